@@ -0,0 +1,155 @@
+// Package admin 提供浏览数据表的只读 HTTP 接口
+//
+// 挂载在任意前缀下，例如：
+//
+//	http.Handle("/_db/", http.StripPrefix("/_db", admin.Handler(admin.Options{Auth: checkToken})))
+//
+// 接口返回 JSON：
+//
+//	GET /                       表名列表
+//	GET /{table}                表结构和建表语句
+//	GET /{table}/rows?take=&skip=  分页读取行
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dgf1988/db"
+)
+
+// Options 接口的选项
+type Options struct {
+	//认证请求，返回 false 时响应 403，为 nil 时不认证
+	Auth func(r *http.Request) bool
+	//每页最多的行数，默认 100
+	MaxTake int
+}
+
+type handler struct {
+	opt Options
+}
+
+// Handler 返回浏览数据表的 http.Handler
+func Handler(opt Options) http.Handler {
+	if opt.MaxTake <= 0 {
+		opt.MaxTake = 100
+	}
+	return &handler{opt: opt}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.opt.Auth != nil && !h.opt.Auth(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "":
+		h.tables(w)
+	case len(parts) == 1:
+		h.schema(w, parts[0])
+	case len(parts) == 2 && parts[1] == "rows":
+		h.rows(w, r, parts[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *handler) tables(w http.ResponseWriter) {
+	names, err := db.ShowTables()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, names)
+}
+
+// 只允许访问当前数据库中已有的表
+func (h *handler) table(w http.ResponseWriter, name string) *db.Table {
+	names, err := db.ShowTables()
+	if err != nil {
+		writeError(w, err)
+		return nil
+	}
+	for _, n := range names {
+		if n == name {
+			t, err := db.GetTable(name)
+			if err != nil {
+				writeError(w, err)
+				return nil
+			}
+			return t
+		}
+	}
+	http.Error(w, "table not found", http.StatusNotFound)
+	return nil
+}
+
+func (h *handler) schema(w http.ResponseWriter, name string) {
+	t := h.table(w, name)
+	if t == nil {
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"name":        t.TbName,
+		"primary_key": t.PrimaryKey,
+		"fields":      t.Fields,
+		"sql":         t.ToSql(),
+	})
+}
+
+func (h *handler) rows(w http.ResponseWriter, r *http.Request, name string) {
+	t := h.table(w, name)
+	if t == nil {
+		return
+	}
+	take, _ := strconv.Atoi(r.URL.Query().Get("take"))
+	skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+	if take <= 0 || take > h.opt.MaxTake {
+		take = h.opt.MaxTake
+	}
+	if skip < 0 {
+		skip = 0
+	}
+	rs, err := t.List(take, skip)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rs.Close()
+	data := make([]map[string]interface{}, 0, take)
+	for rs.Next() {
+		m, err := rs.Map()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		for k, v := range m {
+			if b, ok := v.([]byte); ok {
+				m[k] = string(b)
+			}
+		}
+		data = append(data, m)
+	}
+	if err = rs.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"take": take, "skip": skip, "rows": data})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
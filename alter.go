@@ -0,0 +1,110 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Refresh 重新读取表结构
+func (t *Table) Refresh() error {
+	nt, err := getTable(t.DbName, t.TbName)
+	if err != nil {
+		return err
+	}
+	//保留表上的设置
+	if t.idempotencyKey >= 0 {
+		nt.idempotencyKey, _ = nt.indexOf(t.Fields[t.idempotencyKey].Name)
+	}
+	nt.ctx, nt.audit, nt.rowCache = t.ctx, t.audit, t.rowCache
+	nt.resultTTL, nt.flight, nt.async, nt.db = t.resultTTL, t.flight, t.async, t.db
+	nt.validate, nt.clientDefaults, nt.idGen, nt.redact = t.validate, t.clientDefaults, t.idGen, t.redact
+	nt.structMode, nt.loc, nt.scopes, nt.strict = t.structMode, t.loc, t.scopes, t.strict
+	if t.hint != "" {
+		nt.hint = t.hint
+		nt.prepareSql()
+	}
+	for i, fn := range t.masks {
+		nt.Mask(fn, t.Fields[i].Name)
+	}
+	if t.cipher != nil {
+		columns := make([]string, 0, len(t.cipher.columns))
+		for i := range t.cipher.columns {
+			if _, err = nt.indexOf(t.Fields[i].Name); err == nil {
+				columns = append(columns, t.Fields[i].Name)
+			}
+		}
+		if err = nt.Encrypt(t.cipher.keys, columns...); err != nil {
+			return err
+		}
+	}
+	*t = *nt
+	return nil
+}
+
+// 执行 ALTER TABLE 并刷新表结构
+func (t *Table) alter(spec string) error {
+	if _, err := t.exec(fmt.Sprintf("ALTER TABLE %s %s", t.Fullname, spec)); err != nil {
+		return err
+	}
+	return t.Refresh()
+}
+
+// AddColumn 添加字段，after 不为空时添加到该字段之后
+func (t *Table) AddColumn(f Field, after string) error {
+	spec := "ADD COLUMN " + f.ToSql()
+	if after != "" {
+		spec += fmt.Sprintf(" AFTER `%s`", after)
+	}
+	return t.alter(spec)
+}
+
+// DropColumn 删除字段
+func (t *Table) DropColumn(name string) error {
+	if _, err := t.indexOf(name); err != nil {
+		return err
+	}
+	return t.alter(fmt.Sprintf("DROP COLUMN `%s`", name))
+}
+
+// ModifyColumn 修改字段的定义，字段名不变
+func (t *Table) ModifyColumn(f Field) error {
+	if _, err := t.indexOf(f.Name); err != nil {
+		return err
+	}
+	return t.alter("MODIFY COLUMN " + f.ToSql())
+}
+
+// RenameColumn 重命名字段，f 为新的字段定义
+func (t *Table) RenameColumn(name string, f Field) error {
+	if _, err := t.indexOf(name); err != nil {
+		return err
+	}
+	return t.alter(fmt.Sprintf("CHANGE COLUMN `%s` %s", name, f.ToSql()))
+}
+
+// AddIndex 添加普通索引
+func (t *Table) AddIndex(name string, columns ...string) error {
+	return t.addIndex("INDEX", name, columns)
+}
+
+// AddUniqueIndex 添加唯一索引
+func (t *Table) AddUniqueIndex(name string, columns ...string) error {
+	return t.addIndex("UNIQUE INDEX", name, columns)
+}
+
+func (t *Table) addIndex(kind, name string, columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("db: the index (%s) has no columns", name)
+	}
+	for i := range columns {
+		if _, err := t.indexOf(columns[i]); err != nil {
+			return err
+		}
+	}
+	return t.alter(fmt.Sprintf("ADD %s `%s` (`%s`)", kind, name, strings.Join(columns, "`, `")))
+}
+
+// DropIndex 删除索引
+func (t *Table) DropIndex(name string) error {
+	return t.alter(fmt.Sprintf("DROP INDEX `%s`", name))
+}
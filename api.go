@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"errors"
@@ -9,16 +10,19 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 var (
-	//连接池
+	//连接池，通过 conn 读取
 	db *sql.DB
-	//数据库名
+	//数据库名，通过 dbName 读取
 	db_name string
+	//保护 db 和 db_name
+	dbMu sync.RWMutex
 )
 
 var (
@@ -28,42 +32,71 @@ var (
 
 //直接使用标准库的API
 func Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return db.Query(query, args...)
+	return QueryContext(context.Background(), query, args...)
 }
 
 func QueryRow(query string, args ...interface{}) *sql.Row {
-	return db.QueryRow(query, args...)
+	return QueryRowContext(context.Background(), query, args...)
 }
 
 func Exec(query string, args ...interface{}) (sql.Result, error) {
-	return db.Exec(query, args...)
+	return ExecContext(context.Background(), query, args...)
 }
 
-//连接
+//带上下文的API，上下文的期限到达时客户端放弃查询
+func QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return queryOn(connFrom(ctx), ctx, query, args...)
+}
+
+func QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return queryRowOn(connFrom(ctx), ctx, query, args...)
+}
+
+func ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return execOn(connFrom(ctx), ctx, query, args...)
+}
+
+//连接，可以重复调用，例如更换密码后重新连接，新的连接池可用后才替换旧的连接池
 func Open(username, password, hostname string, port int, databasename string) error {
-	sqldb, err := sql.Open("mysql",
-		fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8&parseTime=true", username, password, hostname, port, databasename))
+	sqldb, err := openDB(dsn(username, password, fmt.Sprintf("%s:%d", hostname, port), databasename))
 	if err != nil {
 		return err
 	}
 	if err = sqldb.Ping(); err != nil {
+		sqldb.Close()
 		return err
 	}
-	db = sqldb
-	db_name = databasename
+	setConn(sqldb, databasename)
+	setOpenParams(username, fmt.Sprintf("%s:%d", hostname, port), databasename)
 	return nil
 }
 
-//Use命令
+//Use命令，切换 GetTable 和 ShowTables 使用的数据库
+//
+//与旧版本不同，Use 不再在连接上执行 USE：旧版本只切换了连接池中的某一个连接，
+//其他连接仍使用 Open 时的数据库。现在连接池中的连接都不执行 USE，Query、Exec 直接执行的语句中
+//不带库名的表仍然属于 Open 时的数据库，已经取得的表也不受影响。
+//数据库不存在时返回错误，当前数据库不变，旧版本在出错时也会切换。
 func Use(databasename string) error {
-	_, err := Exec(fmt.Sprintf("use %s", databasename))
-	db_name = databasename
-	return err
+	var name string
+	err := QueryRow("SELECT SCHEMA_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?", databasename).Scan(&name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("db: unknown database (%s)", databasename)
+	}
+	if err != nil {
+		return err
+	}
+	setDbName(databasename)
+	return nil
 }
 
 //命令
 func ShowTables() ([]string, error) {
-	rows, err := Query("show tables")
+	return showTables(dbName())
+}
+
+func showTables(dbname string) ([]string, error) {
+	rows, err := Query(fmt.Sprintf("show tables from `%s`", dbname))
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +136,8 @@ const (
 	TypeYear
 	TypeTime
 	TypeTimestamp
+
+	TypeEnum
 )
 
 //解析到常量
@@ -145,6 +180,10 @@ func parseDbType(typename string) int {
 		return TypeTimestamp
 	case "time":
 		return TypeTime
+
+	//string
+	case "enum":
+		return TypeEnum
 	}
 	panic(fmt.Sprintf("db: parse type name error: %s", typename))
 }
@@ -182,6 +221,8 @@ func formatDbType(typevalue int) string {
 		return "time"
 	case TypeTimestamp:
 		return "timestamp"
+	case TypeEnum:
+		return "enum"
 	}
 	panic(fmt.Sprintf("db: parse type name error: %s", typevalue))
 }
@@ -201,13 +242,33 @@ type FieldType struct {
 	Name   string
 	Value  int
 	Length int
+	//无符号整数
+	Unsigned bool
+	//枚举的取值
+	Enum []string
+	//DATETIME、TIMESTAMP 和 TIME 的小数秒位数，0 到 6
+	Fsp int
 }
 
 //输出Sql
 func (t FieldType) ToSql() string {
 	switch t.Value {
-	case TypeDate, TypeDatetime, TypeYear, TypeTime, TypeTimestamp, TypeText, TypeMediumText, TypeLongtext:
+	case TypeDatetime, TypeTime, TypeTimestamp:
+		if t.Fsp > 0 {
+			return fmt.Sprintf("%s(%d)", t.Name, t.Fsp)
+		}
+		return t.Name
+	case TypeDate, TypeYear, TypeText, TypeMediumText, TypeLongtext:
 		return t.Name
+	case TypeEnum:
+		values := make([]string, len(t.Enum))
+		for i := range t.Enum {
+			values[i] = "'" + strings.Replace(t.Enum[i], "'", "''", -1) + "'"
+		}
+		return fmt.Sprintf("%s(%s)", t.Name, strings.Join(values, ","))
+	}
+	if t.Unsigned {
+		return fmt.Sprintf("%s(%d) unsigned", t.Name, t.Length)
 	}
 	return fmt.Sprintf("%s(%d)", t.Name, t.Length)
 }
@@ -225,18 +286,65 @@ func (t *FieldType) Scan(v interface{}) error {
 		}
 	}
 	t.Name, t.Value, t.Length = parseFieldType(str)
+	t.Unsigned = strings.Contains(strings.ToLower(str), "unsigned")
+	switch t.Value {
+	case TypeEnum:
+		t.Length = 0
+		t.Enum = parseEnum(str)
+	case TypeDatetime, TypeTime, TypeTimestamp:
+		t.Length, t.Fsp = 0, t.Length
+	}
 	return nil
 }
 
+//解析枚举的取值，例如 enum('a','b')
+func parseEnum(typestr string) []string {
+	values := make([]string, 0)
+	start := strings.Index(typestr, "(")
+	end := strings.LastIndex(typestr, ")")
+	if start < 0 || end < start {
+		return values
+	}
+	body := typestr[start+1 : end]
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\'' {
+			continue
+		}
+		var value []byte
+		for i++; i < len(body); i++ {
+			if body[i] == '\'' {
+				//两个单引号表示一个单引号
+				if i+1 < len(body) && body[i+1] == '\'' {
+					value = append(value, '\'')
+					i++
+					continue
+				}
+				break
+			}
+			value = append(value, body[i])
+		}
+		values = append(values, string(value))
+	}
+	return values
+}
+
 //默认值
 type FieldDefault struct {
 	Null             bool
 	Value            string
 	CurrentTimestamp bool
+	//MySQL 8 的表达式默认值
+	Expression bool
 }
 
 func (d FieldDefault) ToSql() string {
-	return fmt.Sprintf("DEFAULT %s", d.Value)
+	switch {
+	case d.Null, d.CurrentTimestamp:
+		return fmt.Sprintf("DEFAULT %s", d.Value)
+	case d.Expression:
+		return fmt.Sprintf("DEFAULT (%s)", d.Value)
+	}
+	return fmt.Sprintf("DEFAULT '%s'", strings.Replace(d.Value, "'", "''", -1))
 }
 
 func (d *FieldDefault) Scan(v interface{}) error {
@@ -246,7 +354,8 @@ func (d *FieldDefault) Scan(v interface{}) error {
 	} else {
 		d.Null = false
 		d.Value = string(v.([]byte))
-		if d.Value == "CURRENT_TIMESTAMP" {
+		//MySQL 8 带精度，MariaDB 写作 current_timestamp()
+		if strings.HasPrefix(strings.ToUpper(d.Value), "CURRENT_TIMESTAMP") {
 			d.CurrentTimestamp = true
 		}
 	}
@@ -267,12 +376,21 @@ type Field struct {
 	Default  FieldDefault
 	Extra    string
 	Comment  string
+	//字符集和排序规则，非字符类型为空
+	Charset   string
+	Collation string
 }
 
 func (r Field) ToSql() string {
 	var strs = make([]string, 0)
 	strs = append(strs, fmt.Sprintf("`%s`", r.Name))
 	strs = append(strs, r.Type.ToSql())
+	if r.Charset != "" {
+		strs = append(strs, "CHARACTER SET "+r.Charset)
+	}
+	if r.Collation != "" {
+		strs = append(strs, "COLLATE "+r.Collation)
+	}
 	if r.Null {
 		strs = append(strs, "NULL", r.Default.ToSql())
 	} else {
@@ -281,16 +399,38 @@ func (r Field) ToSql() string {
 			strs = append(strs, r.Default.ToSql())
 		}
 	}
-	strs = append(strs, r.Extra)
+	//DEFAULT_GENERATED 只出现在元数据中，不是合法的定义
+	if extra := strings.TrimSpace(strings.Replace(r.Extra, "DEFAULT_GENERATED", "", 1)); extra != "" {
+		strs = append(strs, extra)
+	}
+	if r.Comment != "" {
+		strs = append(strs, "COMMENT "+quoteString(r.Comment))
+	}
 	return strings.Join(strs, " ")
 }
 
+//表结构
+//
+//查询和写入方法不修改表，可以在多个 goroutine 中同时调用。表不是不可变的：
+//SetCache、Encrypt、Mask、EnableAudit 等设置方法以及 Refresh 和修改表结构的方法直接修改表，
+//没有加锁，与其他方法同时调用是数据竞争，应在共享之前调用，或者在 WithContext 等返回的副本上调用。
 type Table struct {
-	DbName      string
-	TbName      string
-	Fields      []Field
-	PrimaryKey  string
+	DbName     string
+	TbName     string
+	Fields     []Field
+	PrimaryKey string
+	//单列唯一索引的字段，联合唯一索引见 Indexes 和 UniqueKeys
 	UniqueIndex []string
+	//表的注释、默认字符集和排序规则
+	Comment   string
+	Charset   string
+	Collation string
+	//全文索引的字段
+	FullText []string
+	//分区方式，未分区时为 nil
+	Partition *Partitioning
+	//所有索引，包括主键
+	Indexes []Index
 
 	Fullname string
 	// 预备Sql执行语句
@@ -304,6 +444,47 @@ type Table struct {
 
 	sqlArgMark []string
 	Len        int
+
+	//幂等键字段的位置，-1 表示未设置
+	idempotencyKey int
+	//执行查询使用的上下文
+	ctx context.Context
+	//审计表，为空时不记录
+	audit string
+	//加密字段
+	cipher *columnCipher
+	//脱敏字段
+	masks map[int]MaskFunc
+	//行缓存
+	rowCache *rowCache
+	//结果集缓存的时间，0 表示不缓存
+	resultTTL time.Duration
+	//合并并发相同查询的方法
+	flight int
+	//异步写入
+	async *AsyncWriter
+	//使用的连接池，为 nil 时使用 Open 打开的连接池
+	db *sql.DB
+	//写入前按字段定义检查
+	validate bool
+	//插入时在客户端填充默认值
+	clientDefaults bool
+	//客户端生成主键
+	idGen IDGenerator
+	//日志中隐藏参数值的字段
+	redact map[string]bool
+	//结构体映射的方式
+	structMode int
+	//查询的索引提示
+	hint string
+	//读取时拒绝有损的转换
+	strict bool
+	//日期时间字段的时区，为 nil 时使用连接的时区
+	loc *time.Location
+	//命名的查询条件
+	scopes map[string]Condition
+	//读取缓冲和结构体映射
+	cache *schemaCache
 }
 
 func (t Table) ToSql() string {
@@ -316,20 +497,55 @@ func (t Table) ToSql() string {
 	if t.PrimaryKey != "" {
 		colitems = append(colitems, fmt.Sprintf("\tPRIMARY KEY (`%s`)", t.PrimaryKey))
 	}
-	for i := range t.UniqueIndex {
-		colitems = append(colitems, fmt.Sprintf("\tUNIQUE KEY `%s_%d` (`%s`)", t.UniqueIndex[i], i, t.UniqueIndex[i]))
+	if len(t.Indexes) > 0 {
+		//按索引名生成，联合索引的字段在同一个键中
+		for _, idx := range t.Indexes {
+			if idx.Name == "PRIMARY" || idx.Type == "FULLTEXT" {
+				continue
+			}
+			colitems = append(colitems, "\t"+idx.ToSql())
+		}
+	} else {
+		for i := range t.UniqueIndex {
+			colitems = append(colitems, fmt.Sprintf("\tUNIQUE KEY `%s_%d` (`%s`)", t.UniqueIndex[i], i, t.UniqueIndex[i]))
+		}
+	}
+	if len(t.FullText) > 0 {
+		colitems = append(colitems, fmt.Sprintf("\tFULLTEXT KEY `fulltext_%s` (`%s`)", t.FullText[0], strings.Join(t.FullText, "`,`")))
+	}
+	options := "ENGINE=InnoDB DEFAULT CHARSET=utf8"
+	if t.Charset != "" {
+		options = "ENGINE=InnoDB DEFAULT CHARSET=" + t.Charset
+	}
+	if t.Collation != "" {
+		options += " COLLATE=" + t.Collation
+	}
+	if t.Comment != "" {
+		options += " COMMENT=" + quoteString(t.Comment)
+	}
+	stritems = append(stritems, strings.Join(colitems, ",\n"), ") "+options)
+	if t.Partition != nil {
+		stritems = append(stritems, t.Partition.ToSql())
 	}
-	stritems = append(stritems, strings.Join(colitems, ",\n"), ") ENGINE=InnoDB DEFAULT CHARSET=utf8")
 	return strings.Join(stritems, "\n")
 }
 
+//读取表结构，tablename 可以写成 "数据库名.表名" 读取其他数据库中的表
 func GetTable(tablename string) (*Table, error) {
+	if i := strings.Index(tablename, "."); i >= 0 {
+		return getTable(tablename[:i], tablePrefix()+tablename[i+1:])
+	}
+	return getTable(dbName(), tablePrefix()+tablename)
+}
+
+func getTable(dbname, tablename string) (*Table, error) {
 	var query string
 	query = `
     SELECT
 		COLUMN_NAME, COLUMN_TYPE,
 		COLUMN_DEFAULT, IS_NULLABLE,
-		COLUMN_KEY,	EXTRA, COLUMN_COMMENT
+		COLUMN_KEY,	EXTRA, COLUMN_COMMENT,
+		CHARACTER_SET_NAME, COLLATION_NAME
 	FROM
 		information_schema.COLUMNS
 	WHERE
@@ -339,7 +555,7 @@ func GetTable(tablename string) (*Table, error) {
     `
 	var rows *sql.Rows
 	var err error
-	rows, err = Query(query, db_name, tablename)
+	rows, err = Query(query, dbname, tablename)
 	if err != nil {
 		return nil, err
 	}
@@ -349,21 +565,25 @@ func GetTable(tablename string) (*Table, error) {
 	table.Fields = make([]Field, 0)
 	table.UniqueIndex = make([]string, 0)
 	table.sqlArgMark = make([]string, 0)
-	table.DbName = db_name
+	table.DbName = dbname
 	table.TbName = tablename
-
-	keys := make([]string, 0)
+	table.idempotencyKey = -1
 
 	for rows.Next() {
 		var row Field
 		var nullable string
-		err = rows.Scan(&row.Name, &row.Type, &row.Default, &nullable, &row.Key, &row.Extra, &row.Comment)
+		var charset, collation sql.NullString
+		err = rows.Scan(&row.Name, &row.Type, &row.Default, &nullable, &row.Key, &row.Extra, &row.Comment, &charset, &collation)
 		if err != nil {
 			return nil, err
 		}
+		row.Charset, row.Collation = charset.String, collation.String
 		row.Null = parseNullable(nullable)
+		//MySQL 8 在 EXTRA 中标记表达式默认值
+		if strings.Contains(strings.ToUpper(row.Extra), "DEFAULT_GENERATED") && !row.Default.CurrentTimestamp {
+			row.Default.Expression = true
+		}
 		row.FullName = fmt.Sprintf("%s.`%s`", table.TbName, row.Name)
-		keys = append(keys, row.FullName)
 		table.Fields = append(table.Fields, row)
 		table.sqlArgMark = append(table.sqlArgMark, "?")
 		if row.Key == "PRI" {
@@ -382,26 +602,101 @@ func GetTable(tablename string) (*Table, error) {
 		return nil, fmt.Errorf("the table (%s) columns no found", tablename)
 	}
 
-	table.Fullname = fmt.Sprintf("%s.%s", table.DbName, table.TbName)
-	table.sqlInsert = fmt.Sprintf("INSERT INTO %s", table.Fullname)
-	table.sqlDelete = fmt.Sprintf("DELETE FROM %s", table.Fullname)
-	table.sqlUpdate = fmt.Sprintf("UPDATE %s", table.Fullname)
-	strKeys := strings.Join(keys, ",")
-	table.sqlSelect = fmt.Sprintf("SELECT %s FROM %s ", strKeys, table.Fullname)
-	table.sqlSelectCount = fmt.Sprintf("SELECT COUNT(%s) FROM %s", table.PrimaryKey, table.Fullname)
+	table.FullText, err = getFullText(table.DbName, table.TbName)
+	if err != nil {
+		return nil, err
+	}
+	table.Partition, err = getPartitioning(table.DbName, table.TbName)
+	if err != nil {
+		return nil, err
+	}
+	table.Indexes, err = getIndexes(table.DbName, table.TbName)
+	if err != nil {
+		return nil, err
+	}
+	if err = table.loadMeta(); err != nil {
+		return nil, err
+	}
+
+	table.prepareSql()
+	table.newSchemaCache()
 	return &table, nil
 }
 
-// NullTime 可空时间结构体
+//生成预备的Sql语句
+func (t *Table) prepareSql() {
+	keys := make([]string, len(t.Fields))
+	for i := range t.Fields {
+		keys[i] = t.Fields[i].FullName
+	}
+	t.Fullname = fmt.Sprintf("%s.%s", t.DbName, t.TbName)
+	t.sqlInsert = fmt.Sprintf("INSERT INTO %s", t.Fullname)
+	t.sqlDelete = fmt.Sprintf("DELETE FROM %s", t.Fullname)
+	t.sqlUpdate = fmt.Sprintf("UPDATE %s", t.Fullname)
+	strKeys := strings.Join(keys, ",")
+	t.sqlSelect = fmt.Sprintf("SELECT %s FROM %s ", strKeys, t.sqlFrom())
+	t.sqlSelectCount = fmt.Sprintf("SELECT COUNT(%s) FROM %s", t.PrimaryKey, t.sqlFrom())
+}
+
+// NullTime 可空时间结构体，字段与 sql.NullTime 相同，可以用 SqlNullTime 和 NullTimeFrom 互相转换
 type NullTime struct {
 	Time  time.Time
 	Valid bool // Valid is true if Time is not NULL
 }
 
+// NullTimeFrom 从 sql.NullTime 转换
+func NullTimeFrom(nt sql.NullTime) NullTime {
+	return NullTime{Time: nt.Time, Valid: nt.Valid}
+}
+
+// SqlNullTime 转换为 sql.NullTime
+func (nt NullTime) SqlNullTime() sql.NullTime {
+	return sql.NullTime{Time: nt.Time, Valid: nt.Valid}
+}
+
 // Scan implements the Scanner interface.
+//
+// 除了 time.Time，也接受未设置 parseTime 时的 []byte 和 string，零值日期读为 NULL，
+// 无法解析的值返回错误。
 func (nt *NullTime) Scan(value interface{}) error {
-	nt.Time, nt.Valid = value.(time.Time)
-	return nil
+	var err error
+	switch v := value.(type) {
+	case nil:
+		nt.Time, nt.Valid = time.Time{}, false
+	case time.Time:
+		nt.Time, nt.Valid = v, true
+	case sql.NullTime:
+		nt.Time, nt.Valid = v.Time, v.Valid
+	case []byte:
+		nt.Time, nt.Valid, err = parseDateTime(string(v))
+	case string:
+		nt.Time, nt.Valid, err = parseDateTime(v)
+	default:
+		nt.Time, nt.Valid = time.Time{}, false
+		err = fmt.Errorf("db: can't scan %T into NullTime", value)
+	}
+	return err
+}
+
+// MySQL 的日期时间格式
+var dateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+// 解析日期时间字符串，零值日期返回 Valid 为 false
+func parseDateTime(s string) (time.Time, bool, error) {
+	if s == "" || strings.HasPrefix(s, "0000-00-00") {
+		return time.Time{}, false, nil
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, getLocation()); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("db: can't parse (%s) as time", s)
 }
 
 // Value implements the driver Valuer interface.
@@ -435,9 +730,13 @@ func (t Table) makeScans() []interface{} {
 		switch t.Fields[i].Type.Value {
 		case TypeInt, TypeBigint:
 			scans[i] = new(int64)
-		case TypeDate, TypeDatetime, TypeYear, TypeTime, TypeTimestamp:
+		case TypeDate, TypeDatetime, TypeTimestamp:
 			scans[i] = new(time.Time)
-		case TypeChar, TypeVarchar, TypeText, TypeMediumText, TypeLongtext:
+		case TypeTime:
+			scans[i] = new(Duration)
+		case TypeYear:
+			scans[i] = new(int16)
+		case TypeChar, TypeVarchar, TypeText, TypeMediumText, TypeLongtext, TypeEnum:
 			scans[i] = new(string)
 		case TypeFloat, TypeDouble, TypeDecimal:
 			scans[i] = new(float64)
@@ -454,9 +753,13 @@ func (t Table) makeNullableScans() []interface{} {
 		switch t.Fields[i].Type.Value {
 		case TypeInt, TypeBigint:
 			scans[i] = new(sql.NullInt64)
-		case TypeDate, TypeDatetime, TypeYear, TypeTime, TypeTimestamp:
+		case TypeDate, TypeDatetime, TypeTimestamp:
 			scans[i] = new(NullTime)
-		case TypeChar, TypeVarchar, TypeText, TypeMediumText, TypeLongtext:
+		case TypeTime:
+			scans[i] = new(NullDuration)
+		case TypeYear:
+			scans[i] = new(sql.NullInt16)
+		case TypeChar, TypeVarchar, TypeText, TypeMediumText, TypeLongtext, TypeEnum:
 			scans[i] = new(sql.NullString)
 		case TypeFloat, TypeDouble, TypeDecimal:
 			scans[i] = new(sql.NullFloat64)
@@ -477,18 +780,19 @@ func (t Table) makeStructScans(object interface{}) ([]interface{}, error) {
 	if rv.Kind() != reflect.Struct {
 		return nil, fmt.Errorf("db: the pointer (%s) can't point to a struct object", rv.Kind())
 	}
-	if rv.NumField() != t.Len {
-		return nil, fmt.Errorf("db: the object field numbers (%d) not equals table column numbers (%d)", rv.NumField(), t.Len)
+	leaves := structLeaves(rv.Type(), nil, "")
+	if len(leaves) != t.Len {
+		return nil, fmt.Errorf("db: the object field numbers (%d) not equals table column numbers (%d)", len(leaves), t.Len)
 	}
 	for i := range scans {
-		scans[i] = rv.Field(i).Addr().Interface()
+		scans[i] = rv.FieldByIndex(leaves[i].index).Addr().Interface()
 	}
 	return scans, nil
 }
 
 func (t Table) parseSlice(scans []interface{}) []interface{} {
 	data := make([]interface{}, t.Len)
-	for i := range scans {
+	for i := range data {
 		data[i] = parseValue(scans[i])
 	}
 	return data
@@ -503,6 +807,9 @@ func (t Table) parseMap(scans []interface{}) map[string]interface{} {
 }
 
 func parseValue(src interface{}) interface{} {
+	if v, ok := parseTemporal(src); ok {
+		return v
+	}
 	if s, ok := src.(driver.Valuer); ok {
 		src, _ = s.Value()
 	}
@@ -513,25 +820,35 @@ func parseValue(src interface{}) interface{} {
 }
 
 func convertValue(dest interface{}, src interface{}) error {
+	return convertValueMode(dest, src, false)
+}
+
+// 转换读到的值，strict 为 true 时拒绝有损的转换，见 SetStrictConvert
+func convertValueMode(dest interface{}, src interface{}, strict bool) error {
 	if s, ok := src.(driver.Valuer); ok {
 		src, _ = s.Value()
 	}
+	if ok, err := scanConverted(dest, src); ok {
+		return err
+	}
 	if d, ok := dest.(sql.Scanner); ok {
 		return d.Scan(src)
 	}
 	switch s := src.(type) {
 	case *int64:
-		return convertValue(dest, *s)
+		return convertValueMode(dest, *s, strict)
 	case *bool:
-		return convertValue(dest, *s)
+		return convertValueMode(dest, *s, strict)
 	case *float64:
-		return convertValue(dest, *s)
+		return convertValueMode(dest, *s, strict)
 	case *string:
-		return convertValue(dest, *s)
+		return convertValueMode(dest, *s, strict)
 	case *time.Time:
-		return convertValue(dest, *s)
+		return convertValueMode(dest, *s, strict)
 	case *[]byte:
-		return convertValue(dest, *s)
+		return convertValueMode(dest, *s, strict)
+	case *int16:
+		return convertValueMode(dest, int64(*s), strict)
 	//int64
 	case int64:
 		switch d := dest.(type) {
@@ -541,6 +858,15 @@ func convertValue(dest interface{}, src interface{}) error {
 			}
 			*d = s
 			return nil
+		case *int16:
+			if d == nil {
+				return ErrNilPtr
+			}
+			if s < -1<<15 || s >= 1<<15 {
+				return fmt.Errorf("db: the int64(%v) overflows int16", s)
+			}
+			*d = int16(s)
+			return nil
 		case *string:
 			if d == nil {
 				return ErrNilPtr
@@ -563,6 +889,9 @@ func convertValue(dest interface{}, src interface{}) error {
 			if d == nil {
 				return ErrNilPtr
 			}
+			if strict && (s > maxExactFloat || s < -maxExactFloat) {
+				return fmt.Errorf("db: the int64(%v) can't convert to float64 exactly", s)
+			}
 			*d = float64(s)
 			return nil
 		}
@@ -663,24 +992,43 @@ func convertValue(dest interface{}, src interface{}) error {
 				return ErrNilPtr
 			}
 			value, err := strconv.ParseBool(s)
+			if strict {
+				value, err = strictBool(s)
+			}
 			if err != nil {
 				return err
 			} else {
 				*d = value
 				return nil
 			}
-		case *time.Time:
+		case *time.Duration:
 			if d == nil {
 				return ErrNilPtr
 			}
-			value, err := time.Parse("2006-01-02 15:04:05", s)
+			value, err := ParseDuration(s)
 			if err != nil {
-				value, err = time.Parse("2006-01-02", s)
-				if err != nil {
+				return err
+			}
+			*d = time.Duration(value)
+			return nil
+		case *time.Time:
+			if d == nil {
+				return ErrNilPtr
+			}
+			if strict {
+				if err := strictDateTime(s); err != nil {
 					return err
 				}
 			}
-			*d = value
+			value, valid, err := parseDateTime(s)
+			if err != nil {
+				return err
+			}
+			if valid {
+				*d = value
+			} else {
+				*d = time.Time{}
+			}
 			return nil
 		}
 	case []byte:
@@ -692,7 +1040,7 @@ func convertValue(dest interface{}, src interface{}) error {
 			*d = s
 			return nil
 		default:
-			return convertValue(dest, string(s))
+			return convertValueMode(dest, string(s), strict)
 		}
 	case time.Time:
 		switch d := dest.(type) {
@@ -700,7 +1048,7 @@ func convertValue(dest interface{}, src interface{}) error {
 			if d == nil {
 				return ErrNilPtr
 			}
-			*d = s.Format("2006-01-02 15:04:05")
+			*d = s.Format("2006-01-02 15:04:05.999999")
 			return nil
 		case *time.Time:
 			if d == nil {
@@ -716,19 +1064,29 @@ func convertValue(dest interface{}, src interface{}) error {
 type Row struct {
 	*sql.Row
 	t *Table
+	//执行的语句，用于包装错误
+	query string
+	//生成查询时的错误
+	err error
+	//不为空时从这里读取，而不是 Row
+	src scanner
 }
 
 func (r *Row) Scan(dest ...interface{}) error {
-	scans := r.t.makeNullableScans()
-	err := r.Row.Scan(scans...)
+	if r.err != nil {
+		return r.err
+	}
+	scans := r.t.getScans()
+	defer r.t.putScans(scans)
+	err := r.t.scan(r.source(), scans)
 	if err != nil {
-		return err
+		return r.t.wrapErr(r.query, err)
 	}
 	for i := range dest {
 		if dest[i] == nil {
 			continue
 		}
-		err = convertValue(dest[i], scans[i])
+		err = r.t.convertValue(dest[i], scans[i])
 		if err != nil {
 			return err
 		}
@@ -737,45 +1095,43 @@ func (r *Row) Scan(dest ...interface{}) error {
 }
 
 func (r *Row) Struct(dest interface{}) error {
-	rv := reflect.ValueOf(dest)
-	if rv.Kind() != reflect.Ptr {
-		return fmt.Errorf("db: the object (%s) is not a pointer", rv.Kind())
-	}
-	rv = rv.Elem()
-	if rv.Kind() != reflect.Struct {
-		return fmt.Errorf("db: the pointer (%s) is not point to a struct object", rv.Kind())
+	if r.err != nil {
+		return r.err
 	}
-	if rv.NumField() != r.t.Len {
-		return fmt.Errorf("db: the object field numbers (%d) not equals table column numbers (%d)", rv.NumField(), r.t.Len)
-	}
-
-	var err error
-	var scans = r.t.makeNullableScans()
-	if err = r.Row.Scan(scans...); err != nil {
+	rv, plan, err := r.t.structDest(dest)
+	if err != nil {
 		return err
 	}
-	for i := range scans {
-		if err = convertValue(rv.Field(i).Addr().Interface(), scans[i]); err != nil {
-			return err
-		}
+	var scans = r.t.getScans()
+	defer r.t.putScans(scans)
+	if err = r.t.scan(r.source(), scans); err != nil {
+		return r.t.wrapErr(r.query, err)
 	}
-	return nil
+	return plan.assign(rv, scans, r.t.isStrict())
 }
 
 func (r *Row) Slice() ([]interface{}, error) {
-	scans := r.t.makeNullableScans()
-	err := r.Row.Scan(scans...)
+	if r.err != nil {
+		return nil, r.err
+	}
+	scans := r.t.getScans()
+	defer r.t.putScans(scans)
+	err := r.t.scan(r.source(), scans)
 	if err != nil {
-		return nil, err
+		return nil, r.t.wrapErr(r.query, err)
 	}
 	return r.t.parseSlice(scans), nil
 }
 
 func (r *Row) Map() (map[string]interface{}, error) {
-	scans := r.t.makeNullableScans()
-	err := r.Row.Scan(scans...)
+	if r.err != nil {
+		return nil, r.err
+	}
+	scans := r.t.getScans()
+	defer r.t.putScans(scans)
+	err := r.t.scan(r.source(), scans)
 	if err != nil {
-		return nil, err
+		return nil, r.t.wrapErr(r.query, err)
 	}
 	return r.t.parseMap(scans), nil
 }
@@ -784,10 +1140,24 @@ type Rows struct {
 	*sql.Rows
 	t     *Table
 	scans []interface{}
+	//不为空时从这里读取，而不是 Rows
+	src rowsSource
+	//上次使用的结构体映射
+	plan *structPlan
+	//表字段之后附加列的名称
+	extras []string
+	//设置了泄漏检测的终结器
+	leak bool
+	//GetPage 的分页
+	page *pagedSource
+	//读取过程中上下文取消的错误
+	cancelled error
+	//释放查询超时的计时器
+	cancel context.CancelFunc
 }
 
 func (rs *Rows) Scan(dest ...interface{}) error {
-	err := rs.Rows.Scan(rs.scans...)
+	err := rs.t.scan(rs.source(), rs.scans)
 	if err != nil {
 		return err
 	}
@@ -795,7 +1165,7 @@ func (rs *Rows) Scan(dest ...interface{}) error {
 		if dest[i] == nil {
 			continue
 		}
-		err = convertValue(dest[i], rs.scans[i])
+		err = rs.t.convertValue(dest[i], rs.scans[i])
 		if err != nil {
 			return err
 		}
@@ -805,31 +1175,23 @@ func (rs *Rows) Scan(dest ...interface{}) error {
 
 func (rs *Rows) Struct(dest interface{}) error {
 	rv := reflect.ValueOf(dest)
-	if rv.Kind() != reflect.Ptr {
-		return fmt.Errorf("db: the object (%s) is not a pointer", rv.Kind())
-	}
-	rv = rv.Elem()
-	if rv.Kind() != reflect.Struct {
-		return fmt.Errorf("db: the pointer (%s) is not point to a struct object", rv.Kind())
-	}
-	if rv.NumField() != rs.t.Len {
-		return fmt.Errorf("db: the object field numbers (%d) not equals table column numbers (%d)", rv.NumField(), rs.t.Len)
-	}
-
-	var err error
-	if err = rs.Rows.Scan(rs.scans...); err != nil {
-		return err
-	}
-	for i := range rs.scans {
-		if err = convertValue(rv.Field(i).Addr().Interface(), rs.scans[i]); err != nil {
+	//同一个结果集通常读到同一种结构体，复用上次的映射
+	if rs.plan == nil || rv.Type() != rs.plan.typ {
+		var err error
+		if rv, rs.plan, err = rs.t.structDest(dest); err != nil {
 			return err
 		}
+	} else {
+		rv = rv.Elem()
 	}
-	return nil
+	if err := rs.t.scan(rs.source(), rs.scans); err != nil {
+		return err
+	}
+	return rs.plan.assign(rv, rs.scans, rs.t.isStrict())
 }
 
 func (rs *Rows) Slice() ([]interface{}, error) {
-	err := rs.Rows.Scan(rs.scans...)
+	err := rs.t.scan(rs.source(), rs.scans)
 	if err != nil {
 		return nil, err
 	}
@@ -837,7 +1199,7 @@ func (rs *Rows) Slice() ([]interface{}, error) {
 }
 
 func (rs *Rows) Map() (map[string]interface{}, error) {
-	err := rs.Rows.Scan(rs.scans...)
+	err := rs.t.scan(rs.source(), rs.scans)
 	if err != nil {
 		return nil, err
 	}
@@ -848,41 +1210,107 @@ type Setter struct {
 	t     *Table
 	query string
 	args  []interface{}
+	//生成条件时的错误
+	err error
 }
 
 func (s *Setter) Values(values ...interface{}) (int64, error) {
+	if s.err != nil {
+		return -1, s.err
+	}
+	values, err := s.t.updateValues(values)
+	if err != nil {
+		return -1, err
+	}
 	listkey := make([]string, 0)
 	listvalue := make([]interface{}, 0)
 	for i := range values {
 		if values[i] == nil {
 			continue
 		}
-		listkey = append(listkey, s.t.Fields[i].FullName+"=?")
-		listvalue = append(listvalue, values[i])
+		mark, args := valueMark(values[i])
+		listkey = append(listkey, s.t.Fields[i].FullName+"="+mark)
+		listvalue = append(listvalue, args...)
 	}
 	strSql := fmt.Sprintf("%s SET %s %s", s.t.sqlUpdate, strings.Join(listkey, ", "), s.query)
-	res, err := Exec(strSql, append(listvalue, s.args...)...)
+	res, err := s.t.write(opUpdate, strSql, append(listvalue, s.args...), s.query, s.args, values)
 	if err != nil {
 		return -1, err
 	}
-	return res.RowsAffected()
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return 0, ErrNoRowsAffected
+	}
+	return n, err
+}
+
+// 修改前检查、转换和加密按字段位置排列的值
+func (t Table) updateValues(values []interface{}) ([]interface{}, error) {
+	if t.validate {
+		if err := t.Validate(false, values...); err != nil {
+			return nil, err
+		}
+	}
+	values = t.formatValues(values)
+	if t.cipher != nil {
+		return t.cipher.encryptValues(values)
+	}
+	return values, nil
 }
 
 // Add 添加数据
 func (t Table) Add(values ...interface{}) (int64, error) {
+	var id int64
+	if t.idGen != nil {
+		var err error
+		if values, id, err = t.fillID(values); err != nil {
+			return -1, err
+		}
+	}
+	if t.idempotencyKey >= 0 {
+		var err error
+		if values, err = t.fillIdempotencyKey(values); err != nil {
+			return -1, err
+		}
+	}
+	if t.clientDefaults {
+		values = t.fillDefaults(values)
+	}
+	if t.validate {
+		if err := t.Validate(true, values...); err != nil {
+			return -1, err
+		}
+	}
+	values = t.formatValues(values)
+	if t.cipher != nil {
+		var err error
+		if values, err = t.cipher.encryptValues(values); err != nil {
+			return -1, err
+		}
+	}
 	listcolname := make([]string, 0)
+	listmark := make([]string, 0)
 	listParam := make([]interface{}, 0)
 	for i := range values {
 		if values[i] == nil {
 			continue
 		}
+		mark, args := valueMark(values[i])
 		listcolname = append(listcolname, t.Fields[i].FullName)
-		listParam = append(listParam, values[i])
+		listmark = append(listmark, mark)
+		listParam = append(listParam, args...)
 	}
-	res, err := Exec(fmt.Sprintf("%s (%s) VALUES (%s)", t.sqlInsert, strings.Join(listcolname, ", "), strings.Join(t.sqlArgMark[:len(listParam)], ", ")), listParam...)
+	strSql := fmt.Sprintf("%s (%s) VALUES (%s)", t.sqlInsert, strings.Join(listcolname, ", "), strings.Join(listmark, ", "))
+	res, err := t.write(opInsert, strSql, listParam, "", nil, values)
 	if err != nil {
+		if id, ok := t.existingIdempotent(values, err); ok {
+			return id, nil
+		}
 		return -1, err
 	}
+	if id != 0 {
+		return id, nil
+	}
 	return res.LastInsertId()
 }
 
@@ -897,7 +1325,8 @@ func (t Table) Del(args ...interface{}) (int64, error) {
 		listparam = append(listparam, args[i])
 	}
 
-	res, err := Exec(fmt.Sprintf("%s WHERE %s LIMIT 1", t.sqlDelete, strings.Join(listwhere, " AND ")), listparam...)
+	where := fmt.Sprintf("WHERE %s LIMIT 1", strings.Join(listwhere, " AND "))
+	res, err := t.write(opDelete, fmt.Sprintf("%s %s", t.sqlDelete, where), listparam, where, listparam, nil)
 	if err != nil {
 		return -1, err
 	}
@@ -905,6 +1334,9 @@ func (t Table) Del(args ...interface{}) (int64, error) {
 }
 
 func (t *Table) Get(args ...interface{}) *Row {
+	if id, ok := t.onlyPrimaryKey(args); ok {
+		return t.GetByID(id)
+	}
 	listwhere := make([]string, 0)
 	listparam := make([]interface{}, 0)
 	for i := range args {
@@ -915,9 +1347,7 @@ func (t *Table) Get(args ...interface{}) *Row {
 		listparam = append(listparam, args[i])
 	}
 	strSql := fmt.Sprintf("%s WHERE %s limit 1", t.sqlSelect, strings.Join(listwhere, " AND "))
-	return &Row{
-		Row: QueryRow(strSql, listparam...), t: t,
-	}
+	return t.row(strSql, listparam...)
 }
 
 func (t *Table) GetMany(args ...interface{}) (*Rows, error) {
@@ -931,13 +1361,7 @@ func (t *Table) GetMany(args ...interface{}) (*Rows, error) {
 		listparam = append(listparam, args[i])
 	}
 	strSql := fmt.Sprintf("%s WHERE %s", t.sqlSelect, strings.Join(listwhere, " AND "))
-	rows, err := Query(strSql, listparam...)
-	if err != nil {
-		return nil, err
-	}
-	return &Rows{
-		Rows: rows, t: t, scans: t.makeNullableScans(),
-	}, nil
+	return t.rows(strSql, listparam...)
 }
 
 func (t *Table) Find(args ...interface{}) *Row {
@@ -951,9 +1375,7 @@ func (t *Table) Find(args ...interface{}) *Row {
 		listparam = append(listparam, args[i])
 	}
 	strSql := fmt.Sprintf("%s WHERE %s limit 1", t.sqlSelect, strings.Join(listwhere, " OR "))
-	return &Row{
-		Row: QueryRow(strSql, listparam...), t: t,
-	}
+	return t.row(strSql, listparam...)
 }
 
 func (t *Table) FindMany(args ...interface{}) (*Rows, error) {
@@ -967,33 +1389,15 @@ func (t *Table) FindMany(args ...interface{}) (*Rows, error) {
 		listparam = append(listparam, args[i])
 	}
 	strSql := fmt.Sprintf("%s WHERE %s", t.sqlSelect, strings.Join(listwhere, " OR "))
-	rows, err := Query(strSql, listparam...)
-	if err != nil {
-		return nil, err
-	}
-	return &Rows{
-		Rows: rows, t: t, scans: t.makeNullableScans(),
-	}, nil
+	return t.rows(strSql, listparam...)
 }
 
 func (t *Table) List(take, skip int) (*Rows, error) {
-	rows, err := Query(fmt.Sprintf("%s ORDER BY %s limit ?, ?", t.sqlSelect, t.PrimaryKey), skip, take)
-	if err != nil {
-		return nil, err
-	}
-	return &Rows{
-		Rows: rows, t: t, scans: t.makeNullableScans(),
-	}, nil
+	return t.rows(fmt.Sprintf("%s ORDER BY %s limit ?, ?", t.sqlSelect, t.PrimaryKey), skip, take)
 }
 
 func (t *Table) ListDesc(take, skip int) (*Rows, error) {
-	rows, err := Query(fmt.Sprintf("%s ORDER BY %s DESC limit ?, ?", t.sqlSelect, t.PrimaryKey), skip, take)
-	if err != nil {
-		return nil, err
-	}
-	return &Rows{
-		Rows: rows, t: t, scans: t.makeNullableScans(),
-	}, nil
+	return t.rows(fmt.Sprintf("%s ORDER BY %s DESC limit ?, ?", t.sqlSelect, t.PrimaryKey), skip, take)
 }
 
 func (t *Table) Update(args ...interface{}) *Setter {
@@ -1029,16 +1433,11 @@ func (t *Table) UpdateMany(args ...interface{}) *Setter {
 }
 
 func (t Table) Count() (int64, error) {
-	var num int64
-	if err := QueryRow(t.sqlSelectCount).Scan(&num); err != nil {
-		return -1, err
-	}
-	return num, nil
+	return t.scalarInt64(t.sqlSelectCount)
 }
 
 // Count 统计
 func (t Table) CountBy(args ...interface{}) (int64, error) {
-	var err error
 	var keys = make([]string, 0)
 	var param = make([]interface{}, 0)
 	for i := range args {
@@ -1049,27 +1448,15 @@ func (t Table) CountBy(args ...interface{}) (int64, error) {
 		param = append(param, args[i])
 	}
 	var strSql = fmt.Sprintf("%s WHERE %s ", t.sqlSelectCount, strings.Join(keys, " AND "))
-	var num int64
-	if err = QueryRow(strSql, param...).Scan(&num); err != nil {
-		return -1, err
-	}
-	return num, nil
+	return t.scalarInt64(strSql, param...)
 }
 
 func (t *Table) Query(query string, args ...interface{}) (*Rows, error) {
 	strSql := fmt.Sprintf("%s %s", t.sqlSelect, query)
-	rows, err := Query(strSql, args...)
-	if err != nil {
-		return nil, err
-	}
-	return &Rows{
-		Rows: rows, t: t, scans: t.makeNullableScans(),
-	}, nil
+	return t.rows(strSql, args...)
 }
 
 func (t *Table) QueryRow(query string, args ...interface{}) *Row {
 	strSql := fmt.Sprintf("%s %s", t.sqlSelect, query)
-	return &Row{
-		Row: QueryRow(strSql, args...), t: t,
-	}
+	return t.row(strSql, args...)
 }
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ArchiveOptions 归档选项
+type ArchiveOptions struct {
+	//每批移动的行数，默认 1000
+	ChunkSize int
+	//每批之间的间隔
+	Interval time.Duration
+	//每批提交后回调，参数为已移动的行数
+	Progress func(moved int64)
+}
+
+// Archive 把 column 早于 cutoff 的行移动到归档表 dst，返回移动的行数
+//
+// 每批在一个事务中完成 INSERT ... SELECT 和 DELETE，中途失败时已提交的批次不会回滚。
+// 要求表有主键，只复制两个表共有的字段。
+func (t *Table) Archive(dst *Table, column string, cutoff time.Time, opt ArchiveOptions) (int64, error) {
+	if opt.ChunkSize <= 0 {
+		opt.ChunkSize = 1000
+	}
+	pk, err := t.indexOf(t.PrimaryKey)
+	if err != nil {
+		return 0, err
+	}
+	i, err := t.indexOf(column)
+	if err != nil {
+		return 0, err
+	}
+	//INSERT 的字段不能带表名，SELECT 的字段带源表名
+	targets := make([]string, 0, len(t.Fields))
+	columns := make([]string, 0, len(t.Fields))
+	for j := range t.Fields {
+		if _, err := dst.indexOf(t.Fields[j].Name); err == nil {
+			targets = append(targets, "`"+t.Fields[j].Name+"`")
+			columns = append(columns, t.Fields[j].FullName)
+		}
+	}
+	into, cols := strings.Join(targets, ", "), strings.Join(columns, ", ")
+	var moved int64
+	for {
+		n, err := t.archiveChunk(dst, t.Fields[pk].FullName, t.Fields[i].FullName, into, cols, cutoff, opt.ChunkSize)
+		if err != nil {
+			return moved, err
+		}
+		if n == 0 {
+			return moved, nil
+		}
+		moved += n
+		if opt.Progress != nil {
+			opt.Progress(moved)
+		}
+		if opt.Interval > 0 {
+			time.Sleep(opt.Interval)
+		}
+	}
+}
+
+// 在一个事务中移动一批
+func (t *Table) archiveChunk(dst *Table, pk, column, into, cols string, cutoff time.Time, size int) (int64, error) {
+	ctx := t.context()
+	tx, err := t.sqlDB().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s < ? ORDER BY %s LIMIT ? FOR UPDATE",
+		pk, t.Fullname, column, pk), cutoff, size)
+	if err != nil {
+		return 0, err
+	}
+	keys := make([]interface{}, 0, size)
+	for rows.Next() {
+		var key sql.RawBytes
+		if err = rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, string(key))
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	if _, err = t.execTx(tx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE %s IN (%s)",
+		dst.Fullname, into, cols, t.Fullname, pk, in), keys...); err != nil {
+		return 0, err
+	}
+	res, err := t.execTx(tx, fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", t.Fullname, pk, in), keys...)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	t.trackWrite(t.sqlDB())
+	t.invalidate(toStrings(keys))
+	return res.RowsAffected()
+}
+
+func toStrings(values []interface{}) []string {
+	s := make([]string, len(values))
+	for i := range values {
+		s[i] = values[i].(string)
+	}
+	return s
+}
+
+// 按月分区的名称，例如 p202401
+func monthPartition(month time.Time) string {
+	return month.Format("p200601")
+}
+
+// 月份的第一天
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// 表上已有的分区名称
+func (t *Table) partitionNames() (map[string]bool, error) {
+	p, err := getPartitioning(t.DbName, t.TbName)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	if p != nil {
+		for i := range p.Partitions {
+			names[p.Partitions[i].Name] = true
+		}
+	}
+	return names, nil
+}
+
+// AddMonthPartitions 为按 RANGE (TO_DAYS(column)) 分区的表添加从 from 所在月份开始的 months 个月分区
+//
+// 分区命名为 pYYYYMM，已存在的分区跳过。表上有 MAXVALUE 分区 pmax 时拆分 pmax。
+func (t *Table) AddMonthPartitions(from time.Time, months int) ([]string, error) {
+	names, err := t.partitionNames()
+	if err != nil {
+		return nil, err
+	}
+	month := monthStart(from)
+	added := make([]string, 0, months)
+	defs := make([]string, 0, months)
+	for n := 0; n < months; n++ {
+		next := month.AddDate(0, 1, 0)
+		if name := monthPartition(month); !names[name] {
+			defs = append(defs, fmt.Sprintf("PARTITION `%s` VALUES LESS THAN (TO_DAYS('%s'))", name, next.Format("2006-01-02")))
+			added = append(added, name)
+		}
+		month = next
+	}
+	if len(defs) == 0 {
+		return added, nil
+	}
+	if names["pmax"] {
+		defs = append(defs, "PARTITION `pmax` VALUES LESS THAN MAXVALUE")
+		_, err = t.exec(fmt.Sprintf("ALTER TABLE %s REORGANIZE PARTITION `pmax` INTO (%s)", t.Fullname, strings.Join(defs, ", ")))
+	} else {
+		_, err = t.exec(fmt.Sprintf("ALTER TABLE %s ADD PARTITION (%s)", t.Fullname, strings.Join(defs, ", ")))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// DropMonthPartitions 删除所有数据都早于 cutoff 的月分区，返回删除的分区名称
+func (t *Table) DropMonthPartitions(cutoff time.Time) ([]string, error) {
+	names, err := t.partitionNames()
+	if err != nil {
+		return nil, err
+	}
+	dropped := make([]string, 0)
+	for name := range names {
+		month, err := time.Parse("p200601", name)
+		if err != nil {
+			continue
+		}
+		if !month.AddDate(0, 1, 0).After(cutoff) {
+			dropped = append(dropped, "`"+name+"`")
+		}
+	}
+	if len(dropped) == 0 {
+		return nil, nil
+	}
+	if _, err = t.exec(fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s", t.Fullname, strings.Join(dropped, ", "))); err != nil {
+		return nil, err
+	}
+	for i := range dropped {
+		dropped[i] = strings.Trim(dropped[i], "`")
+	}
+	return dropped, nil
+}
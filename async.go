@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrWriterClosed 异步写入已经停止
+var ErrWriterClosed = errors.New("db: async writer is closed")
+
+// AsyncOptions 异步写入的选项
+type AsyncOptions struct {
+	//每批最多插入的行数，默认 100
+	BatchSize int
+	//最长等待多久写入一次，默认 1 秒
+	FlushInterval time.Duration
+	//队列长度，队列满时 AddAsync 阻塞，默认 BatchSize 的 10 倍
+	QueueSize int
+	//写入失败时回调，参数为失败的行
+	OnError func(err error, rows [][]interface{})
+}
+
+// AsyncWriter 后台批量插入
+type AsyncWriter struct {
+	t     *Table
+	opt   AsyncOptions
+	queue chan []interface{}
+	done  chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+	//取消 Close 时的注册
+	unregister func()
+}
+
+// 保护表的 async 字段
+var asyncMu sync.RWMutex
+
+// StartAsync 启动表的异步写入，之后可以使用 AddAsync
+//
+// 表上已经有正在运行的异步写入时直接返回它，opt 被忽略；Drain 之后再调用会启动新的写入。
+func (t *Table) StartAsync(opt AsyncOptions) *AsyncWriter {
+	asyncMu.Lock()
+	defer asyncMu.Unlock()
+	if t.async != nil && !t.async.isClosed() {
+		return t.async
+	}
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 100
+	}
+	if opt.FlushInterval <= 0 {
+		opt.FlushInterval = time.Second
+	}
+	if opt.QueueSize <= 0 {
+		opt.QueueSize = opt.BatchSize * 10
+	}
+	w := &AsyncWriter{
+		t:     t,
+		opt:   opt,
+		queue: make(chan []interface{}, opt.QueueSize),
+		done:  make(chan struct{}),
+	}
+	t.async = w
+	w.unregister = OnClose(w.Drain)
+	go w.run()
+	return w
+}
+
+// AddAsync 把一行放入异步写入队列，参数与 Add 相同
+//
+// 队列满时阻塞直到有空位。写入结果通过 AsyncOptions.OnError 报告。
+func (t *Table) AddAsync(values ...interface{}) error {
+	asyncMu.RLock()
+	w := t.async
+	asyncMu.RUnlock()
+	if w == nil {
+		return fmt.Errorf("db: the table (%s) async writer is not started", t.TbName)
+	}
+	if t.idGen != nil {
+		var err error
+		if values, _, err = t.fillID(values); err != nil {
+			return err
+		}
+	}
+	return w.add(values)
+}
+
+func (w *AsyncWriter) isClosed() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.closed
+}
+
+func (w *AsyncWriter) add(values []interface{}) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	w.queue <- values
+	return nil
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opt.FlushInterval)
+	defer ticker.Stop()
+	batch := make([][]interface{}, 0, w.opt.BatchSize)
+	for {
+		select {
+		case values, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, values)
+			if len(batch) >= w.opt.BatchSize {
+				w.flush(batch)
+				batch = make([][]interface{}, 0, w.opt.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = make([][]interface{}, 0, w.opt.BatchSize)
+			}
+		}
+	}
+}
+
+// 按给出的字段分组，每组一条多行 INSERT
+func (w *AsyncWriter) flush(batch [][]interface{}) {
+	groups := make(map[string][][]interface{})
+	order := make([]string, 0)
+	for _, values := range batch {
+		mask := make([]byte, len(values))
+		for i := range values {
+			if values[i] != nil {
+				mask[i] = '1'
+			} else {
+				mask[i] = '0'
+			}
+		}
+		key := string(mask)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], values)
+	}
+	for _, key := range order {
+		if err := w.insert(groups[key]); err != nil && w.opt.OnError != nil {
+			w.opt.OnError(err, groups[key])
+		}
+	}
+}
+
+func (w *AsyncWriter) insert(rows [][]interface{}) error {
+	t := w.t
+	listcolname := make([]string, 0)
+	for i := range rows[0] {
+		if rows[0][i] != nil {
+			listcolname = append(listcolname, t.Fields[i].FullName)
+		}
+	}
+	marks := make([]string, len(rows))
+	listParam := make([]interface{}, 0, len(rows)*len(listcolname))
+	for r, values := range rows {
+		values = t.formatValues(values)
+		if t.cipher != nil {
+			var err error
+			if values, err = t.cipher.encryptValues(values); err != nil {
+				return err
+			}
+		}
+		listmark := make([]string, 0, len(listcolname))
+		for i := range values {
+			if values[i] != nil {
+				mark, args := valueMark(values[i])
+				listmark = append(listmark, mark)
+				listParam = append(listParam, args...)
+			}
+		}
+		marks[r] = "(" + strings.Join(listmark, ", ") + ")"
+	}
+	_, err := t.exec(fmt.Sprintf("%s (%s) VALUES %s", t.sqlInsert, strings.Join(listcolname, ", "), strings.Join(marks, ", ")), listParam...)
+	return err
+}
+
+// Drain 停止接收新的行，等待队列中的行全部写入
+//
+// ctx 结束时不再等待并返回 ctx.Err()，后台仍会继续写入剩余的行。
+func (w *AsyncWriter) Drain(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+		w.unregister()
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
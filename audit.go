@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 写操作
+const (
+	opInsert = "insert"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+type actorKey struct{}
+
+// WithActor 在上下文中记录操作人，审计记录从中读取
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom 读取上下文中的操作人
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AuditTableSql 审计表的建表语句
+func AuditTableSql(name string) string {
+	return strings.Join([]string{
+		fmt.Sprintf("CREATE TABLE %s (", name),
+		"\t`id` bigint(20) NOT NULL AUTO_INCREMENT,",
+		"\t`tbname` varchar(128) NOT NULL,",
+		"\t`pk` varchar(255) NOT NULL,",
+		"\t`operation` varchar(16) NOT NULL,",
+		"\t`changes` text NULL,",
+		"\t`actor` varchar(255) NOT NULL,",
+		"\t`created` datetime NOT NULL,",
+		"\tPRIMARY KEY (`id`),",
+		"\tKEY `tbname_pk` (`tbname`, `pk`)",
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+	}, "\n")
+}
+
+// EnableAudit 开启审计，之后通过 Add、Update、Del 的修改都会在同一个事务中写入审计表
+//
+// 审计表的结构见 AuditTableSql，操作人通过 WithActor 和 WithContext 传入。
+// auditTable 为空时关闭审计。
+func (t *Table) EnableAudit(auditTable string) {
+	t.audit = auditTable
+}
+
+// 执行写操作，开启审计时同时写入审计记录
+//
+// where 和 whereArgs 用于查出受影响的主键，values 为按字段位置排列的修改值。
+func (t Table) write(op, query string, args []interface{}, where string, whereArgs []interface{}, values []interface{}) (sql.Result, error) {
+	if t.audit == "" {
+		if t.rowCache == nil {
+			return t.exec(query, args...)
+		}
+		return t.writeCached(op, query, args, where, whereArgs, values)
+	}
+	ctx := t.context()
+	//事务中的语句通过 execTx 执行，与 exec 一样经过策略、预算、熔断、日志等检查
+	tx, err := t.sqlDB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var pks []string
+	if op != opInsert && t.PrimaryKey != "" {
+		if pks, err = t.affectedKeys(ctx, tx, where, whereArgs, true); err != nil {
+			return nil, err
+		}
+	}
+	res, err := t.execTx(tx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if op == opInsert {
+		pks = []string{t.insertedKey(res, values)}
+	}
+	var changes []byte
+	if values != nil {
+		data := make(map[string]interface{})
+		for i := range values {
+			if values[i] != nil {
+				data[t.Fields[i].Name] = values[i]
+			}
+		}
+		if changes, err = json.Marshal(data); err != nil {
+			return nil, err
+		}
+	}
+	strSql := fmt.Sprintf("INSERT INTO %s (`tbname`, `pk`, `operation`, `changes`, `actor`, `created`) VALUES (?, ?, ?, ?, ?, ?)", t.audit)
+	now := time.Now()
+	for _, pk := range pks {
+		if _, err = t.execTx(tx, strSql, t.Fullname, pk, op, NullBytes{Bytes: changes, Valid: changes != nil}, ActorFrom(ctx), now); err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	t.trackWrite(t.sqlDB())
+	t.invalidate(pks)
+	return res, nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// 读取将被修改的行的主键，lock 为 true 时加锁
+func (t Table) affectedKeys(ctx context.Context, q querier, where string, args []interface{}, lock bool) ([]string, error) {
+	strSql := fmt.Sprintf("SELECT %s.`%s` FROM %s %s", t.TbName, t.PrimaryKey, t.Fullname, where)
+	if lock {
+		strSql += " FOR UPDATE"
+	}
+	rows, err := q.QueryContext(ctx, strSql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pks := make([]string, 0)
+	for rows.Next() {
+		var pk string
+		if err = rows.Scan(&pk); err != nil {
+			return nil, err
+		}
+		pks = append(pks, pk)
+	}
+	return pks, rows.Err()
+}
+
+// 插入的行的主键，优先使用显式给出的值
+func (t Table) insertedKey(res sql.Result, values []interface{}) string {
+	if i, err := t.indexOf(t.PrimaryKey); err == nil && i < len(values) && values[i] != nil {
+		return fmt.Sprint(values[i])
+	}
+	id, _ := res.LastInsertId()
+	return fmt.Sprint(id)
+}
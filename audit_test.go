@@ -0,0 +1,23 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+// 开启审计后写操作仍然经过只读模式等检查
+func TestAuditedWriteChecksPolicy(t *testing.T) {
+	users := openFake(t, 3)
+	users.EnableAudit("audit")
+	if _, err := users.Add(nil, "name", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	SetReadOnly(true)
+	defer SetReadOnly(false)
+	if _, err := users.Add(nil, "name", int64(1)); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("audited Add in read-only mode = %v, want ErrReadOnly", err)
+	}
+	if _, err := users.Del(int64(1)); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("audited Del in read-only mode = %v, want ErrReadOnly", err)
+	}
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 连接参数
+type dsnConfig struct {
+	username, password string
+	//网络类型，为空时为 tcp，自定义拨号时为注册的名称
+	network      string
+	addr, dbname string
+	//附加的参数，例如 tls=true
+	params url.Values
+}
+
+// 生成连接字符串
+func (c dsnConfig) String() string {
+	network := c.network
+	if network == "" {
+		network = "tcp"
+	}
+	s := fmt.Sprintf("%s:%s@%s(%s)/%s?charset=utf8&parseTime=true", c.username, c.password, network, c.addr, c.dbname) + locationParam() + foundRowsParam()
+	if getProxyMode() != ProxyNone {
+		s += "&interpolateParams=true"
+	}
+	if len(c.params) > 0 {
+		s += "&" + c.params.Encode()
+	}
+	return s
+}
+
+// AuthOptions 令牌认证的选项
+type AuthOptions struct {
+	Username string
+	//建立每个新连接时调用，返回密码或令牌，例如 RDS IAM 的认证令牌
+	Password func(ctx context.Context) (string, error)
+	//自定义拨号，例如 Cloud SQL connector 的 Dialer，为 nil 时使用 TCP
+	Dial func(ctx context.Context, addr string) (net.Conn, error)
+	//TLS 设置，true、skip-verify 或通过 mysql.RegisterTLSConfig 注册的名称，RDS IAM 必须使用 TLS
+	TLS string
+	//以明文发送密码，RDS IAM 认证需要，必须同时使用 TLS
+	Cleartext bool
+}
+
+// 自定义拨号注册的序号
+var dialSeq int64
+
+// OpenAuth 以令牌认证连接 addr（主机:端口）上的数据库
+//
+// 与 Open 使用固定的密码不同，每次建立新连接时调用 opt.Password 取得密码，
+// 适用于定期过期的令牌，例如 15 分钟过期的 RDS IAM 令牌；已建立的连接不受令牌过期影响。
+// 替换连接池的方式与 Open 相同，Reopen 只适用于 Open 打开的连接。
+func OpenAuth(addr, databasename string, opt AuthOptions) error {
+	if opt.Password == nil {
+		return errors.New("db: the password callback is required")
+	}
+	cfg := dsnConfig{username: opt.Username, addr: addr, dbname: databasename, params: url.Values{}}
+	if opt.Dial != nil {
+		cfg.network = fmt.Sprintf("db-dial-%d", atomic.AddInt64(&dialSeq, 1))
+		mysql.RegisterDialContext(cfg.network, opt.Dial)
+	}
+	if opt.TLS != "" {
+		cfg.params.Set("tls", opt.TLS)
+	}
+	if opt.Cleartext {
+		cfg.params.Set("allowCleartextPasswords", "true")
+	}
+	drv, err := driverContext()
+	if err != nil {
+		return err
+	}
+	sqldb := openConnector(&authConnector{drv: drv, cfg: cfg, password: opt.Password})
+	if err = sqldb.Ping(); err != nil {
+		sqldb.Close()
+		return err
+	}
+	setConn(sqldb, databasename)
+	setOpenParams("", "", "")
+	return nil
+}
+
+// 建立连接时取得密码
+type authConnector struct {
+	drv      driver.DriverContext
+	cfg      dsnConfig
+	password func(ctx context.Context) (string, error)
+}
+
+func (c *authConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.password(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("db: get password: %w", err)
+	}
+	cfg := c.cfg
+	cfg.password = password
+	connector, err := c.drv.OpenConnector(cfg.String())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *authConnector) Driver() driver.Driver {
+	return c.drv.(driver.Driver)
+}
+
+// MySQL 驱动
+func driverContext() (driver.DriverContext, error) {
+	sqldb, err := sql.Open("mysql", "")
+	if err != nil {
+		return nil, err
+	}
+	defer sqldb.Close()
+	drv, ok := sqldb.Driver().(driver.DriverContext)
+	if !ok {
+		return nil, fmt.Errorf("db: the driver does not support connectors")
+	}
+	return drv, nil
+}
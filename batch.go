@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Pipeline 排队执行的一组语句
+type Pipeline struct {
+	stmts []batchStmt
+}
+
+type batchStmt struct {
+	query string
+	args  []interface{}
+}
+
+// Batch 创建一组排队执行的语句，调用 Flush 时在一个事务中执行
+func Batch() *Pipeline {
+	return &Pipeline{stmts: make([]batchStmt, 0)}
+}
+
+// Exec 把语句加入队列
+func (p *Pipeline) Exec(query string, args ...interface{}) *Pipeline {
+	p.stmts = append(p.stmts, batchStmt{query: query, args: args})
+	return p
+}
+
+// Len 队列中的语句数
+func (p *Pipeline) Len() int {
+	return len(p.stmts)
+}
+
+// Flush 执行并清空队列，返回每条语句的结果
+func (p *Pipeline) Flush() ([]sql.Result, error) {
+	return p.FlushContext(context.Background())
+}
+
+// ErrMergedInsertId 合并执行的 INSERT 中除第一行以外的行没有可靠的自增值
+var ErrMergedInsertId = errors.New("db: the insert id of a merged batch statement is unknown")
+
+// FlushContext 在一个事务中执行队列中的语句
+//
+// 连续的相同的单行 INSERT INTO ... VALUES (...) 合并为一条多行 INSERT，每组一次往返。
+// 合并的语句各自的 RowsAffected 为 1；只有每组第一条语句的 LastInsertId 是服务器返回的值，
+// 其余语句的 LastInsertId 返回 ErrMergedInsertId，需要每行自增值时不要用 Batch 插入。
+//
+// 语句与 Exec 一样经过策略、预算、熔断、日志等检查，只读模式下返回 ErrReadOnly。
+// 任一语句失败时回滚整个事务，返回的错误包含失败语句的序号，队列保持不变以便重试。
+func (p *Pipeline) FlushContext(ctx context.Context) ([]sql.Result, error) {
+	if len(p.stmts) == 0 {
+		return []sql.Result{}, nil
+	}
+	sqldb := connFrom(ctx)
+	tx, err := BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	results := make([]sql.Result, len(p.stmts))
+	for i := 0; i < len(p.stmts); {
+		prefix, tuple, n := p.group(i)
+		if n == 1 {
+			if results[i], err = execTxOn(sqldb, tx, ctx, p.stmts[i].query, p.stmts[i].args...); err != nil {
+				return nil, fmt.Errorf("db: batch statement %d: %w", i, err)
+			}
+			i++
+			continue
+		}
+		args := make([]interface{}, 0, n*len(p.stmts[i].args))
+		for j := i; j < i+n; j++ {
+			args = append(args, p.stmts[j].args...)
+		}
+		query := prefix + strings.TrimSuffix(strings.Repeat(tuple+", ", n), ", ")
+		res, err := execTxOn(sqldb, tx, ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("db: batch statements %d-%d: %w", i, i+n-1, err)
+		}
+		first, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		results[i] = batchResult{id: first, affected: 1}
+		for j := 1; j < n; j++ {
+			results[i+j] = batchResult{id: -1, affected: 1}
+		}
+		i += n
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	p.stmts = p.stmts[:0]
+	return results, nil
+}
+
+// 合并的 INSERT 最多的行数和参数个数
+const (
+	maxBatchRows = 1000
+	maxBatchArgs = 65535
+)
+
+// 从 i 开始可以合并的语句数，以及合并用的 VALUES 之前的部分和值列表
+func (p *Pipeline) group(i int) (string, string, int) {
+	prefix, tuple, ok := splitValues(p.stmts[i].query)
+	if !ok {
+		return "", "", 1
+	}
+	n, args := 1, len(p.stmts[i].args)
+	for j := i + 1; j < len(p.stmts) && n < maxBatchRows; j++ {
+		if p.stmts[j].query != p.stmts[i].query || args+len(p.stmts[j].args) > maxBatchArgs {
+			break
+		}
+		n++
+		args += len(p.stmts[j].args)
+	}
+	return prefix, tuple, n
+}
+
+// 把单行的 INSERT INTO ... VALUES (...) 拆分为 VALUES 之前的部分和值列表，其他语句返回 false
+//
+// INSERT IGNORE、ON DUPLICATE KEY UPDATE 等影响的行数不确定的语句不合并。
+func splitValues(query string) (string, string, bool) {
+	q := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	fields := strings.Fields(q)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "INSERT") || !strings.EqualFold(fields[1], "INTO") {
+		return "", "", false
+	}
+	upper := strings.ToUpper(q)
+	k := strings.LastIndex(upper, "VALUES")
+	if k <= 0 || strings.Contains(upper, "DUPLICATE") || strings.Contains(upper, "SELECT") {
+		return "", "", false
+	}
+	if c := q[k-1]; c != ' ' && c != '\t' && c != '\n' && c != ')' {
+		return "", "", false
+	}
+	tuple := strings.TrimSpace(q[k+len("VALUES"):])
+	if !strings.HasPrefix(tuple, "(") || !strings.HasSuffix(tuple, ")") {
+		return "", "", false
+	}
+	//第一个括号必须在末尾闭合，跳过字符串
+	depth := 0
+	for i := 0; i < len(tuple); i++ {
+		switch c := tuple[i]; c {
+		case '\'', '"', '`':
+			for i++; i < len(tuple) && tuple[i] != c; i++ {
+				if tuple[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i != len(tuple)-1 {
+				return "", "", false
+			}
+		}
+	}
+	if depth != 0 {
+		return "", "", false
+	}
+	return q[:k+len("VALUES")] + " ", tuple, true
+}
+
+// 合并执行的 INSERT 中一条语句的结果，id 为 -1 时自增值未知
+type batchResult struct {
+	id, affected int64
+}
+
+func (r batchResult) LastInsertId() (int64, error) {
+	if r.id < 0 {
+		return 0, ErrMergedInsertId
+	}
+	return r.id, nil
+}
+
+func (r batchResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBatchMergedInsertId(t *testing.T) {
+	openFake(t, 0)
+	p := Batch()
+	for i := 0; i < 3; i++ {
+		p.Exec("INSERT INTO test.users (name, age) VALUES (?, ?)", "u", i)
+	}
+	p.Exec("UPDATE test.users SET age = 1")
+	results, err := p.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := results[0].LastInsertId(); err != nil || id == 0 {
+		t.Fatalf("first merged LastInsertId() = %d, %v", id, err)
+	}
+	for _, r := range results[1:3] {
+		if _, err := r.LastInsertId(); !errors.Is(err, ErrMergedInsertId) {
+			t.Fatalf("merged LastInsertId() error = %v, want ErrMergedInsertId", err)
+		}
+		if n, _ := r.RowsAffected(); n != 1 {
+			t.Fatalf("merged RowsAffected() = %d, want 1", n)
+		}
+	}
+	if p.Len() != 0 {
+		t.Fatalf("Len() after Flush = %d", p.Len())
+	}
+}
+
+func TestBatchReadOnly(t *testing.T) {
+	openFake(t, 0)
+	SetReadOnly(true)
+	defer SetReadOnly(false)
+	p := Batch().Exec("INSERT INTO test.users (name) VALUES (?)", "u")
+	if _, err := p.Flush(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Flush in read-only mode = %v, want ErrReadOnly", err)
+	}
+	if p.Len() != 1 {
+		t.Fatalf("Len() after failed Flush = %d, want 1", p.Len())
+	}
+}
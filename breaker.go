@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrCircuitOpen 熔断器断开，语句没有发往服务器
+var ErrCircuitOpen = errors.New("db: the circuit breaker is open")
+
+// 熔断器的状态
+const (
+	//正常执行语句
+	CircuitClosed int32 = iota
+	//所有语句直接返回 ErrCircuitOpen
+	CircuitOpen
+	//冷却时间已过，允许一条语句探测服务器是否恢复
+	CircuitHalfOpen
+)
+
+// BreakerOptions 熔断器的选项
+type BreakerOptions struct {
+	//连续失败多少次后断开，默认 5
+	Failures int
+	//断开后等待多久再探测，默认 10 秒
+	CoolDown time.Duration
+	//判断错误是否计入失败，默认为连接错误、超时和连接数已满
+	IsFailure func(err error) bool
+	//状态变化时回调，参数为变化前后的状态
+	OnChange func(from, to int32)
+}
+
+type breaker struct {
+	opt BreakerOptions
+
+	mu       sync.Mutex
+	state    int32
+	failures int
+	openedAt time.Time
+	//半开状态下是否已经有探测的语句
+	probing bool
+}
+
+// 按连接池的熔断器，键为 nil 时是 Open 打开的连接池
+var breakers sync.Map
+
+// SetBreaker 给连接池加上熔断器，sqldb 为 nil 时是 Open 打开的连接池，重新连接后仍然有效
+//
+// 连续 Failures 次语句因连接错误或超时失败后断开，CoolDown 内所有语句直接返回 ErrCircuitOpen，
+// 不再占用服务器的连接；之后放行一条语句探测，成功时恢复，失败时再断开一个冷却时间。
+func SetBreaker(sqldb *sql.DB, opt BreakerOptions) {
+	if opt.Failures <= 0 {
+		opt.Failures = 5
+	}
+	if opt.CoolDown <= 0 {
+		opt.CoolDown = 10 * time.Second
+	}
+	if opt.IsFailure == nil {
+		opt.IsFailure = isConnFailure
+	}
+	breakers.Store(sqldb, &breaker{opt: opt})
+}
+
+// RemoveBreaker 去掉连接池上的熔断器
+func RemoveBreaker(sqldb *sql.DB) {
+	breakers.Delete(sqldb)
+}
+
+// BreakerState 连接池上熔断器的状态，没有熔断器时为 CircuitClosed
+func BreakerState(sqldb *sql.DB) int32 {
+	v, ok := breakers.Load(sqldb)
+	if !ok {
+		return CircuitClosed
+	}
+	b := v.(*breaker)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// MySQL 连接数已满和语句执行超时的错误码
+const (
+	errConCount      = 1040
+	errUserConCount  = 1203
+	errExecutionTime = 3024
+)
+
+// 默认计入失败的错误：连接断开、网络错误、超时和连接数已满，客户端主动取消不计入
+func isConnFailure(err error) bool {
+	var ne net.Error
+	var me *mysql.MySQLError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn), errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &ne):
+		return true
+	case errors.As(err, &me):
+		return me.Number == errConCount || me.Number == errUserConCount || me.Number == errExecutionTime
+	}
+	return false
+}
+
+func breakerOf(sqldb *sql.DB) *breaker {
+	if v, ok := breakers.Load(sqldb); ok {
+		return v.(*breaker)
+	}
+	if v, ok := breakers.Load((*sql.DB)(nil)); ok && sqldb == conn() {
+		return v.(*breaker)
+	}
+	return nil
+}
+
+func noFinish(error) {}
+
+// 与 breakerAllow 相同，但 ctx 已经结束时不经过熔断器
+//
+// 被策略、预算或并发限制拒绝的语句通过已经结束的 ctx 返回错误，没有发送到服务器，
+// 不能算作成功，也不能占用半开状态下唯一的探测。
+func breakerAllowCtx(sqldb *sql.DB, ctx context.Context) (func(error), error) {
+	if ctx.Err() != nil {
+		return noFinish, nil
+	}
+	return breakerAllow(sqldb)
+}
+
+// 检查熔断器是否允许执行，语句执行后用返回的函数报告结果
+func breakerAllow(sqldb *sql.DB) (func(error), error) {
+	b := breakerOf(sqldb)
+	if b == nil {
+		return noFinish, nil
+	}
+	b.mu.Lock()
+	from := b.state
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.opt.CoolDown {
+		b.state = CircuitHalfOpen
+	}
+	probe := false
+	switch b.state {
+	case CircuitOpen:
+		b.mu.Unlock()
+		return nil, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			b.changed(from, CircuitHalfOpen)
+			return nil, ErrCircuitOpen
+		}
+		b.probing, probe = true, true
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.changed(from, to)
+	return func(err error) {
+		b.done(err, probe)
+	}, nil
+}
+
+// 记录语句的结果
+func (b *breaker) done(err error, probe bool) {
+	failed := err != nil && b.opt.IsFailure(err)
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case probe:
+		b.probing = false
+		if failed {
+			b.state, b.openedAt = CircuitOpen, time.Now()
+		} else {
+			b.state, b.failures = CircuitClosed, 0
+		}
+	case b.state != CircuitClosed:
+		//断开之前开始执行的语句，结果不影响状态
+	case failed:
+		b.failures++
+		if b.failures >= b.opt.Failures {
+			b.state, b.openedAt, b.failures = CircuitOpen, time.Now(), 0
+		}
+	default:
+		b.failures = 0
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.changed(from, to)
+}
+
+// 状态变化时回调，调用时不持有锁
+func (b *breaker) changed(from, to int32) {
+	if from != to && b.opt.OnChange != nil {
+		b.opt.OnChange(from, to)
+	}
+}
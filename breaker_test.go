@@ -0,0 +1,38 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerIgnoresRejected(t *testing.T) {
+	users := openFake(t, 3)
+	SetBreaker(nil, BreakerOptions{Failures: 1, CoolDown: time.Millisecond})
+	defer RemoveBreaker(nil)
+	b := breakerOf(conn())
+	b.mu.Lock()
+	b.state, b.openedAt = CircuitOpen, time.Now().Add(-time.Second)
+	b.mu.Unlock()
+	//被本地拒绝的语句不能占用探测，也不能让熔断器恢复
+	SetReadOnly(true)
+	if _, err := Exec("UPDATE test.users SET age = 1"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Exec in read-only mode = %v, want ErrReadOnly", err)
+	}
+	SetReadOnly(false)
+	if s := BreakerState(nil); s == CircuitClosed {
+		t.Fatal("a rejected statement closed the circuit")
+	}
+	b.mu.Lock()
+	probing := b.probing
+	b.mu.Unlock()
+	if probing {
+		t.Fatal("a rejected statement is holding the half-open probe")
+	}
+	if _, err := users.Count(); err != nil {
+		t.Fatal(err)
+	}
+	if s := BreakerState(nil); s != CircuitClosed {
+		t.Fatalf("the probe succeeded but the state is %d", s)
+	}
+}
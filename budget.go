@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrQueryBudget 上下文中执行的语句数超出了预算
+var ErrQueryBudget = errors.New("db: the query budget of the context is exceeded")
+
+// QueryBudget 一个上下文（例如一次 HTTP 请求）允许执行的语句数
+type QueryBudget struct {
+	//允许的语句数，小于等于 0 时只计数
+	Max int
+	//第一次超出时调用，n 为当前的语句数，query 为超出的语句；
+	//为 nil 时超出的语句不发往服务器，返回 ErrQueryBudget
+	OnExceed func(n int, query string)
+}
+
+type budgetKey struct{}
+
+type budgetCounter struct {
+	QueryBudget
+	n int64
+}
+
+// WithQueryBudget 在上下文中开始计数，之后经过包内连接池的语句都计入，用于发现 N+1 查询
+//
+//	ctx := db.WithQueryBudget(r.Context(), db.QueryBudget{Max: 50, OnExceed: func(n int, query string) {
+//		log.Printf("too many queries (%d): %s", n, query)
+//	}})
+//	posts := posts.WithContext(ctx)
+func WithQueryBudget(ctx context.Context, b QueryBudget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, &budgetCounter{QueryBudget: b})
+}
+
+// QueryCount 上下文中已经执行的语句数，没有用 WithQueryBudget 开始计数时为 0
+func QueryCount(ctx context.Context) int {
+	if c, ok := ctx.Value(budgetKey{}).(*budgetCounter); ok {
+		return int(atomic.LoadInt64(&c.n))
+	}
+	return 0
+}
+
+// 计数，超出预算且没有回调时返回已经结束的上下文
+func budgetContext(ctx context.Context, query string) context.Context {
+	c, ok := ctx.Value(budgetKey{}).(*budgetCounter)
+	if !ok {
+		return ctx
+	}
+	n := int(atomic.AddInt64(&c.n, 1))
+	if c.Max <= 0 || n <= c.Max {
+		return ctx
+	}
+	if c.OnExceed == nil {
+		return rejectedCtx{Context: ctx, err: ErrQueryBudget}
+	}
+	if n == c.Max+1 {
+		c.OnExceed(n, query)
+	}
+	return ctx
+}
@@ -0,0 +1,297 @@
+package db
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"sync"
+	"time"
+)
+
+func init() {
+	gob.Register(time.Time{})
+}
+
+// Cache 缓存后端
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	//ttl 为 0 时不过期
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+}
+
+// 行缓存设置
+type rowCache struct {
+	cache Cache
+	ttl   time.Duration
+	//不存在的行的缓存时间，0 表示不缓存
+	negative time.Duration
+}
+
+// 缓存的一行
+type cacheEntry struct {
+	Found  bool
+	Values []interface{}
+}
+
+// SetCache 为 GetByID 和只按主键查询的 Get 开启行缓存
+//
+// 缓存按表名和主键保存原始值，通过 Add、Update、Del 修改时自动失效，
+// 绕过本包直接修改数据库不会使缓存失效。negativeTTL 大于 0 时，
+// 不存在的行也会缓存这么长时间。c 为 nil 时关闭缓存。
+func (t *Table) SetCache(c Cache, ttl, negativeTTL time.Duration) {
+	if c == nil {
+		t.rowCache = nil
+		return
+	}
+	t.rowCache = &rowCache{cache: c, ttl: ttl, negative: negativeTTL}
+}
+
+func (t Table) cacheKey(id interface{}) string {
+	return fmt.Sprintf("%s:%v", t.Fullname, id)
+}
+
+// 是否只给出了主键
+func (t Table) onlyPrimaryKey(args []interface{}) (interface{}, bool) {
+	if t.rowCache == nil {
+		return nil, false
+	}
+	pk, err := t.indexOf(t.PrimaryKey)
+	if err != nil || pk >= len(args) || args[pk] == nil {
+		return nil, false
+	}
+	for i := range args {
+		if i != pk && args[i] != nil {
+			return nil, false
+		}
+	}
+	return args[pk], true
+}
+
+// GetByID 按主键查询一行，开启行缓存时优先读取缓存
+func (t *Table) GetByID(id interface{}) *Row {
+	if t.PrimaryKey == "" {
+		return &Row{t: t, err: fmt.Errorf("db: the table (%s) has no primary key", t.TbName)}
+	}
+	if t.rowCache != nil {
+		key := t.cacheKey(id)
+		if buf, ok := t.rowCache.cache.Get(key); ok {
+			var entry cacheEntry
+			if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&entry); err == nil {
+				if !entry.Found {
+					return &Row{t: t, err: sql.ErrNoRows}
+				}
+				return &Row{t: t, src: valuesScanner(entry.Values)}
+			}
+		}
+		strSql := fmt.Sprintf("%s WHERE %s.`%s`=? limit 1", t.sqlSelect, t.TbName, t.PrimaryKey)
+		r := t.row(strSql, id)
+		if r.err == nil {
+			r.src = &recordingScanner{s: r.source(), c: t.rowCache, key: key}
+		}
+		return r
+	}
+	strSql := fmt.Sprintf("%s WHERE %s.`%s`=? limit 1", t.sqlSelect, t.TbName, t.PrimaryKey)
+	return t.row(strSql, id)
+}
+
+func (r *Row) source() scanner {
+	if r.src != nil {
+		return r.src
+	}
+	return r.Row
+}
+
+// 从缓存的值读取
+type valuesScanner []interface{}
+
+func (v valuesScanner) Scan(dest ...interface{}) error {
+	if len(dest) != len(v) {
+		return fmt.Errorf("db: the cached row has %d values, expect %d", len(v), len(dest))
+	}
+	for i := range dest {
+		if err := dest[i].(sql.Scanner).Scan(v[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 读取数据库的同时写入缓存
+type recordingScanner struct {
+	s   scanner
+	c   *rowCache
+	key string
+}
+
+func (r *recordingScanner) Scan(dest ...interface{}) error {
+	err := r.s.Scan(dest...)
+	if err == sql.ErrNoRows {
+		if r.c.negative > 0 {
+			r.c.store(r.key, cacheEntry{}, r.c.negative)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(dest))
+	for i := range dest {
+		values[i] = parseValue(dest[i])
+	}
+	r.c.store(r.key, cacheEntry{Found: true, Values: values}, r.c.ttl)
+	return nil
+}
+
+func (c *rowCache) store(key string, entry cacheEntry, ttl time.Duration) {
+	var buf bytes.Buffer
+	if gob.NewEncoder(&buf).Encode(entry) == nil {
+		c.cache.Set(key, buf.Bytes(), ttl)
+	}
+}
+
+// 使缓存失效
+func (t Table) invalidate(pks []string) {
+	if t.rowCache == nil {
+		return
+	}
+	for _, pk := range pks {
+		t.rowCache.cache.Delete(t.cacheKey(pk))
+	}
+}
+
+// 开启缓存且未开启审计时的写操作
+func (t Table) writeCached(op, query string, args []interface{}, where string, whereArgs []interface{}, values []interface{}) (sql.Result, error) {
+	var pks []string
+	var err error
+	if op != opInsert && t.PrimaryKey != "" {
+		if pks, err = t.affectedKeys(t.context(), t.sqlDB(), where, whereArgs, false); err != nil {
+			return nil, err
+		}
+	}
+	res, err := t.exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if op == opInsert {
+		pks = []string{t.insertedKey(res, values)}
+	}
+	t.invalidate(pks)
+	return res, nil
+}
+
+// LRUCache 进程内的 LRU 缓存
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache 创建最多保存 size 项的 LRU 缓存
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := e.Value.(*lruItem)
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		c.ll.Remove(e)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return item.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if e, ok := c.items[key]; ok {
+		e.Value = &lruItem{key: key, value: value, expires: expires}
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruItem{key: key, value: value, expires: expires})
+	for c.size > 0 && c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*lruItem).key)
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// RedisClient Redis 客户端需要提供的操作，可以很容易地包装 go-redis 等客户端
+//
+// 键不存在时 Get 应返回 ok 为 false。
+type RedisClient interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+}
+
+// RedisCache 基于 Redis 的缓存，出错时视为未命中
+type RedisCache struct {
+	client RedisClient
+	prefix string
+	//每个操作的超时时间
+	Timeout time.Duration
+}
+
+// NewRedisCache 创建 Redis 缓存，所有键加上 prefix 前缀
+func NewRedisCache(client RedisClient, prefix string) *RedisCache {
+	return &RedisCache{client: client, prefix: prefix, Timeout: 100 * time.Millisecond}
+}
+
+func (c *RedisCache) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.Timeout)
+}
+
+func (c *RedisCache) Get(key string) ([]byte, bool) {
+	ctx, cancel := c.context()
+	defer cancel()
+	value, ok, err := c.client.Get(ctx, c.prefix+key)
+	if err != nil {
+		return nil, false
+	}
+	return value, ok
+}
+
+func (c *RedisCache) Set(key string, value []byte, ttl time.Duration) {
+	ctx, cancel := c.context()
+	defer cancel()
+	c.client.Set(ctx, c.prefix+key, value, ttl)
+}
+
+func (c *RedisCache) Delete(key string) {
+	ctx, cancel := c.context()
+	defer cancel()
+	c.client.Del(ctx, c.prefix+key)
+}
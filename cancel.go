@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var killOnCancel int32
+
+// SetKillOnCancel 打开或关闭取消查询时终止服务器上的语句
+//
+// 上下文取消后驱动只断开连接，服务器上正在执行的查询（例如大表导出、排序）仍会继续，
+// 直到发送结果时才发现连接已断开。打开后可以取消的查询在固定的连接上执行，
+// 执行前读取 CONNECTION_ID()，上下文取消时通过另一个连接执行 KILL QUERY。
+// 每个查询多一次往返，代理模式下不生效。
+func SetKillOnCancel(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&killOnCancel, v)
+}
+
+// 是否需要在取消时终止服务器上的语句
+func killable(ctx context.Context) bool {
+	return ctx.Done() != nil && atomic.LoadInt32(&killOnCancel) == 1 && getProxyMode() == ProxyNone
+}
+
+// 在固定的连接上查询，结果集关闭后归还连接并调用 done，kill 为 true 时上下文取消后终止服务器上的语句
+//
+// 终止语句时连接必须仍被占用，否则 KILL QUERY 可能落在归还后执行其他语句的连接上。
+// 所以 kill 为 true 时语句在不随 ctx 取消的上下文上执行，ctx 取消后先执行 KILL QUERY，
+// 再取消语句的上下文。Rows.Close 关闭结果集之前也会先做同样的处理，见 settleKill。
+func queryPinned(sqldb *sql.DB, ctx context.Context, kill bool, done func(), query string, args ...interface{}) (*sql.Rows, error) {
+	if done == nil {
+		done = func() {}
+	}
+	c, err := sqldb.Conn(ctx)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	if !kill {
+		rows, err := c.QueryContext(ctx, query, args...)
+		if err != nil {
+			c.Close()
+			done()
+			return nil, err
+		}
+		go func() {
+			//结果集关闭之后 Close 才返回
+			c.Close()
+			done()
+		}()
+		return rows, nil
+	}
+	var id int64
+	if err = c.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		c.Close()
+		done()
+		return nil, err
+	}
+	queryCtx, cancelQuery := context.WithCancel(detachedContext{ctx})
+	released := make(chan struct{})
+	var once sync.Once
+	settle := func() {
+		if ctx.Err() == nil {
+			return
+		}
+		once.Do(func() {
+			//连接已经归还时不能再终止
+			select {
+			case <-released:
+			default:
+				killQuery(sqldb, id)
+			}
+			cancelQuery()
+		})
+	}
+	go func() {
+		select {
+		case <-released:
+		case <-ctx.Done():
+			settle()
+		}
+	}()
+	rows, err := c.QueryContext(queryCtx, query, args...)
+	if err != nil {
+		c.Close()
+		close(released)
+		cancelQuery()
+		done()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+	killers.Store(rows, settle)
+	go func() {
+		c.Close()
+		killers.Delete(rows)
+		close(released)
+		cancelQuery()
+		done()
+	}()
+	return rows, nil
+}
+
+// 可以终止语句的结果集，值为 queryPinned 中的 settle
+var killers sync.Map
+
+// 关闭结果集之前调用，ctx 已经取消时先终止服务器上的语句
+//
+// 只在结果集还没有读完时调用，读完后连接随时可能归还，应调用 forgetKill。
+func settleKill(rows *sql.Rows) {
+	if settle, ok := killers.Load(rows); ok {
+		settle.(func())()
+	}
+}
+
+// 结果集已经读完，不再需要终止语句
+func forgetKill(rows *sql.Rows) {
+	killers.Delete(rows)
+}
+
+// 保留 ctx 中的值，但不随 ctx 取消
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+// 在固定的连接上查询一行，读取完成后归还连接并调用 done
+func queryRowPinned(sqldb *sql.DB, ctx context.Context, done func(), query string, args ...interface{}) *sql.Row {
+	c, err := sqldb.Conn(ctx)
+	if err != nil {
+		done()
+		return sqldb.QueryRowContext(rejectedCtx{Context: ctx, err: err}, query, args...)
+	}
+	row := c.QueryRowContext(ctx, query, args...)
+	go func() {
+		//Scan 之后 Close 才返回
+		c.Close()
+		done()
+	}()
+	return row
+}
+
+// 在另一个连接上终止语句，错误忽略
+func killQuery(sqldb *sql.DB, id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sqldb.ExecContext(ctx, fmt.Sprintf("KILL QUERY %d", id))
+}
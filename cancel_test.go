@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 读取中途取消时停止读取、关闭结果集，Err 返回 ctx.Err()
+func TestRowsStopOnCancel(t *testing.T) {
+	users := openFake(t, 1<<40)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rs, err := users.WithContext(ctx).GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for rs.Next() {
+		if n++; n == 10 {
+			cancel()
+		}
+		if n > 1000 {
+			t.Fatal("Next kept returning rows after the context was cancelled")
+		}
+	}
+	if !errors.Is(rs.Err(), context.Canceled) {
+		t.Fatalf("Err() = %v, want context.Canceled", rs.Err())
+	}
+	if !eventually(func() bool { return atomic.LoadInt64(&fake.open) == 0 }) {
+		t.Fatal("the result set was not closed after cancellation")
+	}
+}
+
+func TestForEachStopOnCancel(t *testing.T) {
+	users := openFake(t, 1<<40)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rs, err := users.WithContext(ctx).GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	err = rs.ForEach(func(rs *Rows) error {
+		if n++; n == 10 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ForEach() = %v, want context.Canceled", err)
+	}
+	if n > 1000 {
+		t.Fatalf("ForEach read %d rows after the context was cancelled", n)
+	}
+	if !eventually(func() bool { return atomic.LoadInt64(&fake.open) == 0 }) {
+		t.Fatal("the result set was not closed after cancellation")
+	}
+}
+
+// 打开 SetKillOnCancel 后取消时在另一个连接上终止执行查询的连接上的语句
+func TestKillOnCancel(t *testing.T) {
+	SetKillOnCancel(true)
+	defer SetKillOnCancel(false)
+	users := openFake(t, 1<<40)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rs, err := users.WithContext(ctx).GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Next()
+	cancel()
+	for rs.Next() {
+	}
+	if !eventually(func() bool { return len(fakeKills()) == 1 }) {
+		t.Fatalf("kills = %v, want one KILL QUERY", fakeKills())
+	}
+	if !errors.Is(rs.Err(), context.Canceled) {
+		t.Fatalf("Err() = %v, want context.Canceled", rs.Err())
+	}
+}
+
+// 结果集关闭、连接归还之后再取消不能终止其他语句
+func TestNoKillAfterRelease(t *testing.T) {
+	SetKillOnCancel(true)
+	defer SetKillOnCancel(false)
+	users := openFake(t, 3)
+	for i := 0; i < 50; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		rs, err := users.WithContext(ctx).GetMany()
+		if err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+		for rs.Next() {
+		}
+		if err = rs.Err(); err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+		//连接归还之后再取消，终止语句的 goroutine 可能同时看到两个信号
+		if !eventually(func() bool { return conn().Stats().InUse == 0 }) {
+			cancel()
+			t.Fatal("the connection was not released after the result set was closed")
+		}
+		cancel()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if kills := fakeKills(); len(kills) != 0 {
+		t.Fatalf("kills = %v after the connections were released", kills)
+	}
+}
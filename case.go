@@ -0,0 +1,120 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CaseBuilder 生成 CASE 表达式
+type CaseBuilder struct {
+	column string
+	whens  []string
+	args   []interface{}
+	els    *Expression
+}
+
+// Case 按字段的取值选择结果，column 为 Sql 中的字段名，例如 `id`
+//
+//	db.Case("`id`").When(1, "a").When(2, "b").Else(db.Expr("`name`")).Expr()
+func Case(column string) *CaseBuilder {
+	return &CaseBuilder{column: column}
+}
+
+// When 字段等于 value 时取 then，then 可以是 Expression
+func (c *CaseBuilder) When(value, then interface{}) *CaseBuilder {
+	mark, args := valueMark(then)
+	c.whens = append(c.whens, "WHEN ? THEN "+mark)
+	c.args = append(append(c.args, value), args...)
+	return c
+}
+
+// Else 没有匹配时的结果，不设置时为 NULL
+func (c *CaseBuilder) Else(value interface{}) *CaseBuilder {
+	mark, args := valueMark(value)
+	c.els = &Expression{Sql: mark, Args: args}
+	return c
+}
+
+// Expr 生成 Sql 片段，可以作为 Add 和 Setter.Values 的值
+func (c *CaseBuilder) Expr() Expression {
+	query := "CASE " + c.column + " " + strings.Join(c.whens, " ")
+	args := append([]interface{}(nil), c.args...)
+	if c.els != nil {
+		query += " ELSE " + c.els.Sql
+		args = append(args, c.els.Args...)
+	}
+	return Expression{Sql: query + " END", Args: args}
+}
+
+// BulkUpdate 用一条 UPDATE 按 column 的取值修改多行，rows 的键为 column 的值，值与 Setter.Values 相同
+//
+// 每个字段生成一个 CASE 表达式，没有给出该字段的行保持原值，例如
+//
+//	UPDATE t SET `name` = CASE `id` WHEN 1 THEN 'a' WHEN 2 THEN 'b' ELSE `name` END WHERE `id` IN (1, 2)
+//
+// 比逐行修改快得多。行数很多时应分批调用，避免语句超过 max_allowed_packet。
+func (t *Table) BulkUpdate(column string, rows map[interface{}][]interface{}) (int64, error) {
+	k, err := t.indexOf(column)
+	if err != nil {
+		return -1, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	//按键排序，同样的输入生成同样的语句
+	keys := make([]interface{}, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	cases := make([]*CaseBuilder, t.Len)
+	for _, key := range keys {
+		values := rows[key]
+		if len(values) > t.Len {
+			return -1, fmt.Errorf("db: the values numbers (%d) more than table column numbers (%d)", len(values), t.Len)
+		}
+		if t.validate {
+			if err = t.Validate(false, values...); err != nil {
+				return -1, err
+			}
+		}
+		values = t.formatValues(values)
+		if t.cipher != nil {
+			if values, err = t.cipher.encryptValues(values); err != nil {
+				return -1, err
+			}
+		}
+		for i := range values {
+			if values[i] == nil {
+				continue
+			}
+			if cases[i] == nil {
+				cases[i] = Case(t.Fields[k].FullName).Else(Expr(t.Fields[i].FullName))
+			}
+			cases[i].When(key, values[i])
+		}
+	}
+	listkey := make([]string, 0)
+	listvalue := make([]interface{}, 0)
+	for i := range cases {
+		if cases[i] == nil {
+			continue
+		}
+		e := cases[i].Expr()
+		listkey = append(listkey, t.Fields[i].FullName+"="+e.Sql)
+		listvalue = append(listvalue, e.Args...)
+	}
+	if len(listkey) == 0 {
+		return 0, nil
+	}
+	where := fmt.Sprintf("WHERE %s IN (%s)", t.Fields[k].FullName, strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", "))
+	strSql := fmt.Sprintf("%s SET %s %s", t.sqlUpdate, strings.Join(listkey, ", "), where)
+	res, err := t.write(opUpdate, strSql, append(listvalue, keys...), where, keys, nil)
+	if err != nil {
+		return -1, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,764 @@
+package cdc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BinlogConfig 以复制客户端的身份读取 binlog 的参数
+//
+// 用户需要 REPLICATION SLAVE 和 REPLICATION CLIENT 权限，服务器需要 binlog_format=ROW，
+// binlog_row_image 不为 FULL 时修改前后未记录的字段为 nil。
+type BinlogConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	//复制客户端的 server_id，不能与副本和其他客户端相同
+	ServerID uint32
+	//开始读取的位置，File 为空时从服务器当前的位置开始，GTID 被忽略
+	Position Position
+	//连接超时，默认 10 秒
+	DialTimeout time.Duration
+}
+
+// BinlogSource 通过复制协议读取 binlog 的 Source
+//
+// 事件的 Position 为所在事务开始前的位置，从该位置重新开始时整个事务会再次读到。
+// 读取出错后下一次 Next 从最后一个完整读取的事务之后重新连接。
+type BinlogSource struct {
+	cfg BinlogConfig
+
+	mu     sync.Mutex
+	conn   *binlogConn
+	closed bool
+
+	//已完整读取的事务之后的位置
+	committed Position
+	//当前事务的 GTID
+	gtid     string
+	checksum bool
+	tables   map[uint64]*tableMap
+}
+
+// NewBinlogSource 创建 binlog 来源，第一次 Next 时连接
+func NewBinlogSource(cfg BinlogConfig) *BinlogSource {
+	if cfg.Port == 0 {
+		cfg.Port = 3306
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 10 * time.Second
+	}
+	return &BinlogSource{cfg: cfg, committed: cfg.Position, tables: make(map[uint64]*tableMap)}
+}
+
+var errSourceClosed = errors.New("cdc: the binlog source is closed")
+
+// Next 读取下一个行事件
+func (s *BinlogSource) Next(ctx context.Context) (*RowsEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := s.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	//ctx 取消时中断阻塞的读取
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	defer func() {
+		close(done)
+		<-exited
+		conn.SetReadDeadline(time.Time{})
+	}()
+	for {
+		data, err := conn.readPacket()
+		if err == nil {
+			switch {
+			case len(data) == 0:
+				err = errMalformed
+			case data[0] == 0xff:
+				err = conn.error(data)
+			case data[0] == 0xfe && len(data) < 9:
+				err = errors.New("cdc: the server closed the binlog stream")
+			}
+		}
+		if err != nil {
+			s.reset(conn)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		event, err := s.parseEvent(data[1:])
+		if err != nil {
+			s.reset(conn)
+			return nil, err
+		}
+		if event != nil {
+			return event, nil
+		}
+	}
+}
+
+// Close 断开连接，之后 Next 返回错误
+func (s *BinlogSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Position 最后一个完整读取的事务之后的位置，可以保存下来作为下次的 BinlogConfig.Position
+func (s *BinlogSource) Position() Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.committed
+}
+
+// 关闭出错的连接，下次 Next 重新连接
+func (s *BinlogSource) reset(conn *binlogConn) {
+	s.mu.Lock()
+	if s.conn == conn {
+		s.conn = nil
+	}
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// 取得连接，没有时连接并开始读取 binlog
+func (s *BinlogSource) connection(ctx context.Context) (*binlogConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errSourceClosed
+	}
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	dialer := net.Dialer{Timeout: s.cfg.DialTimeout}
+	nc, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port)))
+	if err != nil {
+		return nil, err
+	}
+	conn := &binlogConn{Conn: nc, r: bufio.NewReaderSize(nc, 64*1024)}
+	//握手和开始复制不能超过连接超时
+	conn.SetDeadline(time.Now().Add(s.cfg.DialTimeout))
+	if err = s.start(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	s.conn = conn
+	s.checksum = false
+	s.gtid = ""
+	s.tables = make(map[uint64]*tableMap)
+	return conn, nil
+}
+
+func (s *BinlogSource) start(conn *binlogConn) error {
+	if err := conn.handshake(s.cfg.User, s.cfg.Password); err != nil {
+		return err
+	}
+	//告诉服务器客户端可以处理校验和，事件按服务器的设置带或不带 CRC32
+	if _, err := conn.query("SET @master_binlog_checksum = 'NONE', @source_binlog_checksum = 'NONE'"); err != nil {
+		return err
+	}
+	if s.committed.File == "" {
+		//8.2 起为 SHOW BINARY LOG STATUS，8.4 删除了 SHOW MASTER STATUS
+		rows, err := conn.query("SHOW BINARY LOG STATUS")
+		if err != nil {
+			rows, err = conn.query("SHOW MASTER STATUS")
+		}
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 || len(rows[0]) < 2 {
+			return errors.New("cdc: the binary log is not enabled")
+		}
+		offset, err := strconv.ParseUint(rows[0][1], 10, 32)
+		if err != nil {
+			return err
+		}
+		s.committed = Position{File: rows[0][0], Offset: uint32(offset)}
+	}
+	offset := s.committed.Offset
+	if offset < 4 {
+		offset = 4
+	}
+	//COM_BINLOG_DUMP
+	buf := []byte{0x12}
+	buf = binary.LittleEndian.AppendUint32(buf, offset)
+	buf = binary.LittleEndian.AppendUint16(buf, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, s.cfg.ServerID)
+	buf = append(buf, s.committed.File...)
+	conn.seq = 0
+	return conn.writePacket(buf)
+}
+
+// binlog 事件的类型
+const (
+	eventQuery             = 2
+	eventRotate            = 4
+	eventFormatDescription = 15
+	eventXid               = 16
+	eventTableMap          = 19
+	eventWriteRowsV1       = 23
+	eventUpdateRowsV1      = 24
+	eventDeleteRowsV1      = 25
+	eventWriteRowsV2       = 30
+	eventUpdateRowsV2      = 31
+	eventDeleteRowsV2      = 32
+	eventGtid              = 33
+)
+
+// 解析一个事件，不是行事件时返回 nil
+func (s *BinlogSource) parseEvent(data []byte) (*RowsEvent, error) {
+	if len(data) < 19 {
+		return nil, errMalformed
+	}
+	typ := data[4]
+	next := binary.LittleEndian.Uint32(data[13:])
+	body := data[19:]
+	if s.checksum && typ != eventFormatDescription {
+		if len(body) < 4 {
+			return nil, errMalformed
+		}
+		body = body[:len(body)-4]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch typ {
+	case eventRotate:
+		if len(body) < 8 {
+			return nil, errMalformed
+		}
+		s.committed = Position{File: string(body[8:]), Offset: uint32(binary.LittleEndian.Uint64(body)), GTID: s.committed.GTID}
+		s.tables = make(map[uint64]*tableMap)
+	case eventFormatDescription:
+		//5.6.1 起末尾为 1 字节的校验算法和 4 字节的校验和
+		if len(body) < 57 {
+			return nil, errMalformed
+		}
+		version := string(bytes.TrimRight(body[2:52], "\x00"))
+		s.checksum = versionAtLeast(version, 5, 6, 1) && body[len(body)-5] == 1
+	case eventGtid:
+		if len(body) < 25 {
+			return nil, errMalformed
+		}
+		sid := hex.EncodeToString(body[1:17])
+		s.gtid = fmt.Sprintf("%s-%s-%s-%s-%s:%d", sid[:8], sid[8:12], sid[12:16], sid[16:20], sid[20:], binary.LittleEndian.Uint64(body[17:25]))
+	case eventQuery:
+		//BEGIN 之外的语句（DDL 和非事务的语句）单独提交
+		if q := queryText(body); q != "BEGIN" && next > 0 {
+			s.commit(next)
+		}
+	case eventXid:
+		if next > 0 {
+			s.commit(next)
+		}
+	case eventTableMap:
+		tm, err := parseTableMap(body)
+		if err != nil {
+			return nil, err
+		}
+		s.tables[tm.id] = tm
+	case eventWriteRowsV1, eventUpdateRowsV1, eventDeleteRowsV1, eventWriteRowsV2, eventUpdateRowsV2, eventDeleteRowsV2:
+		return s.rowsEvent(typ, body)
+	}
+	return nil, nil
+}
+
+func (s *BinlogSource) commit(next uint32) {
+	s.committed = Position{File: s.committed.File, Offset: next, GTID: s.gtid}
+}
+
+// QUERY_EVENT 中的语句
+func queryText(body []byte) string {
+	//thread id、执行时间、库名长度、错误码、状态变量长度
+	if len(body) < 13 {
+		return ""
+	}
+	dbLen := int(body[8])
+	start := 13 + int(binary.LittleEndian.Uint16(body[11:])) + dbLen + 1
+	if start > len(body) {
+		return ""
+	}
+	return string(body[start:])
+}
+
+// 解析行事件，Update 时前后镜像交替排列
+func (s *BinlogSource) rowsEvent(typ byte, body []byte) (*RowsEvent, error) {
+	r := &reader{buf: body}
+	id := r.uint(6)
+	flags := r.uint(2)
+	if typ >= eventWriteRowsV2 {
+		r.next(int(r.uint(2)) - 2)
+	}
+	n := int(r.lenenc())
+	present := r.next((n + 7) / 8)
+	update := typ == eventUpdateRowsV1 || typ == eventUpdateRowsV2
+	presentAfter := present
+	if update {
+		presentAfter = r.next((n + 7) / 8)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	tm := s.tables[id]
+	if tm == nil {
+		return nil, fmt.Errorf("cdc: the table map of table id %d is missing", id)
+	}
+	if n != len(tm.types) {
+		return nil, fmt.Errorf("cdc: the rows event of %s.%s has %d columns, the table map has %d", tm.schema, tm.table, n, len(tm.types))
+	}
+	e := &RowsEvent{Schema: tm.schema, Table: tm.table, Position: Position{File: s.committed.File, Offset: s.committed.Offset, GTID: s.gtid}}
+	switch typ {
+	case eventWriteRowsV1, eventWriteRowsV2:
+		e.Action = Insert
+	case eventUpdateRowsV1, eventUpdateRowsV2:
+		e.Action = Update
+	default:
+		e.Action = Delete
+	}
+	for r.pos < len(r.buf) && r.err == nil {
+		row, err := tm.decodeRow(r, present)
+		if err != nil {
+			return nil, err
+		}
+		e.Rows = append(e.Rows, row)
+		if update {
+			if row, err = tm.decodeRow(r, presentAfter); err != nil {
+				return nil, err
+			}
+			e.Rows = append(e.Rows, row)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	//语句结束时释放表映射
+	if flags&1 != 0 {
+		s.tables = make(map[uint64]*tableMap)
+	}
+	return e, nil
+}
+
+// 版本号是否不低于 major.minor.patch
+func versionAtLeast(version string, major, minor, patch int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	want := []int{major, minor, patch}
+	for i := range want {
+		if i >= len(parts) {
+			return false
+		}
+		digits := parts[i]
+		for j := range digits {
+			if digits[j] < '0' || digits[j] > '9' {
+				digits = digits[:j]
+				break
+			}
+		}
+		v, _ := strconv.Atoi(digits)
+		if v != want[i] {
+			return v > want[i]
+		}
+	}
+	return true
+}
+
+var errMalformed = errors.New("cdc: malformed packet")
+
+// 复制协议的连接
+type binlogConn struct {
+	net.Conn
+	r   *bufio.Reader
+	seq byte
+}
+
+// 读取一个数据包，合并超过 16MB 时拆分的包
+func (c *binlogConn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(c.r, header[:]); err != nil {
+			return nil, err
+		}
+		n := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		c.seq = header[3] + 1
+		start := len(payload)
+		payload = append(payload, make([]byte, n)...)
+		if _, err := io.ReadFull(c.r, payload[start:]); err != nil {
+			return nil, err
+		}
+		if n < 0xffffff {
+			return payload, nil
+		}
+	}
+}
+
+func (c *binlogConn) writePacket(data []byte) error {
+	for {
+		n := len(data)
+		if n > 0xffffff {
+			n = 0xffffff
+		}
+		packet := make([]byte, 4, 4+n)
+		packet[0], packet[1], packet[2], packet[3] = byte(n), byte(n>>8), byte(n>>16), c.seq
+		c.seq++
+		if _, err := c.Write(append(packet, data[:n]...)); err != nil {
+			return err
+		}
+		data = data[n:]
+		if n < 0xffffff {
+			return nil
+		}
+	}
+}
+
+// ERR 包转换为错误
+func (c *binlogConn) error(data []byte) error {
+	if len(data) < 3 {
+		return errMalformed
+	}
+	code := binary.LittleEndian.Uint16(data[1:])
+	msg := data[3:]
+	if len(msg) >= 6 && msg[0] == '#' {
+		msg = msg[6:]
+	}
+	return fmt.Errorf("cdc: mysql error %d: %s", code, msg)
+}
+
+// 客户端能力标志
+const (
+	clientLongPassword  = 0x1
+	clientLongFlag      = 0x4
+	clientProtocol41    = 0x200
+	clientTransactions  = 0x2000
+	clientSecureConn    = 0x8000
+	clientMultiResults  = 0x20000
+	clientPluginAuth    = 0x80000
+	defaultAuthPlugin   = "mysql_native_password"
+	cachingSha2Password = "caching_sha2_password"
+)
+
+// 握手和认证，支持 mysql_native_password 和 caching_sha2_password
+func (c *binlogConn) handshake(user, password string) error {
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[0] == 0xff {
+		return c.error(data)
+	}
+	r := &reader{buf: data}
+	if r.uint(1) != 10 {
+		return errors.New("cdc: unsupported protocol version")
+	}
+	r.string()
+	r.next(4)
+	scramble := append([]byte{}, r.next(8)...)
+	r.next(1)
+	caps := r.uint(2)
+	plugin := defaultAuthPlugin
+	if r.pos < len(r.buf) {
+		r.next(3)
+		caps |= r.uint(2) << 16
+		authLen := int(r.uint(1))
+		r.next(10)
+		if caps&clientSecureConn != 0 {
+			n := authLen - 8
+			if n < 13 {
+				n = 13
+			}
+			scramble = append(scramble, bytes.TrimRight(r.next(n), "\x00")...)
+		}
+		if caps&clientPluginAuth != 0 && r.pos < len(r.buf) {
+			plugin = r.string()
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	auth, err := scrambleFor(plugin, scramble, password)
+	if err != nil {
+		return err
+	}
+	buf := binary.LittleEndian.AppendUint32(nil, clientLongPassword|clientLongFlag|clientProtocol41|clientTransactions|clientSecureConn|clientMultiResults|clientPluginAuth)
+	buf = binary.LittleEndian.AppendUint32(buf, 0xffffff)
+	//utf8mb4_general_ci
+	buf = append(buf, 45)
+	buf = append(buf, make([]byte, 23)...)
+	buf = append(append(buf, user...), 0)
+	buf = append(append(buf, byte(len(auth))), auth...)
+	buf = append(append(buf, plugin...), 0)
+	if err = c.writePacket(buf); err != nil {
+		return err
+	}
+	return c.authResult(plugin, scramble, password)
+}
+
+// 读取认证结果，处理认证方式切换和 caching_sha2_password 的完整认证
+func (c *binlogConn) authResult(plugin string, scramble []byte, password string) error {
+	for {
+		data, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return errMalformed
+		}
+		switch data[0] {
+		case 0x00:
+			return nil
+		case 0xff:
+			return c.error(data)
+		case 0xfe:
+			r := &reader{buf: data[1:]}
+			plugin = r.string()
+			scramble = bytes.TrimRight(r.buf[r.pos:], "\x00")
+			if r.err != nil {
+				return r.err
+			}
+			auth, err := scrambleFor(plugin, scramble, password)
+			if err != nil {
+				return err
+			}
+			if err = c.writePacket(auth); err != nil {
+				return err
+			}
+		case 0x01:
+			if plugin != cachingSha2Password || len(data) < 2 {
+				return errMalformed
+			}
+			switch data[1] {
+			case 3:
+				//快速认证成功，之后是 OK 包
+			case 4:
+				if err = c.fullAuth(scramble, password); err != nil {
+					return err
+				}
+			default:
+				return errMalformed
+			}
+		default:
+			return errMalformed
+		}
+	}
+}
+
+// 没有 TLS 时用服务器的公钥加密密码
+func (c *binlogConn) fullAuth(scramble []byte, password string) error {
+	if err := c.writePacket([]byte{2}); err != nil {
+		return err
+	}
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 || data[0] != 1 {
+		return errors.New("cdc: failed to get the server public key")
+	}
+	block, _ := pem.Decode(data[1:])
+	if block == nil {
+		return errors.New("cdc: invalid server public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("cdc: the server public key is not an RSA key")
+	}
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	enc, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, plain, nil)
+	if err != nil {
+		return err
+	}
+	return c.writePacket(enc)
+}
+
+// 按认证方式计算密码的散列
+func scrambleFor(plugin string, scramble []byte, password string) ([]byte, error) {
+	if len(scramble) > 20 {
+		scramble = scramble[:20]
+	}
+	if password == "" {
+		return nil, nil
+	}
+	switch plugin {
+	case defaultAuthPlugin:
+		//SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+		h1 := sha1.Sum([]byte(password))
+		h2 := sha1.Sum(h1[:])
+		h := sha1.New()
+		h.Write(scramble)
+		h.Write(h2[:])
+		out := h.Sum(nil)
+		for i := range out {
+			out[i] ^= h1[i]
+		}
+		return out, nil
+	case cachingSha2Password:
+		//SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+		h1 := sha256.Sum256([]byte(password))
+		h2 := sha256.Sum256(h1[:])
+		h := sha256.New()
+		h.Write(h2[:])
+		h.Write(scramble)
+		out := h.Sum(nil)
+		for i := range out {
+			out[i] ^= h1[i]
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cdc: unsupported auth plugin (%s)", plugin)
+}
+
+// 执行语句，返回文本结果集的各行，NULL 为空字符串
+func (c *binlogConn) query(sql string) ([][]string, error) {
+	c.seq = 0
+	if err := c.writePacket(append([]byte{0x03}, sql...)); err != nil {
+		return nil, err
+	}
+	data, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errMalformed
+	}
+	switch data[0] {
+	case 0x00:
+		return nil, nil
+	case 0xff:
+		return nil, c.error(data)
+	}
+	//跳过字段定义
+	for {
+		if data, err = c.readPacket(); err != nil {
+			return nil, err
+		}
+		if len(data) > 0 && data[0] == 0xfe && len(data) < 9 {
+			break
+		}
+	}
+	rows := make([][]string, 0)
+	for {
+		if data, err = c.readPacket(); err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return nil, errMalformed
+		}
+		if data[0] == 0xff {
+			return nil, c.error(data)
+		}
+		if data[0] == 0xfe && len(data) < 9 {
+			return rows, nil
+		}
+		r := &reader{buf: data}
+		row := make([]string, 0)
+		for r.pos < len(r.buf) && r.err == nil {
+			if r.buf[r.pos] == 0xfb {
+				r.pos++
+				row = append(row, "")
+				continue
+			}
+			row = append(row, string(r.next(int(r.lenenc()))))
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// 按顺序读取数据包，越界时记录错误并返回零值
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.buf)-r.pos {
+		r.err = errMalformed
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// 小端的 n 字节无符号整数
+func (r *reader) uint(n int) uint64 {
+	b := r.next(n)
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// 长度编码的整数
+func (r *reader) lenenc() uint64 {
+	switch first := r.uint(1); first {
+	case 0xfc:
+		return r.uint(2)
+	case 0xfd:
+		return r.uint(3)
+	case 0xfe:
+		return r.uint(8)
+	default:
+		return first
+	}
+}
+
+// 以 0 结尾的字符串
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.buf[r.pos:], 0)
+	if end < 0 {
+		r.err = errMalformed
+		return ""
+	}
+	s := string(r.buf[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
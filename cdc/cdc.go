@@ -0,0 +1,241 @@
+// Package cdc 把 MySQL 行格式 binlog 中的修改转换成按表结构映射的事件
+//
+// binlog 的读取由 Source 完成，BinlogSource 通过复制协议直接读取，也可以包装 go-mysql 等客户端。
+// 本包负责按 db.Table 的字段把原始行映射为字段名到值的 map，并分发给订阅者：
+//
+//	stream := cdc.New(cdc.NewBinlogSource(cdc.BinlogConfig{Host: "127.0.0.1", User: "repl", Password: "...", ServerID: 1001}))
+//	stream.Watch(users)
+//	stream.Subscribe(func(e cdc.Event) error { ... })
+//	err := stream.Run(ctx)
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dgf1988/db"
+)
+
+// Action 修改的类型
+type Action int
+
+const (
+	Insert Action = iota
+	Update
+	Delete
+)
+
+func (a Action) String() string {
+	switch a {
+	case Insert:
+		return "insert"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	}
+	return fmt.Sprintf("action(%d)", int(a))
+}
+
+// Position binlog 的位置
+type Position struct {
+	File   string
+	Offset uint32
+	//开启 GTID 时的事务标识
+	GTID string
+}
+
+// RowsEvent Source 从 binlog 读取的一个行事件
+//
+// Rows 中每一行按表的字段顺序排列；Update 时前后镜像交替排列，
+// 即 Rows[0] 为修改前，Rows[1] 为修改后，依此类推。
+type RowsEvent struct {
+	Schema   string
+	Table    string
+	Action   Action
+	Rows     [][]interface{}
+	Position Position
+}
+
+// Source binlog 的来源
+type Source interface {
+	//阻塞直到读到下一个行事件，ctx 取消时返回 ctx.Err()
+	Next(ctx context.Context) (*RowsEvent, error)
+	Close() error
+}
+
+// Event 映射后的修改事件
+type Event struct {
+	Table  *db.Table
+	Action Action
+	//Insert 时为 nil
+	Before map[string]interface{}
+	//Delete 时为 nil
+	After    map[string]interface{}
+	Position Position
+}
+
+// Handler 事件回调，返回错误时 Stream 停止
+type Handler func(Event) error
+
+// Stream 读取 binlog 并分发事件
+type Stream struct {
+	source Source
+
+	mu       sync.RWMutex
+	tables   map[string]*db.Table
+	handlers []Handler
+	chans    []chan Event
+}
+
+// New 创建事件流
+func New(source Source) *Stream {
+	return &Stream{
+		source: source,
+		tables: make(map[string]*db.Table),
+	}
+}
+
+// Watch 关注一个表，未关注的表的事件被忽略
+func (s *Stream) Watch(t *db.Table) {
+	s.mu.Lock()
+	s.tables[t.DbName+"."+t.TbName] = t
+	s.mu.Unlock()
+}
+
+// Subscribe 添加事件回调
+func (s *Stream) Subscribe(h Handler) {
+	s.mu.Lock()
+	s.handlers = append(s.handlers, h)
+	s.mu.Unlock()
+}
+
+// Chan 以 channel 的形式订阅事件，size 为缓冲大小
+//
+// channel 满时 Stream 阻塞等待，直到消费者读取或 ctx 取消；Run 返回时关闭 channel。
+func (s *Stream) Chan(size int) <-chan Event {
+	ch := make(chan Event, size)
+	s.mu.Lock()
+	s.chans = append(s.chans, ch)
+	s.mu.Unlock()
+	return ch
+}
+
+// Run 读取并分发事件直到 ctx 取消或出错，返回前关闭 Source 和 Chan 创建的 channel
+//
+// db.Close 时 Run 停止并返回 context.Canceled。
+func (s *Stream) Run(ctx context.Context) error {
+	var stopper db.Stopper
+	ctx = stopper.Start(ctx)
+	defer stopper.Done()
+	defer s.source.Close()
+	defer s.closeChans()
+	for {
+		raw, err := s.source.Next(ctx)
+		if err != nil {
+			return err
+		}
+		s.mu.RLock()
+		t := s.tables[raw.Schema+"."+raw.Table]
+		handlers, chans := s.handlers, s.chans
+		s.mu.RUnlock()
+		if t == nil {
+			continue
+		}
+		events, err := mapEvents(t, raw)
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			for _, h := range handlers {
+				if err = h(events[i]); err != nil {
+					return err
+				}
+			}
+			for _, ch := range chans {
+				select {
+				case ch <- events[i]:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+	}
+}
+
+func (s *Stream) closeChans() {
+	s.mu.Lock()
+	for _, ch := range s.chans {
+		close(ch)
+	}
+	s.chans = nil
+	s.mu.Unlock()
+}
+
+// 按表结构映射行
+func mapEvents(t *db.Table, raw *RowsEvent) ([]Event, error) {
+	events := make([]Event, 0, len(raw.Rows))
+	step := 1
+	if raw.Action == Update {
+		step = 2
+		if len(raw.Rows)%2 != 0 {
+			return nil, fmt.Errorf("cdc: the update event of %s has unpaired rows", t.TbName)
+		}
+	}
+	for i := 0; i < len(raw.Rows); i += step {
+		e := Event{Table: t, Action: raw.Action, Position: raw.Position}
+		row, err := mapRow(t, raw.Rows[i])
+		if err != nil {
+			return nil, err
+		}
+		switch raw.Action {
+		case Insert:
+			e.After = row
+		case Delete:
+			e.Before = row
+		case Update:
+			e.Before = row
+			if e.After, err = mapRow(t, raw.Rows[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func mapRow(t *db.Table, values []interface{}) (map[string]interface{}, error) {
+	if len(values) != len(t.Fields) {
+		return nil, fmt.Errorf("cdc: the row of %s has %d values, the table has %d columns", t.TbName, len(values), len(t.Fields))
+	}
+	row := make(map[string]interface{}, len(values))
+	for i := range t.Fields {
+		value := values[i]
+		ft := t.Fields[i].Type
+		switch v := value.(type) {
+		case []byte:
+			//字符类型统一为 string
+			switch ft.Value {
+			case db.TypeChar, db.TypeVarchar, db.TypeText, db.TypeMediumText, db.TypeLongtext, db.TypeEnum:
+				value = string(v)
+			}
+		case int64:
+			switch {
+			//binlog 中的枚举为从 1 开始的序号，0 为空字符串
+			case ft.Value == db.TypeEnum && v >= 0 && v <= int64(len(ft.Enum)):
+				value = ""
+				if v > 0 {
+					value = ft.Enum[v-1]
+				}
+			//没有符号元数据时按字段类型转换无符号整数
+			case ft.Unsigned && v < 0 && ft.Value == db.TypeInt:
+				value = uint64(uint32(v))
+			case ft.Unsigned && v < 0 && ft.Value == db.TypeBigint:
+				value = uint64(v)
+			}
+		}
+		row[t.Fields[i].Name] = value
+	}
+	return row, nil
+}
@@ -0,0 +1,665 @@
+package cdc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binlog 中的字段类型
+const (
+	typeDecimal    = 0x00
+	typeTiny       = 0x01
+	typeShort      = 0x02
+	typeLong       = 0x03
+	typeFloat      = 0x04
+	typeDouble     = 0x05
+	typeNull       = 0x06
+	typeTimestamp  = 0x07
+	typeLongLong   = 0x08
+	typeInt24      = 0x09
+	typeDate       = 0x0a
+	typeTime       = 0x0b
+	typeDatetime   = 0x0c
+	typeYear       = 0x0d
+	typeNewDate    = 0x0e
+	typeVarchar    = 0x0f
+	typeBit        = 0x10
+	typeTimestamp2 = 0x11
+	typeDatetime2  = 0x12
+	typeTime2      = 0x13
+	typeVector     = 0xf2
+	typeJSON       = 0xf5
+	typeNewDecimal = 0xf6
+	typeEnum       = 0xf7
+	typeSet        = 0xf8
+	typeTinyBlob   = 0xf9
+	typeMediumBlob = 0xfa
+	typeLongBlob   = 0xfb
+	typeBlob       = 0xfc
+	typeVarString  = 0xfd
+	typeString     = 0xfe
+	typeGeometry   = 0xff
+)
+
+// TABLE_MAP_EVENT 描述的表，行事件按 id 引用
+type tableMap struct {
+	id     uint64
+	schema string
+	table  string
+	types  []byte
+	meta   []uint16
+	//无符号的整数字段，没有可选元数据时为空
+	unsigned []bool
+}
+
+func parseTableMap(body []byte) (*tableMap, error) {
+	r := &reader{buf: body}
+	tm := &tableMap{id: r.uint(6)}
+	r.next(2)
+	tm.schema = string(r.next(int(r.uint(1))))
+	r.next(1)
+	tm.table = string(r.next(int(r.uint(1))))
+	r.next(1)
+	n := int(r.lenenc())
+	tm.types = r.next(n)
+	meta := &reader{buf: r.next(int(r.lenenc()))}
+	if r.err != nil {
+		return nil, r.err
+	}
+	tm.meta = make([]uint16, n)
+	numeric := make([]int, 0)
+	for i, typ := range tm.types {
+		switch typ {
+		case typeFloat, typeDouble, typeBlob, typeTinyBlob, typeMediumBlob, typeLongBlob, typeGeometry, typeJSON, typeVector,
+			typeTimestamp2, typeDatetime2, typeTime2:
+			tm.meta[i] = uint16(meta.uint(1))
+		case typeVarchar, typeVarString:
+			tm.meta[i] = uint16(meta.uint(2))
+		case typeBit:
+			//位数除以 8 的余数和字节数
+			b := meta.next(2)
+			if b != nil {
+				tm.meta[i] = uint16(b[1])*8 + uint16(b[0])
+			}
+		case typeNewDecimal, typeString, typeEnum, typeSet:
+			//精度和小数位数，或者实际类型和长度
+			b := meta.next(2)
+			if b != nil {
+				tm.meta[i] = uint16(b[0])<<8 | uint16(b[1])
+			}
+		}
+		switch typ {
+		case typeTiny, typeShort, typeInt24, typeLong, typeLongLong, typeFloat, typeDouble, typeDecimal, typeNewDecimal:
+			numeric = append(numeric, i)
+		}
+	}
+	if meta.err != nil {
+		return nil, meta.err
+	}
+	//NULL 位图之后是 8.0 的可选元数据，类型 1 为数值字段的符号位图
+	r.next((n + 7) / 8)
+	for r.err == nil && r.pos < len(r.buf) {
+		typ := r.uint(1)
+		value := r.next(int(r.lenenc()))
+		if typ != 1 || r.err != nil {
+			continue
+		}
+		tm.unsigned = make([]bool, n)
+		for j, i := range numeric {
+			if j/8 < len(value) && value[j/8]&(0x80>>uint(j%8)) != 0 {
+				tm.unsigned[i] = true
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return tm, nil
+}
+
+// 读取一行，present 为行中记录了的字段的位图
+func (tm *tableMap) decodeRow(r *reader, present []byte) ([]interface{}, error) {
+	n := len(tm.types)
+	count := 0
+	for i := 0; i < n; i++ {
+		if bitSet(present, i) {
+			count++
+		}
+	}
+	nulls := r.next((count + 7) / 8)
+	row := make([]interface{}, n)
+	k := 0
+	for i := 0; i < n; i++ {
+		if !bitSet(present, i) {
+			continue
+		}
+		null := bitSet(nulls, k)
+		k++
+		if null {
+			continue
+		}
+		v, err := decodeValue(r, tm.types[i], tm.meta[i], tm.unsigned != nil && tm.unsigned[i])
+		if err != nil {
+			return nil, fmt.Errorf("cdc: the column %d of %s.%s: %w", i, tm.schema, tm.table, err)
+		}
+		row[i] = v
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return row, nil
+}
+
+func bitSet(bitmap []byte, i int) bool {
+	return i/8 < len(bitmap) && bitmap[i/8]&(1<<uint(i%8)) != 0
+}
+
+// 大端的无符号整数
+func bigEndian(b []byte) uint64 {
+	var v uint64
+	for i := range b {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// 解析一个字段的值
+//
+// 整数为 int64，已知无符号时为 uint64；DECIMAL 为字符串；日期和时间为 MySQL 文本格式的字符串，
+// TIMESTAMP 按 UTC 转换；字符串、BLOB 和 JSON 为 []byte；ENUM 为序号，SET 和 BIT 为 uint64。
+func decodeValue(r *reader, typ byte, meta uint16, unsigned bool) (interface{}, error) {
+	switch typ {
+	case typeTiny:
+		v := r.uint(1)
+		if unsigned {
+			return v, nil
+		}
+		return int64(int8(v)), nil
+	case typeShort:
+		v := r.uint(2)
+		if unsigned {
+			return v, nil
+		}
+		return int64(int16(v)), nil
+	case typeInt24:
+		v := r.uint(3)
+		if unsigned {
+			return v, nil
+		}
+		return int64(int32(v<<8) >> 8), nil
+	case typeLong:
+		v := r.uint(4)
+		if unsigned {
+			return v, nil
+		}
+		return int64(int32(v)), nil
+	case typeLongLong:
+		v := r.uint(8)
+		if unsigned {
+			return v, nil
+		}
+		return int64(v), nil
+	case typeFloat:
+		return float64(math.Float32frombits(uint32(r.uint(4)))), nil
+	case typeDouble:
+		return math.Float64frombits(r.uint(8)), nil
+	case typeNull:
+		return nil, nil
+	case typeYear:
+		v := int64(r.uint(1))
+		if v == 0 {
+			return v, nil
+		}
+		return 1900 + v, nil
+	case typeDate, typeNewDate:
+		v := r.uint(3)
+		return fmt.Sprintf("%04d-%02d-%02d", v>>9, v>>5&15, v&31), nil
+	case typeTimestamp:
+		return formatTimestamp(int64(r.uint(4)), 0, 0), nil
+	case typeTimestamp2:
+		sec := int64(bigEndian(r.next(4)))
+		return formatTimestamp(sec, fraction(r, int(meta)), int(meta)), nil
+	case typeDatetime:
+		v := r.uint(8)
+		d, t := v/1000000, v%1000000
+		return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d/10000, d/100%100, d%100, t/10000, t/100%100, t%100), nil
+	case typeDatetime2:
+		v := int64(bigEndian(r.next(5))) - 0x8000000000
+		return formatDatetime(v, fraction(r, int(meta)), int(meta), true), nil
+	case typeTime:
+		v := int64(int32(r.uint(3)<<8) >> 8)
+		sign := ""
+		if v < 0 {
+			sign, v = "-", -v
+		}
+		return fmt.Sprintf("%s%02d:%02d:%02d", sign, v/10000, v/100%100, v%100), nil
+	case typeTime2:
+		return decodeTime2(r, int(meta)), nil
+	case typeVarchar, typeVarString:
+		if meta < 256 {
+			return r.next(int(r.uint(1))), nil
+		}
+		return r.next(int(r.uint(2))), nil
+	case typeString:
+		//实际类型和长度，长度超过 255 时高位存放在类型中
+		realType, length := byte(meta>>8), int(meta&0xff)
+		if realType&0x30 != 0x30 {
+			length |= int(realType&0x30^0x30) << 4
+			realType |= 0x30
+		}
+		switch realType {
+		case typeEnum, typeSet:
+			return decodeValue(r, realType, uint16(length), unsigned)
+		}
+		if length < 256 {
+			return r.next(int(r.uint(1))), nil
+		}
+		return r.next(int(r.uint(2))), nil
+	case typeEnum:
+		return int64(r.uint(int(meta & 0xff))), nil
+	case typeSet:
+		return r.uint(int(meta & 0xff)), nil
+	case typeBit:
+		return bigEndian(r.next((int(meta) + 7) / 8)), nil
+	case typeNewDecimal:
+		return decodeDecimal(r, int(meta>>8), int(meta&0xff)), nil
+	case typeBlob, typeTinyBlob, typeMediumBlob, typeLongBlob, typeGeometry, typeVector:
+		return r.next(int(r.uint(int(meta)))), nil
+	case typeJSON:
+		data := r.next(int(r.uint(int(meta))))
+		if r.err != nil {
+			return nil, r.err
+		}
+		return decodeJSON(data)
+	}
+	return nil, fmt.Errorf("unsupported column type %d", typ)
+}
+
+// 小数秒，单位为微秒
+func fraction(r *reader, fsp int) int64 {
+	switch fsp {
+	case 1, 2:
+		return int64(r.uint(1)) * 10000
+	case 3, 4:
+		return int64(bigEndian(r.next(2))) * 100
+	case 5, 6:
+		return int64(bigEndian(r.next(3)))
+	}
+	return 0
+}
+
+// 小数秒的文本，fsp 为 0 时为空
+func formatFraction(micro int64, fsp int) string {
+	if fsp <= 0 {
+		return ""
+	}
+	if fsp > 6 {
+		fsp = 6
+	}
+	return fmt.Sprintf(".%06d", micro)[:fsp+1]
+}
+
+func formatTimestamp(sec, micro int64, fsp int) string {
+	if sec == 0 && micro == 0 {
+		return "0000-00-00 00:00:00" + formatFraction(0, fsp)
+	}
+	return time.Unix(sec, 0).UTC().Format("2006-01-02 15:04:05") + formatFraction(micro, fsp)
+}
+
+// 按 年*13+月、日、时、分、秒 打包的日期时间
+func formatDatetime(packed, micro int64, fsp int, withTime bool) string {
+	ymd := packed >> 17
+	ym := ymd >> 5
+	hms := packed % (1 << 17)
+	date := fmt.Sprintf("%04d-%02d-%02d", ym/13, ym%13, ymd%32)
+	if !withTime {
+		return date
+	}
+	return fmt.Sprintf("%s %02d:%02d:%02d", date, hms>>12, hms>>6%64, hms%64) + formatFraction(micro, fsp)
+}
+
+// TIME(fsp)，整数部分和小数部分合并为以 2^24 为进制的数，负数时两部分一起取反
+func decodeTime2(r *reader, fsp int) string {
+	var tmp int64
+	switch fsp {
+	case 1, 2:
+		intPart := int64(bigEndian(r.next(3))) - 0x800000
+		frac := int64(r.uint(1))
+		if intPart < 0 && frac != 0 {
+			intPart++
+			frac -= 0x100
+		}
+		tmp = intPart<<24 + frac*10000
+	case 3, 4:
+		intPart := int64(bigEndian(r.next(3))) - 0x800000
+		frac := int64(bigEndian(r.next(2)))
+		if intPart < 0 && frac != 0 {
+			intPart++
+			frac -= 0x10000
+		}
+		tmp = intPart<<24 + frac*100
+	case 5, 6:
+		tmp = int64(bigEndian(r.next(6))) - 0x800000000000
+	default:
+		tmp = (int64(bigEndian(r.next(3))) - 0x800000) << 24
+	}
+	return formatTime(tmp, fsp)
+}
+
+func formatTime(packed int64, fsp int) string {
+	sign := ""
+	if packed < 0 {
+		sign, packed = "-", -packed
+	}
+	hms := packed >> 24
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, hms>>12%(1<<10), hms>>6%64, hms%64) + formatFraction(packed%(1<<24), fsp)
+}
+
+// 每组不足 9 位的十进制数字占用的字节数
+var digitBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// DECIMAL 的二进制格式：整数和小数部分每 9 位数字占 4 字节，首位为符号位，负数时所有位取反
+func decodeDecimal(r *reader, precision, scale int) string {
+	intg := precision - scale
+	intg0, intg0x := intg/9, intg%9
+	frac0, frac0x := scale/9, scale%9
+	src := r.next(intg0*4 + digitBytes[intg0x] + frac0*4 + digitBytes[frac0x])
+	if len(src) == 0 {
+		return ""
+	}
+	buf := append([]byte{}, src...)
+	negative := buf[0]&0x80 == 0
+	buf[0] ^= 0x80
+	if negative {
+		for i := range buf {
+			buf[i] = ^buf[i]
+		}
+	}
+	pos := 0
+	group := func(n int) uint64 {
+		v := bigEndian(buf[pos : pos+n])
+		pos += n
+		return v
+	}
+	var integer strings.Builder
+	if intg0x > 0 {
+		integer.WriteString(strconv.FormatUint(group(digitBytes[intg0x]), 10))
+	}
+	for i := 0; i < intg0; i++ {
+		fmt.Fprintf(&integer, "%09d", group(4))
+	}
+	var sb strings.Builder
+	if negative {
+		sb.WriteByte('-')
+	}
+	if s := strings.TrimLeft(integer.String(), "0"); s != "" {
+		sb.WriteString(s)
+	} else {
+		sb.WriteByte('0')
+	}
+	if scale > 0 {
+		sb.WriteByte('.')
+		for i := 0; i < frac0; i++ {
+			fmt.Fprintf(&sb, "%09d", group(4))
+		}
+		if frac0x > 0 {
+			fmt.Fprintf(&sb, "%0*d", frac0x, group(digitBytes[frac0x]))
+		}
+	}
+	return sb.String()
+}
+
+// 把 JSON 字段的二进制格式转换为 JSON 文本
+func decodeJSON(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte("null"), nil
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, data[0], data[1:]); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JSON 二进制格式中值的类型
+const (
+	jsonSmallObject = 0x00
+	jsonLargeObject = 0x01
+	jsonSmallArray  = 0x02
+	jsonLargeArray  = 0x03
+	jsonLiteral     = 0x04
+	jsonInt16       = 0x05
+	jsonUint16      = 0x06
+	jsonInt32       = 0x07
+	jsonUint32      = 0x08
+	jsonInt64       = 0x09
+	jsonUint64      = 0x0a
+	jsonDouble      = 0x0b
+	jsonString      = 0x0c
+	jsonOpaque      = 0x0f
+)
+
+// data 从 offset 开始的 n 个字节
+func span(data []byte, offset, n int) ([]byte, error) {
+	if offset < 0 || n < 0 || offset > len(data) || n > len(data)-offset {
+		return nil, errMalformed
+	}
+	return data[offset : offset+n], nil
+}
+
+// 每字节 7 位的变长长度，返回长度和占用的字节数
+func varLength(data []byte) (int, int, error) {
+	length := 0
+	for i := 0; i < 5 && i < len(data); i++ {
+		length |= int(data[i]&0x7f) << (7 * uint(i))
+		if data[i]&0x80 == 0 {
+			return length, i + 1, nil
+		}
+	}
+	return 0, 0, errMalformed
+}
+
+func writeJSON(buf *bytes.Buffer, typ byte, data []byte) error {
+	switch typ {
+	case jsonSmallObject, jsonLargeObject:
+		return writeJSONContainer(buf, data, true, typ == jsonLargeObject)
+	case jsonSmallArray, jsonLargeArray:
+		return writeJSONContainer(buf, data, false, typ == jsonLargeArray)
+	case jsonLiteral:
+		if len(data) < 1 {
+			return errMalformed
+		}
+		switch data[0] {
+		case 0:
+			buf.WriteString("null")
+		case 1:
+			buf.WriteString("true")
+		case 2:
+			buf.WriteString("false")
+		default:
+			return errMalformed
+		}
+		return nil
+	case jsonInt16, jsonUint16, jsonInt32, jsonUint32, jsonInt64, jsonUint64, jsonDouble:
+		size := map[byte]int{jsonInt16: 2, jsonUint16: 2, jsonInt32: 4, jsonUint32: 4}[typ]
+		if size == 0 {
+			size = 8
+		}
+		b, err := span(data, 0, size)
+		if err != nil {
+			return err
+		}
+		v := (&reader{buf: b}).uint(size)
+		switch typ {
+		case jsonInt16:
+			buf.WriteString(strconv.FormatInt(int64(int16(v)), 10))
+		case jsonInt32:
+			buf.WriteString(strconv.FormatInt(int64(int32(v)), 10))
+		case jsonInt64:
+			buf.WriteString(strconv.FormatInt(int64(v), 10))
+		case jsonDouble:
+			buf.WriteString(strconv.FormatFloat(math.Float64frombits(v), 'g', -1, 64))
+		default:
+			buf.WriteString(strconv.FormatUint(v, 10))
+		}
+		return nil
+	case jsonString:
+		n, l, err := varLength(data)
+		if err != nil {
+			return err
+		}
+		s, err := span(data, l, n)
+		if err != nil {
+			return err
+		}
+		return writeJSONString(buf, string(s))
+	case jsonOpaque:
+		if len(data) < 1 {
+			return errMalformed
+		}
+		n, l, err := varLength(data[1:])
+		if err != nil {
+			return err
+		}
+		payload, err := span(data, 1+l, n)
+		if err != nil {
+			return err
+		}
+		return writeJSONOpaque(buf, data[0], payload)
+	}
+	return errMalformed
+}
+
+// 对象和数组：元素个数、总字节数、键的位置、值的类型和位置，小的值直接存放在值的位置中
+func writeJSONContainer(buf *bytes.Buffer, data []byte, object, large bool) error {
+	size := 2
+	if large {
+		size = 4
+	}
+	header, err := span(data, 0, 2*size)
+	if err != nil {
+		return err
+	}
+	hr := &reader{buf: header}
+	count, total := int(hr.uint(size)), int(hr.uint(size))
+	if total > len(data) {
+		return errMalformed
+	}
+	data = data[:total]
+	keyEntries := 2 * size
+	keySize := 0
+	if object {
+		keySize = size + 2
+	}
+	valueSize := 1 + size
+	if object {
+		buf.WriteByte('{')
+	} else {
+		buf.WriteByte('[')
+	}
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if object {
+			entry, err := span(data, keyEntries+i*keySize, keySize)
+			if err != nil {
+				return err
+			}
+			er := &reader{buf: entry}
+			offset, length := int(er.uint(size)), int(er.uint(2))
+			key, err := span(data, offset, length)
+			if err != nil {
+				return err
+			}
+			if err = writeJSONString(buf, string(key)); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+		}
+		entry, err := span(data, keyEntries+count*keySize+i*valueSize, valueSize)
+		if err != nil {
+			return err
+		}
+		typ := entry[0]
+		switch {
+		case typ == jsonLiteral || typ == jsonInt16 || typ == jsonUint16 || large && (typ == jsonInt32 || typ == jsonUint32):
+			err = writeJSON(buf, typ, entry[1:])
+		default:
+			offset := int((&reader{buf: entry[1:]}).uint(size))
+			if offset >= len(data) {
+				return errMalformed
+			}
+			err = writeJSON(buf, typ, data[offset:])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if object {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// 不透明的值：DECIMAL 和日期时间按 MySQL 的格式输出，其他类型与 MySQL 一样输出为 base64
+func writeJSONOpaque(buf *bytes.Buffer, typ byte, data []byte) error {
+	switch typ {
+	case typeNewDecimal:
+		if len(data) < 2 {
+			return errMalformed
+		}
+		r := &reader{buf: data[2:]}
+		s := decodeDecimal(r, int(data[0]), int(data[1]))
+		if r.err != nil {
+			return r.err
+		}
+		buf.WriteString(s)
+		return nil
+	case typeDate, typeDatetime, typeTimestamp, typeTime:
+		if len(data) < 8 {
+			return errMalformed
+		}
+		packed := int64(binary.LittleEndian.Uint64(data))
+		var s string
+		if typ == typeTime {
+			s = formatTime(packed, fspOf(packed))
+		} else {
+			if packed < 0 {
+				packed = -packed
+			}
+			s = formatDatetime(packed>>24, packed%(1<<24), fspOf(packed), typ != typeDate)
+		}
+		return writeJSONString(buf, s)
+	}
+	return writeJSONString(buf, fmt.Sprintf("base64:type%d:%s", typ, base64.StdEncoding.EncodeToString(data)))
+}
+
+// 打包的时间中有小数秒时输出 6 位
+func fspOf(packed int64) int {
+	if packed < 0 {
+		packed = -packed
+	}
+	if packed%(1<<24) != 0 {
+		return 6
+	}
+	return 0
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// On 返回在 sqldb 上执行查询的表，例如从库，原来的表不受影响
+func (t Table) On(sqldb *sql.DB) *Table {
+	t.db = sqldb
+	return &t
+}
+
+// Checksum 执行 CHECKSUM TABLE，表不存在时返回错误
+func (t *Table) Checksum() (int64, error) {
+	var name string
+	var sum sql.NullInt64
+	if err := t.queryRow(fmt.Sprintf("CHECKSUM TABLE %s", t.Fullname)).Scan(&name, &sum); err != nil {
+		return 0, err
+	}
+	if !sum.Valid {
+		return 0, fmt.Errorf("db: the table (%s) does not exist", t.Fullname)
+	}
+	return sum.Int64, nil
+}
+
+// ChunkChecksum 按主键范围 (Lower, Upper] 计算的校验和
+type ChunkChecksum struct {
+	Lower int64
+	Upper int64
+	Count int64
+	Sum   int64
+}
+
+// 一行所有字段的 CRC32，NULL 单独标记以区分 NULL 和空字符串
+func (t *Table) rowHashSql() string {
+	columns := make([]string, 0, t.Len*2)
+	for i := range t.Fields {
+		columns = append(columns, t.Fields[i].FullName, fmt.Sprintf("ISNULL(%s)", t.Fields[i].FullName))
+	}
+	return fmt.Sprintf("COALESCE(BIT_XOR(CAST(CRC32(CONCAT_WS('#', %s)) AS UNSIGNED)), 0)", strings.Join(columns, ", "))
+}
+
+// 计算一个主键范围的校验和
+func (t *Table) chunkChecksum(pk string, lower, upper int64) (ChunkChecksum, error) {
+	c := ChunkChecksum{Lower: lower, Upper: upper}
+	err := t.queryRow(fmt.Sprintf("SELECT COUNT(*), %s FROM %s WHERE %s > ? AND %s <= ?", t.rowHashSql(), t.Fullname, pk, pk),
+		lower, upper).Scan(&c.Count, &c.Sum)
+	return c, err
+}
+
+// ChunkChecksums 按整数主键每 chunkSize 行一段计算校验和，类似 pt-table-checksum
+func (t *Table) ChunkChecksums(chunkSize int) ([]ChunkChecksum, error) {
+	if chunkSize <= 0 {
+		chunkSize = 1000
+	}
+	i, err := t.indexOf(t.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	pk := t.Fields[i].FullName
+	var min sql.NullInt64
+	if err = t.queryRow(fmt.Sprintf("SELECT MIN(%s) FROM %s", pk, t.Fullname)).Scan(&min); err != nil {
+		return nil, err
+	}
+	chunks := make([]ChunkChecksum, 0)
+	if !min.Valid {
+		return chunks, nil
+	}
+	lower := min.Int64 - 1
+	for {
+		var upper sql.NullInt64
+		if err = t.queryRow(fmt.Sprintf("SELECT MAX(x.pk) FROM (SELECT %s AS pk FROM %s WHERE %s > ? ORDER BY %s LIMIT ?) x",
+			pk, t.Fullname, pk, pk), lower, chunkSize).Scan(&upper); err != nil {
+			return nil, err
+		}
+		if !upper.Valid {
+			return chunks, nil
+		}
+		c, err := t.chunkChecksum(pk, lower, upper.Int64)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+		lower = upper.Int64
+	}
+}
+
+// CompareChecksums 按 a 的分段计算 b 中相同主键范围的校验和，返回不一致的分段
+//
+// 常用于比较主库和从库：CompareChecksums(t.Primary(), t.On(replica), 1000)。
+// 在 a 的范围之外时，b 中多出的行作为额外的分段比较。
+func CompareChecksums(a, b *Table, chunkSize int) ([]ChunkChecksum, error) {
+	chunks, err := a.ChunkChecksums(chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	i, err := b.indexOf(b.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	pk := b.Fields[i].FullName
+	var min, max sql.NullInt64
+	if err = b.queryRow(fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", pk, pk, b.Fullname)).Scan(&min, &max); err != nil {
+		return nil, err
+	}
+	if max.Valid {
+		switch {
+		case len(chunks) == 0:
+			chunks = append(chunks, ChunkChecksum{Lower: min.Int64 - 1, Upper: max.Int64})
+		default:
+			if first := chunks[0]; min.Int64 <= first.Lower {
+				chunks = append([]ChunkChecksum{{Lower: min.Int64 - 1, Upper: first.Lower}}, chunks...)
+			}
+			if last := chunks[len(chunks)-1]; max.Int64 > last.Upper {
+				chunks = append(chunks, ChunkChecksum{Lower: last.Upper, Upper: max.Int64})
+			}
+		}
+	}
+	diffs := make([]ChunkChecksum, 0)
+	for _, c := range chunks {
+		other, err := b.chunkChecksum(pk, c.Lower, c.Upper)
+		if err != nil {
+			return nil, err
+		}
+		if other.Count != c.Count || other.Sum != c.Sum {
+			diffs = append(diffs, other)
+		}
+	}
+	return diffs, nil
+}
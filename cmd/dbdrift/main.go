@@ -0,0 +1,73 @@
+// Command dbdrift 比较表结构快照与数据库中的实际结构，有差异时以状态码 1 退出
+//
+//	dbdrift -host 127.0.0.1 -user root -password secret -snapshot schema.yaml
+//
+// 快照由 SchemaSnapshot.JSON 或 SchemaSnapshot.YAML 导出，可以提交到版本库，部署前检查。
+// 指定 -db 时把该数据库的当前结构以 -export 指定的格式写到标准输出，用于生成新的快照。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dgf1988/db"
+)
+
+func main() {
+	host := flag.String("host", "127.0.0.1", "MySQL host")
+	port := flag.Int("port", 3306, "MySQL port")
+	user := flag.String("user", "root", "MySQL user")
+	password := flag.String("password", os.Getenv("MYSQL_PWD"), "MySQL password, defaults to $MYSQL_PWD")
+	database := flag.String("db", "", "export this database instead of comparing")
+	snapshot := flag.String("snapshot", "", "schema snapshot file, JSON or YAML")
+	export := flag.String("export", "", "export format when -db is set: json or yaml")
+	flag.Parse()
+
+	if err := db.Open(*user, *password, *host, *port, "information_schema"); err != nil {
+		fail(err)
+	}
+	if *database != "" {
+		s, err := db.Schema(*database).Snapshot()
+		if err != nil {
+			fail(err)
+		}
+		var out []byte
+		if *export == "json" {
+			out, err = s.JSON()
+		} else {
+			out, err = s.YAML()
+		}
+		if err != nil {
+			fail(err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+	if *snapshot == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	data, err := ioutil.ReadFile(*snapshot)
+	if err != nil {
+		fail(err)
+	}
+	expected, err := db.LoadSchema(data)
+	if err != nil {
+		fail(err)
+	}
+	report, err := db.DetectDrift(expected)
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println(report)
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "dbdrift:", err)
+	os.Exit(2)
+}
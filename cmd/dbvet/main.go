@@ -0,0 +1,337 @@
+// Command dbvet 检查代码中传给 Query、QueryRow、Exec 等方法的 SQL 字符串，有问题时以状态码 1 退出
+//
+//	dbvet -host 127.0.0.1 -user root -db shop ./...
+//	dbvet -snapshot schema.yaml ./...
+//
+// 表结构从数据库读取，或者从 dbdrift 导出的快照读取。检查的内容：
+//   - 语句中的表和字段是否存在；
+//   - 占位符 ? 的个数与参数个数是否相同；
+//   - 与字段直接比较或写入字段的字面量参数是否与字段类型相符。
+//
+// 只检查字面量和常量组成的语句。Table.Query 和 Table.QueryRow 的条件语句按同一文件中
+// db.GetTable("表名") 赋值的变量确定表。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgf1988/db"
+)
+
+func main() {
+	host := flag.String("host", "127.0.0.1", "MySQL host")
+	port := flag.Int("port", 3306, "MySQL port")
+	user := flag.String("user", "root", "MySQL user")
+	password := flag.String("password", os.Getenv("MYSQL_PWD"), "MySQL password, defaults to $MYSQL_PWD")
+	database := flag.String("db", "", "database to validate against")
+	snapshot := flag.String("snapshot", "", "schema snapshot file, JSON or YAML, instead of connecting")
+	flag.Parse()
+
+	var s *db.SchemaSnapshot
+	var err error
+	switch {
+	case *snapshot != "":
+		var data []byte
+		if data, err = ioutil.ReadFile(*snapshot); err != nil {
+			fail(err)
+		}
+		s, err = db.LoadSchema(data)
+	case *database != "":
+		if err = db.Open(*user, *password, *host, *port, "information_schema"); err != nil {
+			fail(err)
+		}
+		s, err = db.Schema(*database).Snapshot()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	v := &vet{schema: newSchema(s), fset: token.NewFileSet()}
+	for _, pattern := range patterns {
+		if err = v.walk(pattern); err != nil {
+			fail(err)
+		}
+	}
+	sort.Slice(v.problems, func(i, j int) bool {
+		a, b := v.problems[i].pos, v.problems[j].pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Line < b.Line
+	})
+	for _, p := range v.problems {
+		fmt.Printf("%s: %s\n", p.pos, p.msg)
+	}
+	if len(v.problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "dbvet:", err)
+	os.Exit(2)
+}
+
+type problem struct {
+	pos token.Position
+	msg string
+}
+
+type vet struct {
+	schema   *schema
+	fset     *token.FileSet
+	problems []problem
+}
+
+func (v *vet) report(pos token.Pos, format string, args ...interface{}) {
+	v.problems = append(v.problems, problem{pos: v.fset.Position(pos), msg: fmt.Sprintf(format, args...)})
+}
+
+// 检查目录，以 /... 结尾时包括子目录
+func (v *vet) walk(pattern string) error {
+	root, recursive := strings.TrimSuffix(pattern, "/..."), strings.HasSuffix(pattern, "/...")
+	if pattern == "..." {
+		root, recursive = ".", true
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != root && (!recursive || name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return v.dir(path)
+		}
+		return nil
+	})
+}
+
+// 检查一个目录中的 Go 文件，同一个包的常量可以互相引用
+func (v *vet) dir(path string) error {
+	pkgs, err := parser.ParseDir(v.fset, path, nil, 0)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		consts := make(map[string]ast.Expr)
+		for _, f := range pkg.Files {
+			collectConsts(f, consts)
+		}
+		for _, f := range pkg.Files {
+			v.file(f, consts)
+		}
+	}
+	return nil
+}
+
+// 收集字符串常量
+func collectConsts(f *ast.File, consts map[string]ast.Expr) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		if d, ok := n.(*ast.GenDecl); ok && d.Tok == token.CONST {
+			for _, spec := range d.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i := range vs.Names {
+					if i < len(vs.Values) {
+						consts[vs.Names[i].Name] = vs.Values[i]
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+// 求字面量和常量组成的字符串
+func stringValue(e ast.Expr, consts map[string]ast.Expr, depth int) (string, bool) {
+	if depth > 16 {
+		return "", false
+	}
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(e.Value)
+		return s, err == nil
+	case *ast.ParenExpr:
+		return stringValue(e.X, consts, depth+1)
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", false
+		}
+		x, ok := stringValue(e.X, consts, depth+1)
+		if !ok {
+			return "", false
+		}
+		y, ok := stringValue(e.Y, consts, depth+1)
+		return x + y, ok
+	case *ast.Ident:
+		if c, ok := consts[e.Name]; ok {
+			return stringValue(c, consts, depth+1)
+		}
+	}
+	return "", false
+}
+
+// 包级函数和 *sql.DB、*sql.Tx 等方法中语句参数的位置
+var queryFuncs = map[string]int{
+	"Query": 0, "QueryRow": 0, "Exec": 0,
+	"QueryContext": 1, "QueryRowContext": 1, "ExecContext": 1,
+	"ScalarInt64": 0, "ScalarFloat64": 0, "ScalarString": 0,
+}
+
+// 返回同一个表的 Table 方法
+var tableMethods = map[string]bool{"WithContext": true, "On": true, "Primary": true, "Cached": true}
+
+func (v *vet) file(f *ast.File, consts map[string]ast.Expr) {
+	pkgName := "db"
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path == "github.com/dgf1988/db" && imp.Name != nil {
+			pkgName = imp.Name.Name
+		}
+	}
+	//GetTable 赋值的变量对应的表
+	tables := make(map[string]string)
+	ast.Inspect(f, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || len(as.Rhs) != 1 {
+			return true
+		}
+		if name, ok := v.getTable(as.Rhs[0], consts); ok && len(as.Lhs) > 0 {
+			tables[types.ExprString(as.Lhs[0])] = name
+		}
+		return true
+	})
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		name := sel.Sel.Name
+		if name == "GetTable" {
+			v.getTable(call, consts)
+			return true
+		}
+		if table, ok := tableOf(sel.X, tables); ok && (name == "Query" || name == "QueryRow") {
+			if len(call.Args) > 0 {
+				if query, ok := stringValue(call.Args[0], consts, 0); ok {
+					v.check(call, 0, fmt.Sprintf("SELECT * FROM `%s` %s", table, query))
+				}
+			}
+			return true
+		}
+		i, ok := queryFuncs[name]
+		if !ok || len(call.Args) <= i {
+			return true
+		}
+		query, ok := stringValue(call.Args[i], consts, 0)
+		if !ok {
+			return true
+		}
+		//其他包的同名方法只检查完整的语句
+		if x, ok := sel.X.(*ast.Ident); !ok || x.Name != pkgName {
+			if !isStatement(query) {
+				return true
+			}
+		}
+		v.check(call, i, query)
+		return true
+	})
+}
+
+// 检查 GetTable("表名") 中的表是否存在
+func (v *vet) getTable(e ast.Expr, consts map[string]ast.Expr) (string, bool) {
+	call, ok := e.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return "", false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "GetTable" {
+		return "", false
+	}
+	name, ok := stringValue(call.Args[0], consts, 0)
+	if !ok {
+		return "", false
+	}
+	name = strings.ToLower(name)
+	if _, known := v.schema.tables[name]; !known {
+		v.report(call.Args[0].Pos(), "unknown table (%s)", name)
+		return "", false
+	}
+	return name, true
+}
+
+// 方法的接收者对应的表，t.WithContext(ctx) 等与 t 相同
+func tableOf(e ast.Expr, tables map[string]string) (string, bool) {
+	if call, ok := e.(*ast.CallExpr); ok {
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && tableMethods[sel.Sel.Name] {
+			return tableOf(sel.X, tables)
+		}
+		return "", false
+	}
+	name, ok := tables[types.ExprString(e)]
+	return name, ok
+}
+
+func isStatement(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "WITH":
+		return true
+	}
+	return false
+}
+
+// 检查语句以及之后的参数
+func (v *vet) check(call *ast.CallExpr, index int, query string) {
+	pos := call.Args[index].Pos()
+	a := v.schema.analyze(query)
+	for _, msg := range a.problems {
+		v.report(pos, "%s", msg)
+	}
+	if call.Ellipsis.IsValid() {
+		return
+	}
+	args := call.Args[index+1:]
+	if len(args) != a.params {
+		v.report(pos, "the query has %d placeholders but %d arguments", a.params, len(args))
+		return
+	}
+	for i, arg := range args {
+		lit, ok := arg.(*ast.BasicLit)
+		if !ok {
+			continue
+		}
+		if c, ok := a.columns[i]; ok {
+			if msg := checkLiteral(c, lit.Kind.String(), lit.Value); msg != "" {
+				v.report(arg.Pos(), "%s", msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dgf1988/db"
+)
+
+// 语句中的词法单元
+type sqlToken struct {
+	kind int
+	text string
+}
+
+const (
+	tWord = iota
+	//反引号中的标识符
+	tQuoted
+	tString
+	tNumber
+	tParam
+	tPunct
+)
+
+// 切分语句，跳过注释
+func tokenize(query string) []sqlToken {
+	tokens := make([]sqlToken, 0)
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(query[i:], "-- "):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(query)
+			}
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(query) && query[j] != c {
+				if query[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			kind := tString
+			if c == '`' {
+				kind = tQuoted
+			}
+			if j > len(query) {
+				j = len(query)
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: query[i+1 : j]})
+			i = j + 1
+		case c == '?':
+			tokens = append(tokens, sqlToken{kind: tParam, text: "?"})
+			i++
+		case isWordByte(c):
+			j := i
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			kind := tWord
+			if c >= '0' && c <= '9' {
+				kind = tNumber
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: query[i:j]})
+			i = j
+		default:
+			if len(query) > i+1 {
+				if op := query[i : i+2]; op == "<=" || op == ">=" || op == "<>" || op == "!=" {
+					tokens = append(tokens, sqlToken{kind: tPunct, text: op})
+					i += 2
+					continue
+				}
+			}
+			tokens = append(tokens, sqlToken{kind: tPunct, text: query[i : i+1]})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// 不会是表名、别名和字段的关键字
+var keywords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`SELECT FROM WHERE AND OR NOT NULL IS IN LIKE BETWEEN ORDER BY GROUP HAVING
+		LIMIT OFFSET ASC DESC AS ON JOIN LEFT RIGHT INNER OUTER CROSS STRAIGHT_JOIN NATURAL INSERT INTO VALUES VALUE
+		UPDATE SET DELETE DISTINCT CASE WHEN THEN ELSE END TRUE FALSE INTERVAL USING FOR SHARE LOCK MODE UNION ALL
+		EXISTS DUPLICATE KEY IGNORE REPLACE WITH RECURSIVE DEFAULT ESCAPE REGEXP RLIKE DIV MOD XOR BINARY COLLATE
+		FORCE USE INDEX DUAL WINDOW OVER PARTITION ROWS RANGE LOW_PRIORITY HIGH_PRIORITY QUICK SQL_CALC_FOUND_ROWS`) {
+		keywords[w] = true
+	}
+}
+
+func isKeyword(t sqlToken) bool {
+	return t.kind == tWord && keywords[strings.ToUpper(t.text)]
+}
+
+// 单元是否为指定的关键字
+func isWord(t sqlToken, word string) bool {
+	return t.kind == tWord && strings.EqualFold(t.text, word)
+}
+
+// 可以作为名称的单元
+func isName(t sqlToken) bool {
+	return t.kind == tQuoted || t.kind == tWord && !isKeyword(t)
+}
+
+// 比较运算符
+func isCompare(t sqlToken) bool {
+	switch t.text {
+	case "=", "!=", "<>", "<", ">", "<=", ">=":
+		return t.kind == tPunct
+	}
+	return isWord(t, "LIKE") || isWord(t, "IN") || isWord(t, "BETWEEN") || isWord(t, "IS")
+}
+
+// 按字段名查找
+type schema struct {
+	dbname string
+	tables map[string]map[string]db.ColumnSchema
+}
+
+func newSchema(s *db.SchemaSnapshot) *schema {
+	sc := &schema{tables: make(map[string]map[string]db.ColumnSchema)}
+	for _, t := range s.Tables {
+		sc.dbname = t.Database
+		columns := make(map[string]db.ColumnSchema, len(t.Columns))
+		for _, c := range t.Columns {
+			columns[strings.ToLower(c.Name)] = c
+		}
+		sc.tables[strings.ToLower(t.Name)] = columns
+	}
+	return sc
+}
+
+// 语句检查的结果
+type analysis struct {
+	problems []string
+	//占位符的个数
+	params int
+	//占位符对应的字段
+	columns map[int]db.ColumnSchema
+}
+
+func (a *analysis) report(format string, args ...interface{}) {
+	a.problems = append(a.problems, fmt.Sprintf(format, args...))
+}
+
+// 检查语句中的表和字段
+func (sc *schema) analyze(query string) *analysis {
+	a := &analysis{columns: make(map[int]db.ColumnSchema)}
+	tokens := tokenize(query)
+	//占位符的序号
+	ordinal := make(map[int]int)
+	for i, t := range tokens {
+		if t.kind == tParam {
+			ordinal[i] = a.params
+			a.params++
+		}
+	}
+	//WITH 定义的名称和 AS 定义的别名
+	defined := make(map[string]bool)
+	for i := 0; i+1 < len(tokens); i++ {
+		if isWord(tokens[i], "AS") && isName(tokens[i+1]) {
+			defined[strings.ToLower(tokens[i+1].text)] = true
+		}
+		if i+2 < len(tokens) && isName(tokens[i]) && isWord(tokens[i+1], "AS") && tokens[i+2].text == "(" {
+			defined[strings.ToLower(tokens[i].text)] = true
+		}
+	}
+	//别名或表名对应的表
+	aliases := make(map[string]string)
+	//被跳过的表，例如其他数据库中的表和子查询
+	opaque := false
+	//INSERT 的字段列表
+	var insertColumns []string
+	insertTable := ""
+	for i := 0; i < len(tokens); i++ {
+		if !(isWord(tokens[i], "FROM") || isWord(tokens[i], "JOIN") || isWord(tokens[i], "UPDATE") || isWord(tokens[i], "INTO")) {
+			continue
+		}
+		//ON DUPLICATE KEY UPDATE 和 EXTRACT(YEAR FROM ...) 之类的函数参数
+		if i > 0 && (isWord(tokens[i-1], "KEY") || tokens[i-1].kind == tString) || i > 1 && tokens[i-2].text == "(" {
+			continue
+		}
+		for j := i + 1; j < len(tokens); {
+			if tokens[j].text == "(" {
+				opaque = true
+				break
+			}
+			if !isName(tokens[j]) {
+				break
+			}
+			name := strings.ToLower(tokens[j].text)
+			other := false
+			if j+2 < len(tokens) && tokens[j+1].text == "." && isName(tokens[j+2]) {
+				other = sc.dbname != "" && !strings.EqualFold(tokens[j].text, sc.dbname)
+				j += 2
+				name = strings.ToLower(tokens[j].text)
+			}
+			j++
+			alias := ""
+			if j < len(tokens) && isWord(tokens[j], "AS") {
+				j++
+			}
+			if j < len(tokens) && isName(tokens[j]) {
+				alias = strings.ToLower(tokens[j].text)
+				j++
+			}
+			switch _, known := sc.tables[name]; {
+			case other || defined[name]:
+				opaque = true
+			case !known:
+				a.report("unknown table (%s)", name)
+				opaque = true
+			default:
+				aliases[name] = name
+				if alias != "" {
+					aliases[alias] = name
+				}
+				if isWord(tokens[i], "INTO") {
+					insertTable = name
+					insertColumns = nameList(tokens[j:])
+				}
+			}
+			if j+1 < len(tokens) && tokens[j].text == "," && isName(tokens[j+1]) && !isWord(tokens[i], "INTO") {
+				j++
+				continue
+			}
+			break
+		}
+	}
+	//只有一个表时检查不带表名的字段
+	single := ""
+	if !opaque {
+		for _, table := range aliases {
+			if single != "" && single != table {
+				single = ""
+				break
+			}
+			single = table
+		}
+	}
+	for i := 0; i < len(tokens); i++ {
+		var table, column string
+		switch {
+		case i+2 < len(tokens) && isName(tokens[i]) && tokens[i+1].text == "." && (isName(tokens[i+2]) || tokens[i+2].text == "*"):
+			table = aliases[strings.ToLower(tokens[i].text)]
+			column = tokens[i+2].text
+			i += 2
+		case single != "" && isName(tokens[i]) && i+1 < len(tokens) && (isCompare(tokens[i+1]) || isWord(tokens[i+1], "NOT")) &&
+			(i == 0 || tokens[i-1].text != "@" && tokens[i-1].text != "."):
+			if defined[strings.ToLower(tokens[i].text)] {
+				continue
+			}
+			table, column = single, tokens[i].text
+		default:
+			continue
+		}
+		if table == "" || column == "*" {
+			continue
+		}
+		c, ok := sc.tables[table][strings.ToLower(column)]
+		if !ok {
+			a.report("unknown column (%s) in table (%s)", column, table)
+			continue
+		}
+		for _, p := range comparedParams(tokens, i+1) {
+			a.columns[ordinal[p]] = c
+		}
+	}
+	if insertTable != "" {
+		for k, column := range insertColumns {
+			c, ok := sc.tables[insertTable][strings.ToLower(column)]
+			if !ok {
+				a.report("unknown column (%s) in table (%s)", column, insertTable)
+				continue
+			}
+			if p, ok := valueParam(tokens, k); ok {
+				a.columns[ordinal[p]] = c
+			}
+		}
+	}
+	return a
+}
+
+// 括号中用逗号分隔的名称，没有括号时返回 nil
+func nameList(tokens []sqlToken) []string {
+	if len(tokens) == 0 || tokens[0].text != "(" {
+		return nil
+	}
+	names := make([]string, 0)
+	for _, t := range tokens[1:] {
+		switch {
+		case t.text == ")":
+			return names
+		case isName(t):
+			names = append(names, t.text)
+		case t.text != ",":
+			return nil
+		}
+	}
+	return nil
+}
+
+// 比较运算符之后直接与字段比较的占位符的位置
+func comparedParams(tokens []sqlToken, i int) []int {
+	if i < len(tokens) && isWord(tokens[i], "NOT") {
+		i++
+	}
+	if i >= len(tokens) || !isCompare(tokens[i]) {
+		return nil
+	}
+	op := strings.ToUpper(tokens[i].text)
+	i++
+	switch {
+	case op == "IN" && i < len(tokens) && tokens[i].text == "(":
+		params := make([]int, 0)
+		for i++; i < len(tokens) && tokens[i].text != ")"; i++ {
+			if tokens[i].kind == tParam {
+				params = append(params, i)
+			} else if tokens[i].text != "," {
+				return nil
+			}
+		}
+		return params
+	case op == "BETWEEN":
+		if i+2 < len(tokens) && tokens[i].kind == tParam && isWord(tokens[i+1], "AND") && tokens[i+2].kind == tParam {
+			return []int{i, i + 2}
+		}
+	case i < len(tokens) && tokens[i].kind == tParam:
+		return []int{i}
+	}
+	return nil
+}
+
+// VALUES 第一组中第 k 个值为单独的占位符时返回它的位置
+func valueParam(tokens []sqlToken, k int) (int, bool) {
+	for i := range tokens {
+		if !(isWord(tokens[i], "VALUES") || isWord(tokens[i], "VALUE")) || i+1 >= len(tokens) || tokens[i+1].text != "(" {
+			continue
+		}
+		n, depth, start := 0, 0, i+2
+		for j := i + 2; j < len(tokens); j++ {
+			switch tokens[j].text {
+			case "(":
+				depth++
+			case ")", ",":
+				if tokens[j].text == ")" && depth > 0 {
+					depth--
+					continue
+				}
+				if depth > 0 {
+					continue
+				}
+				if n == k {
+					return start, j == start+1 && tokens[start].kind == tParam
+				}
+				if tokens[j].text == ")" {
+					return 0, false
+				}
+				n++
+				start = j + 1
+			}
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+// 字段类型的大类
+func typeClass(typ string) string {
+	typ = strings.ToLower(typ)
+	switch {
+	case strings.Contains(typ, "int"):
+		return "integer"
+	case strings.HasPrefix(typ, "decimal"), strings.HasPrefix(typ, "numeric"), strings.HasPrefix(typ, "float"), strings.HasPrefix(typ, "double"):
+		return "number"
+	case strings.HasPrefix(typ, "date"), strings.HasPrefix(typ, "time"), strings.HasPrefix(typ, "year"):
+		return "time"
+	}
+	return "string"
+}
+
+// 检查字面量参数与字段类型是否匹配，kind 为 go/token 的 STRING、INT 或 FLOAT
+func checkLiteral(c db.ColumnSchema, kind, value string) string {
+	class := typeClass(c.Type)
+	switch kind {
+	case "STRING":
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return ""
+		}
+		if class == "integer" || class == "number" {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil {
+				return fmt.Sprintf("the string %s can't be compared with the %s column (%s)", value, c.Type, c.Name)
+			}
+		}
+	case "INT":
+		if class == "time" {
+			return fmt.Sprintf("the number %s is passed to the %s column (%s)", value, c.Type, c.Name)
+		}
+	case "FLOAT":
+		if class == "integer" || class == "time" {
+			return fmt.Sprintf("the float %s is passed to the %s column (%s)", value, c.Type, c.Name)
+		}
+	}
+	return ""
+}
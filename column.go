@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Pluck 只查询一个字段，返回的 Rows 用 Int64s、Strings 或 Times 读取
+//
+//	rows, err := t.Select(db.Raw("`status`=?", 1)).Pluck("id")
+//	if err != nil {
+//		return err
+//	}
+//	ids, err := rows.Int64s()
+func (s *Selector) Pluck(column string) (*Rows, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	i, err := s.t.indexOf(column)
+	if err != nil {
+		return nil, err
+	}
+	fields := s.fields
+	s.fields = []string{s.t.Fields[i].FullName}
+	query, args, err := s.sql(false)
+	s.fields = fields
+	if err != nil {
+		return nil, err
+	}
+	rows, cancel, err := s.t.queryRows(limitQuery(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return (&Rows{Rows: rows, t: s.t, cancel: cancel}).guard().watch(), nil
+}
+
+// 逐行读取单列的结果，scan 读取当前行
+func (rs *Rows) collectColumn(scan func() error) error {
+	defer rs.Close()
+	for rs.Next() {
+		if err := scan(); err != nil {
+			return err
+		}
+	}
+	return rs.Err()
+}
+
+// Int64s 读取单列的结果为 []int64，NULL 为 0，读完后关闭结果集
+//
+// 结果集只能有一列，例如 Selector.Pluck 的结果。
+func (rs *Rows) Int64s() ([]int64, error) {
+	values := make([]int64, 0)
+	var v sql.NullInt64
+	err := rs.collectColumn(func() error {
+		if err := rs.source().Scan(&v); err != nil {
+			return err
+		}
+		values = append(values, v.Int64)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Strings 读取单列的结果为 []string，NULL 为空字符串，读完后关闭结果集
+func (rs *Rows) Strings() ([]string, error) {
+	values := make([]string, 0)
+	var v sql.NullString
+	err := rs.collectColumn(func() error {
+		if err := rs.source().Scan(&v); err != nil {
+			return err
+		}
+		values = append(values, v.String)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Times 读取单列的结果为 []time.Time，NULL 和零值日期为 time.Time{}，读完后关闭结果集
+func (rs *Rows) Times() ([]time.Time, error) {
+	values := make([]time.Time, 0)
+	var v NullTime
+	err := rs.collectColumn(func() error {
+		if err := rs.source().Scan(&v); err != nil {
+			return err
+		}
+		if rs.t.loc != nil && v.Valid {
+			v.Time = Reinterpret(v.Time, rs.t.loc)
+		}
+		values = append(values, v.Time)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// CommentOptions 在语句前加上 sqlcommenter 风格的注释，便于在慢查询日志中定位调用方
+//
+//	/* caller='main.handler',service='api',trace_id='abc' */ SELECT ...
+type CommentOptions struct {
+	//服务名
+	Service string
+	//记录包外的调用函数
+	Caller bool
+	//从上下文中读取其他标签，例如 trace_id
+	Tags func(ctx context.Context) map[string]string
+}
+
+var queryComment atomic.Value
+
+// SetQueryComment 设置语句注释，opt 为 nil 时关闭
+//
+// 只作用于经过包内连接池执行的语句，预编译语句和事务中的语句不加注释。
+func SetQueryComment(opt *CommentOptions) {
+	queryComment.Store(opt)
+}
+
+type tagsKey struct{}
+
+// WithTag 在上下文中附加一个注释标签，与 WithContext 一起使用
+func WithTag(ctx context.Context, key, value string) context.Context {
+	tags := make(map[string]string)
+	if old, ok := ctx.Value(tagsKey{}).(map[string]string); ok {
+		for k, v := range old {
+			tags[k] = v
+		}
+	}
+	tags[key] = value
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// 包路径，用于跳过包内的调用栈
+var pkgPath = reflect.TypeOf(Table{}).PkgPath() + "."
+
+// 包外第一个调用函数
+func callerName() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPath) {
+			return frame.Function
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// 按设置给语句加上注释
+func commentQuery(ctx context.Context, query string) string {
+	opt, _ := queryComment.Load().(*CommentOptions)
+	if opt == nil {
+		return query
+	}
+	tags := make(map[string]string)
+	if opt.Service != "" {
+		tags["service"] = opt.Service
+	}
+	if opt.Caller {
+		if name := callerName(); name != "" {
+			tags["caller"] = name
+		}
+	}
+	if opt.Tags != nil {
+		for k, v := range opt.Tags(ctx) {
+			tags[k] = v
+		}
+	}
+	if ctxTags, ok := ctx.Value(tagsKey{}).(map[string]string); ok {
+		for k, v := range ctxTags {
+			tags[k] = v
+		}
+	}
+	if len(tags) == 0 {
+		return query
+	}
+	items := make([]string, 0, len(tags))
+	for k, v := range tags {
+		items = append(items, escapeTag(k)+"='"+escapeTag(v)+"'")
+	}
+	sort.Strings(items)
+	return "/* " + strings.Join(items, ",") + " */ " + query
+}
+
+// 按 sqlcommenter 的规则编码，编码后不含引号和注释结束符
+func escapeTag(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
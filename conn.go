@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+// 当前的连接池，Open 之前返回的连接池上所有语句都返回 ErrNotOpen
+func conn() *sql.DB {
+	dbMu.RLock()
+	defer dbMu.RUnlock()
+	if db == nil {
+		return notOpen
+	}
+	return db
+}
+
+// 在指定的连接池上执行，所有查询都经过这里
+//
+// 结果集在 ctx 取消后无法读取，超时的计时器到期后才释放；表的查询通过 queryCancelOn 在 Rows.Close 时释放。
+func queryOn(sqldb *sql.DB, ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return queryStmtOn(sqldb, nil, ctx, query, args...)
+}
+
+// 与 queryOn 相同，stmt 不为 nil 时使用 query 预编译的语句
+//
+// 发送的语句与 query 不同（加了注释或超时提示）或需要固定连接时不使用 stmt。
+func queryStmtOn(sqldb *sql.DB, stmt *sql.Stmt, ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, _, err := queryCancelOn(sqldb, stmt, ctx, query, args...)
+	return rows, err
+}
+
+// 与 queryStmtOn 相同，同时返回释放超时计时器的 cancel，结果集关闭后调用
+func queryCancelOn(sqldb *sql.DB, stmt *sql.Stmt, ctx context.Context, query string, args ...interface{}) (*sql.Rows, context.CancelFunc, error) {
+	prepared := query
+	ctx, cancel := timeoutContext(ctx)
+	ctx = budgetContext(policyContext(ctx, query), query)
+	query = defaultExecutionTime(query)
+	release, err := acquireSlot(sqldb, ctx, false)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	finish, err := breakerAllowCtx(sqldb, ctx)
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		cancel()
+		return nil, nil, err
+	}
+	start := time.Now()
+	var rows *sql.Rows
+	sent := routeQuery(ctx, commentQuery(ctx, query))
+	//读取结果集时仍然占用名额，限制并发时在固定的连接上查询，结果集关闭后才归还
+	if kill := killable(ctx); kill || release != nil {
+		rows, err = queryPinned(sqldb, ctx, kill, release, sent, convertArgs(args)...)
+	} else if stmt != nil && sent == prepared {
+		rows, err = stmt.QueryContext(ctx, convertArgs(args)...)
+	} else {
+		rows, err = sqldb.QueryContext(ctx, sent, convertArgs(args)...)
+	}
+	finish(err)
+	observeSlow(sqldb, query, args, start)
+	logQuery(ctx, query, args, start, err)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return rows, cancel, nil
+}
+
+func queryRowOn(sqldb *sql.DB, ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return queryRowStmtOn(sqldb, nil, ctx, query, args...)
+}
+
+// 与 queryRowOn 相同，stmt 的用法见 queryStmtOn
+func queryRowStmtOn(sqldb *sql.DB, stmt *sql.Stmt, ctx context.Context, query string, args ...interface{}) *sql.Row {
+	prepared := query
+	//*sql.Row 在 Scan 时才读取结果，超时的计时器到期后释放
+	ctx, _ = timeoutContext(ctx)
+	ctx = budgetContext(policyContext(ctx, query), query)
+	query = defaultExecutionTime(query)
+	release, err := acquireSlot(sqldb, ctx, false)
+	if err != nil {
+		//*sql.Row 通过已经结束的上下文带上错误
+		ctx = rejectedCtx{Context: ctx, err: err}
+	}
+	finish, err := breakerAllowCtx(sqldb, ctx)
+	if err != nil {
+		ctx, finish = rejectedCtx{Context: ctx, err: err}, noFinish
+		if release != nil {
+			release()
+			release = nil
+		}
+	}
+	start := time.Now()
+	var row *sql.Row
+	sent := routeQuery(ctx, commentQuery(ctx, query))
+	if release != nil {
+		row = queryRowPinned(sqldb, ctx, release, sent, convertArgs(args)...)
+	} else if stmt != nil && sent == prepared {
+		row = stmt.QueryRowContext(ctx, convertArgs(args)...)
+	} else {
+		row = sqldb.QueryRowContext(ctx, sent, convertArgs(args)...)
+	}
+	finish(row.Err())
+	observeSlow(sqldb, query, args, start)
+	logQuery(ctx, query, args, start, row.Err())
+	return row
+}
+
+func execOn(sqldb *sql.DB, ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return execVia(sqldb, ctx, func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+		return execWarn(sqldb, ctx, query, args...)
+	}, query, args...)
+}
+
+// 与 execOn 相同，stmt 的用法见 queryStmtOn，检查警告时不使用 stmt
+func execStmtOn(sqldb *sql.DB, stmt *sql.Stmt, ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	prepared := query
+	return execVia(sqldb, ctx, func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+		if mode, _ := warningSettings(); stmt != nil && query == prepared && mode == WarningsIgnore {
+			return stmt.ExecContext(ctx, args...)
+		}
+		return execWarn(sqldb, ctx, query, args...)
+	}, query, args...)
+}
+
+// 在事务中执行，与 execOn 经过相同的检查和记录，sqldb 为开始事务的连接池
+func execTxOn(sqldb *sql.DB, tx *sql.Tx, ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return execVia(sqldb, ctx, func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+		return execWarnTx(tx, ctx, query, args...)
+	}, query, args...)
+}
+
+// 修改语句的公共部分，run 在连接池或事务上执行
+func execVia(sqldb *sql.DB, ctx context.Context, run func(ctx context.Context, query string, args ...interface{}) (sql.Result, error), query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := timeoutContext(ctx)
+	defer cancel()
+	ctx = budgetContext(policyContext(ctx, query), query)
+	release, err := acquireSlot(sqldb, ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if release != nil {
+		defer release()
+	}
+	finish, err := breakerAllowCtx(sqldb, ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := run(ctx, routeQuery(ctx, commentQuery(ctx, query)), convertArgs(args)...)
+	finish(err)
+	observeSlow(sqldb, query, args, start)
+	logQuery(ctx, query, args, start, err)
+	return res, err
+}
+
+func prepareOn(sqldb *sql.DB, ctx context.Context, query string) (*sql.Stmt, error) {
+	return sqldb.PrepareContext(policyContext(ctx, query), query)
+}
+
+// 当前的数据库名
+func dbName() string {
+	dbMu.RLock()
+	defer dbMu.RUnlock()
+	return db_name
+}
+
+// 替换连接池
+//
+// 旧连接池在后台关闭，正在执行的语句完成后才断开，替换之后的语句都使用新的连接池。
+func setConn(sqldb *sql.DB, name string) {
+	dbMu.Lock()
+	old := db
+	db, db_name = sqldb, name
+	dbMu.Unlock()
+	if old != nil && old != sqldb {
+		go old.Close()
+	}
+}
+
+func setDbName(name string) {
+	dbMu.Lock()
+	db_name = name
+	dbMu.Unlock()
+}
+
+// 读写 time.Duration 类型的全局设置
+type durationSetting struct {
+	v int64
+}
+
+func (s *durationSetting) load() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.v))
+}
+
+func (s *durationSetting) store(d time.Duration) {
+	atomic.StoreInt64(&s.v, int64(d))
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 用 go test -race 运行，检查包级连接状态和表在并发读写时没有数据竞争
+func TestConcurrentCRUD(t *testing.T) {
+	users := openFake(t, 3)
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if _, err := users.Add(nil, "name", int64(i)); err != nil {
+					errs <- err
+					return
+				}
+				var u benchUser
+				if err := users.Get(int64(1)).Struct(&u); err != nil {
+					errs <- err
+					return
+				}
+				rs, err := users.GetMany()
+				if err != nil {
+					errs <- err
+					return
+				}
+				for rs.Next() {
+					if _, err = rs.Map(); err != nil {
+						errs <- err
+						return
+					}
+				}
+				if _, err = users.Count(); err != nil {
+					errs <- err
+					return
+				}
+				if _, err = users.Update(int64(1)).Values(nil, "renamed", int64(g)); err != nil {
+					errs <- err
+					return
+				}
+				if _, err = users.Del(int64(2)); err != nil {
+					errs <- err
+					return
+				}
+				if _, err = users.WithContext(context.Background()).Exists(int64(1)); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConcurrentGetTableAndUse(t *testing.T) {
+	openFake(t, 3)
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := 0; g < 4; g++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				//刚取得的旧连接池可能已经被替换并关闭
+				if _, err := GetTable("users"); err != nil && !strings.Contains(err.Error(), "database is closed") {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := Use("test"); err != nil && !strings.Contains(err.Error(), "database is closed") {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			//重新连接时替换连接池
+			for i := 0; i < 10; i++ {
+				sqldb, err := sql.Open("dbtest", "")
+				if err != nil {
+					errs <- err
+					return
+				}
+				setConn(sqldb, "test")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestUseUnknownDatabase(t *testing.T) {
+	openFake(t, 0)
+	if err := Use("missing"); err == nil {
+		t.Fatal("Use of an unknown database should fail")
+	}
+	if name := dbName(); name != "test" {
+		t.Fatalf("the database name changed to %q after a failed Use", name)
+	}
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type connKey struct{}
+
+// With 返回带有连接池的 ctx
+//
+// QueryContext、ExecContext、BeginTx 等包级函数以及 WithContext 得到的表，
+// 在 ctx 带有连接池时使用该连接池，否则使用 Open 打开的连接池。
+// 适用于按请求切换数据库的场景，也方便在测试中替换连接。
+func With(ctx context.Context, sqldb *sql.DB) context.Context {
+	return context.WithValue(ctx, connKey{}, sqldb)
+}
+
+// ctx 中的连接池，没有时返回 Open 打开的连接池
+func connFrom(ctx context.Context) *sql.DB {
+	if ctx != nil {
+		if sqldb, ok := ctx.Value(connKey{}).(*sql.DB); ok && sqldb != nil {
+			return sqldb
+		}
+	}
+	return conn()
+}
+
+// ctx 中是否带有连接池
+func hasConn(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	sqldb, ok := ctx.Value(connKey{}).(*sql.DB)
+	return ok && sqldb != nil
+}
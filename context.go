@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// WithContext 返回使用 ctx 执行查询的表
+//
+// 返回的是副本，原来的表不受影响。设置了 Tenancy 且 ctx 中带有租户时，
+// 副本的所有语句都指向该租户的数据库。租户的数据库名不是合法的标识符时，
+// 副本的所有语句都返回 ErrInvalidTenant，也不读取缓存。
+func (t Table) WithContext(ctx context.Context) *Table {
+	t.ctx = ctx
+	if schema := tenantSchema(ctx); schema != "" && schema != t.DbName {
+		if !reSchema.MatchString(schema) {
+			t.ctx = rejectedCtx{Context: ctx, err: ErrInvalidTenant}
+			t.rowCache, t.resultTTL, t.flight = nil, 0, 0
+			return &t
+		}
+		t.DbName = schema
+		t.prepareSql()
+	}
+	return &t
+}
+
+func (t Table) context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// 表使用的连接池
+func (t Table) sqlDB() *sql.DB {
+	if t.db != nil {
+		return t.db
+	}
+	return connFrom(t.ctx)
+}
+
+func (t Table) query(query string, args ...interface{}) (*sql.Rows, error) {
+	rows, _, err := t.queryRows(query, args...)
+	return rows, err
+}
+
+// 与 query 相同，同时返回释放超时计时器的 cancel，由 Rows.Close 调用
+func (t Table) queryRows(query string, args ...interface{}) (*sql.Rows, context.CancelFunc, error) {
+	args = t.localizeArgs(args)
+	start := time.Now()
+	sqldb := t.readDB()
+	rows, cancel, err := queryCancelOn(sqldb, nil, t.logContext(t.context()), query, args...)
+	recordStats(t.Fullname, query, start, err)
+	return rows, cancel, diagnoseDeadlock(sqldb, t.wrapErr(query, err))
+}
+
+func (t Table) queryRow(query string, args ...interface{}) *sql.Row {
+	args = t.localizeArgs(args)
+	start := time.Now()
+	row := queryRowOn(t.readDB(), t.logContext(t.context()), query, args...)
+	recordStats(t.Fullname, query, start, row.Err())
+	return row
+}
+
+func (t Table) exec(query string, args ...interface{}) (sql.Result, error) {
+	args = t.localizeArgs(args)
+	start := time.Now()
+	sqldb := t.sqlDB()
+	res, err := execOn(sqldb, t.logContext(t.context()), query, args...)
+	recordStats(t.Fullname, query, start, err)
+	if err == nil {
+		t.trackWrite(sqldb)
+	}
+	return res, diagnoseDeadlock(sqldb, t.wrapErr(query, err))
+}
+
+// 在事务中执行，与 exec 相同，trackWrite 由调用者在提交后调用
+func (t Table) execTx(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	args = t.localizeArgs(args)
+	start := time.Now()
+	sqldb := t.sqlDB()
+	res, err := execTxOn(sqldb, tx, t.logContext(t.context()), query, args...)
+	recordStats(t.Fullname, query, start, err)
+	return res, diagnoseDeadlock(sqldb, t.wrapErr(query, err))
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// 读取一行，并对读到的值做解密等处理
+func (t Table) scan(s scanner, scans []interface{}) error {
+	if err := s.Scan(scans...); err != nil {
+		return err
+	}
+	if t.cipher != nil {
+		if err := t.cipher.decrypt(scans); err != nil {
+			return err
+		}
+	}
+	if t.loc != nil {
+		t.localizeScans(scans)
+	}
+	if t.masks != nil && !isUnmasked(t.context()) {
+		t.mask(scans)
+	}
+	return nil
+}
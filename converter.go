@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// 自定义类型的转换
+type converter struct {
+	scan  func(src interface{}) (interface{}, error)
+	value func(v interface{}) (driver.Value, error)
+}
+
+var converters sync.Map
+
+// RegisterConverter 注册自定义类型 T 的转换，不需要 T 实现 sql.Scanner 和 driver.Valuer
+//
+// scan 把读到的值转换为 T，src 为 nil（NULL）、int64、float64、bool、[]byte、string 或 time.Time；
+// value 把 T 转换为写入的值，用于 Add、Update 和查询条件的参数。任一个为 nil 时不转换该方向。
+// 注册的转换优先于 T 自身的 Scan 和 Value，结构体类型注册后作为一个字段，不再展开。
+// 应在使用前注册，已经读取过的结构体映射不会更新。
+//
+//	db.RegisterConverter(func(src interface{}) (Money, error) {
+//		return ParseMoney(fmt.Sprint(src))
+//	}, func(m Money) (driver.Value, error) {
+//		return m.String(), nil
+//	})
+func RegisterConverter[T any](scan func(src interface{}) (T, error), value func(v T) (driver.Value, error)) {
+	var c converter
+	if scan != nil {
+		c.scan = func(src interface{}) (interface{}, error) {
+			return scan(src)
+		}
+	}
+	if value != nil {
+		c.value = func(v interface{}) (driver.Value, error) {
+			return value(v.(T))
+		}
+	}
+	converters.Store(reflect.TypeOf((*T)(nil)).Elem(), c)
+}
+
+func converterOf(typ reflect.Type) (converter, bool) {
+	if c, ok := converters.Load(typ); ok {
+		return c.(converter), true
+	}
+	return converter{}, false
+}
+
+// 用注册的转换读取到 dest，没有注册时返回 false
+func scanConverted(dest interface{}, src interface{}) (bool, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr {
+		return false, nil
+	}
+	c, ok := converterOf(rv.Type().Elem())
+	if !ok || c.scan == nil {
+		return false, nil
+	}
+	if rv.IsNil() {
+		return true, ErrNilPtr
+	}
+	v, err := c.scan(parseValue(src))
+	if err != nil {
+		return true, fmt.Errorf("db: convert %T to %s: %s", src, rv.Type().Elem(), err)
+	}
+	if v == nil {
+		rv.Elem().Set(reflect.Zero(rv.Type().Elem()))
+		return true, nil
+	}
+	rv.Elem().Set(reflect.ValueOf(v))
+	return true, nil
+}
+
+// 注册了转换的参数
+type convertedArg struct {
+	v     interface{}
+	value func(v interface{}) (driver.Value, error)
+}
+
+func (a convertedArg) Value() (driver.Value, error) {
+	return a.value(a.v)
+}
+
+// 把注册了转换的参数包装为 driver.Valuer
+func convertArgs(args []interface{}) []interface{} {
+	var converted []interface{}
+	for i, arg := range args {
+		if arg == nil {
+			continue
+		}
+		c, ok := converterOf(reflect.TypeOf(arg))
+		if !ok || c.value == nil {
+			continue
+		}
+		if converted == nil {
+			converted = make([]interface{}, len(args))
+			copy(converted, args)
+		}
+		converted[i] = convertedArg{v: arg, value: c.value}
+	}
+	if converted == nil {
+		return args
+	}
+	return converted
+}
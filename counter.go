@@ -0,0 +1,58 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// CounterTableSql 计数表的建表语句
+func CounterTableSql(name string) string {
+	return strings.Join([]string{
+		fmt.Sprintf("CREATE TABLE %s (", name),
+		"\t`name` varchar(128) NOT NULL,",
+		"\t`shard` int(11) NOT NULL,",
+		"\t`value` bigint(20) NOT NULL,",
+		"\tPRIMARY KEY (`name`, `shard`)",
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+	}, "\n")
+}
+
+// Counters 存放在计数表中的计数器
+//
+// shards 大于 1 时每个计数器分散为多行，增加时随机选择一行，读取时求和，
+// 避免高频计数器的单行锁竞争。
+type Counters struct {
+	table  string
+	shards int
+}
+
+// NewCounters 使用 table 表存放计数器，表结构见 CounterTableSql
+func NewCounters(table string, shards int) *Counters {
+	if shards < 1 {
+		shards = 1
+	}
+	return &Counters{table: table, shards: shards}
+}
+
+// Increment 计数器增加 delta，delta 可以为负数
+func (c *Counters) Increment(name string, delta int64) error {
+	shard := 0
+	if c.shards > 1 {
+		shard = rand.Intn(c.shards)
+	}
+	_, err := Exec(fmt.Sprintf("INSERT INTO %s (`name`, `shard`, `value`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `value` = `value` + VALUES(`value`)", c.table),
+		name, shard, delta)
+	return err
+}
+
+// Get 计数器的值，不存在时为 0
+func (c *Counters) Get(name string) (int64, error) {
+	return ScalarInt64(fmt.Sprintf("SELECT COALESCE(SUM(`value`), 0) FROM %s WHERE `name` = ?", c.table), name)
+}
+
+// Reset 删除计数器
+func (c *Counters) Reset(name string) error {
+	_, err := Exec(fmt.Sprintf("DELETE FROM %s WHERE `name` = ?", c.table), name)
+	return err
+}
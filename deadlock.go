@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL 死锁的错误码
+const errDeadlock = 1213
+
+var deadlockDiagnostics int32
+
+// SetDeadlockDiagnostics 打开或关闭死锁诊断
+//
+// 打开后通过 Table 执行的语句因死锁失败时，读取 SHOW ENGINE INNODB STATUS 中最近一次死锁的信息，
+// 返回的错误为 *DeadlockError。读取需要 PROCESS 权限，读取失败时返回原来的错误。
+func SetDeadlockDiagnostics(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&deadlockDiagnostics, v)
+}
+
+// DeadlockTransaction 死锁中的一个事务
+type DeadlockTransaction struct {
+	//死锁信息中的序号，从 1 开始
+	Number int
+	//事务 id
+	Id string
+	//连接 id，与 Process.Id 相同
+	ThreadId int64
+	//事务正在执行的语句
+	Query string
+	//持有的锁，MySQL 5.7 及之前只有被回滚的事务之外的一方有这一项
+	Holds string
+	//等待的锁
+	Waits string
+}
+
+// Deadlock 最近一次死锁的信息
+type Deadlock struct {
+	//服务器记录的时间
+	Time         string
+	Transactions []DeadlockTransaction
+	//被回滚的事务序号，没有记录时为 0
+	RolledBack int
+	//原始的文本
+	Raw string
+}
+
+// DeadlockError 带有死锁信息的错误，errors.Unwrap 返回原来的错误
+type DeadlockError struct {
+	Err      error
+	Deadlock *Deadlock
+}
+
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("%v\n%s", e.Err, e.Deadlock)
+}
+
+func (e *DeadlockError) Unwrap() error {
+	return e.Err
+}
+
+func (d *Deadlock) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "deadlock at %s", d.Time)
+	for _, tx := range d.Transactions {
+		fmt.Fprintf(&b, "\n(%d) transaction %s, thread %d: %s", tx.Number, tx.Id, tx.ThreadId, tx.Query)
+		if tx.Holds != "" {
+			fmt.Fprintf(&b, "\n    holds: %s", tx.Holds)
+		}
+		if tx.Waits != "" {
+			fmt.Fprintf(&b, "\n    waits: %s", tx.Waits)
+		}
+	}
+	if d.RolledBack > 0 {
+		fmt.Fprintf(&b, "\nrolled back transaction (%d)", d.RolledBack)
+	}
+	return b.String()
+}
+
+// LatestDeadlock 读取 Open 打开的服务器上最近一次死锁的信息，没有发生过死锁时返回 nil
+func LatestDeadlock() (*Deadlock, error) {
+	return latestDeadlock(conn(), context.Background())
+}
+
+func latestDeadlock(sqldb *sql.DB, ctx context.Context) (*Deadlock, error) {
+	var typ, name, status string
+	if err := sqldb.QueryRowContext(ctx, "SHOW ENGINE INNODB STATUS").Scan(&typ, &name, &status); err != nil {
+		return nil, err
+	}
+	return ParseDeadlock(status), nil
+}
+
+// ParseDeadlock 从 SHOW ENGINE INNODB STATUS 的输出中解析最近一次死锁，没有时返回 nil
+func ParseDeadlock(status string) *Deadlock {
+	const title = "LATEST DETECTED DEADLOCK"
+	i := strings.Index(status, title)
+	if i < 0 {
+		return nil
+	}
+	lines := strings.Split(status[i+len(title):], "\n")
+	//跳过标题下的分隔线，到下一个分隔线为止
+	for len(lines) > 0 && (strings.TrimSpace(lines[0]) == "" || isDashes(lines[0])) {
+		lines = lines[1:]
+	}
+	for k := range lines {
+		if isDashes(lines[k]) {
+			lines = lines[:k]
+			break
+		}
+	}
+	d := &Deadlock{Raw: strings.Join(lines, "\n")}
+	var tx *DeadlockTransaction
+	//当前读取的部分：语句、持有的锁、等待的锁
+	var part string
+	var query []string
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case d.Time == "" && tx == nil && strings.TrimSpace(line) != "":
+			//日期 时间 线程，只取前两项
+			fields := strings.Fields(line)
+			if len(fields) > 2 {
+				fields = fields[:2]
+			}
+			d.Time = strings.Join(fields, " ")
+		case strings.HasPrefix(line, "*** WE ROLL BACK TRANSACTION ("):
+			d.RolledBack = deadlockNumber(line)
+			part = ""
+		case strings.HasPrefix(line, "*** ("):
+			n := deadlockNumber(line)
+			if tx == nil || tx.Number != n {
+				d.Transactions = append(d.Transactions, DeadlockTransaction{Number: n})
+				tx = &d.Transactions[len(d.Transactions)-1]
+				query = nil
+			}
+			switch {
+			case strings.Contains(line, "HOLDS THE LOCK"):
+				part = "holds"
+			case strings.Contains(line, "WAITING FOR THIS LOCK"):
+				part = "waits"
+			default:
+				part = ""
+			}
+		case tx == nil:
+		case strings.HasPrefix(line, "TRANSACTION ") && tx.Id == "":
+			tx.Id = strings.TrimSuffix(strings.Fields(line)[1], ",")
+		case strings.HasPrefix(line, "MySQL thread id "):
+			fields := strings.Fields(strings.TrimPrefix(line, "MySQL thread id "))
+			if len(fields) > 0 {
+				tx.ThreadId, _ = strconv.ParseInt(strings.TrimSuffix(fields[0], ","), 10, 64)
+			}
+			part = "query"
+		case part == "query":
+			query = append(query, strings.TrimSpace(line))
+			tx.Query = strings.TrimSpace(strings.Join(query, " "))
+		case part == "holds" && tx.Holds == "" && isLockLine(line):
+			tx.Holds = line
+		case part == "waits" && tx.Waits == "" && isLockLine(line):
+			tx.Waits = line
+		}
+	}
+	return d
+}
+
+// 取 "*** (2) ..." 中的序号
+func deadlockNumber(line string) int {
+	i := strings.Index(line, "(")
+	j := strings.Index(line, ")")
+	if i < 0 || j < i {
+		return 0
+	}
+	n, _ := strconv.Atoi(line[i+1 : j])
+	return n
+}
+
+func isDashes(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= 4 && strings.Trim(line, "-") == ""
+}
+
+func isLockLine(line string) bool {
+	return strings.HasPrefix(line, "RECORD LOCKS") || strings.HasPrefix(line, "TABLE LOCK")
+}
+
+// 死锁时附上诊断信息
+func diagnoseDeadlock(sqldb *sql.DB, err error) error {
+	if err == nil || atomic.LoadInt32(&deadlockDiagnostics) == 0 {
+		return err
+	}
+	var e *mysql.MySQLError
+	if !errors.As(err, &e) || e.Number != errDeadlock {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d, derr := latestDeadlock(sqldb, ctx)
+	if derr != nil || d == nil {
+		return err
+	}
+	return &DeadlockError{Err: err, Deadlock: d}
+}
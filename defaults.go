@@ -0,0 +1,55 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// SetClientDefaults 插入时是否在客户端填充省略的字段
+//
+// 默认省略的字段不出现在 INSERT 中，由 MySQL 使用默认值，
+// 没有默认值的 NOT NULL 字段在严格模式下会报错。开启后 Add 按字段定义填充省略的值：
+// 字面量默认值原样使用，CURRENT_TIMESTAMP 使用当前时间，表达式默认值仍交给 MySQL 计算，
+// 没有默认值的 NOT NULL 字段使用类型的零值（日期时间类型除外），自增字段保持省略。
+func (t *Table) SetClientDefaults(on bool) {
+	t.clientDefaults = on
+}
+
+// 填充省略的字段，返回新的切片
+func (t Table) fillDefaults(values []interface{}) []interface{} {
+	filled := make([]interface{}, t.Len)
+	copy(filled, values)
+	now := time.Now()
+	for i := range t.Fields {
+		if filled[i] != nil {
+			continue
+		}
+		f := &t.Fields[i]
+		if strings.Contains(strings.ToLower(f.Extra), "auto_increment") {
+			continue
+		}
+		switch {
+		case f.Default.CurrentTimestamp:
+			filled[i] = now
+		case f.Default.Expression:
+		case !f.Default.Null:
+			filled[i] = f.Default.Value
+		case !f.Null:
+			filled[i] = zeroValue(f.Type.Value)
+		}
+	}
+	return filled
+}
+
+// 类型的零值，日期时间类型没有合适的零值，返回 nil
+func zeroValue(typevalue int) interface{} {
+	switch typevalue {
+	case TypeInt, TypeBigint:
+		return int64(0)
+	case TypeFloat, TypeDouble, TypeDecimal:
+		return float64(0)
+	case TypeChar, TypeVarchar, TypeText, TypeMediumText, TypeLongtext:
+		return ""
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 行差异的类型
+const (
+	//只在 b 中存在
+	DiffInserted = iota
+	//只在 a 中存在
+	DiffDeleted
+	//两边都存在但有字段不同
+	DiffChanged
+)
+
+// RowDiff 一行的差异
+type RowDiff struct {
+	Kind int
+	//键字段的值
+	Key []interface{}
+	//DiffChanged 时不同的字段
+	Columns []string
+}
+
+// DiffTables 按键字段比较两个表的数据，返回所有差异
+//
+// 两个表都按键排序后逐行归并，只比较同名字段。数据量大时使用 DiffTablesFunc。
+func DiffTables(a, b *Table, keyColumns []string) ([]RowDiff, error) {
+	diffs := make([]RowDiff, 0)
+	err := DiffTablesFunc(a, b, keyColumns, func(d RowDiff) error {
+		diffs = append(diffs, d)
+		return nil
+	})
+	return diffs, err
+}
+
+// DiffTablesFunc 按键字段比较两个表的数据，每个差异调用一次 fn，fn 返回错误时停止
+func DiffTablesFunc(a, b *Table, keyColumns []string, fn func(RowDiff) error) error {
+	if len(keyColumns) == 0 {
+		return fmt.Errorf("db: the key columns is empty")
+	}
+	keysA, err := a.diffKeys(keyColumns)
+	if err != nil {
+		return err
+	}
+	keysB, err := b.diffKeys(keyColumns)
+	if err != nil {
+		return err
+	}
+	//同名字段在两个表中的位置
+	common := make([][2]int, 0, a.Len)
+	for i := range a.Fields {
+		if j, err := b.indexOf(a.Fields[i].Name); err == nil {
+			common = append(common, [2]int{i, j})
+		}
+	}
+	ra, err := a.diffRows(keysA)
+	if err != nil {
+		return err
+	}
+	defer ra.Close()
+	rb, err := b.diffRows(keysB)
+	if err != nil {
+		return err
+	}
+	defer rb.Close()
+	scansA, scansB := a.makeNullableScans(), b.makeNullableScans()
+	okA, err := diffNext(ra, scansA)
+	if err != nil {
+		return err
+	}
+	okB, err := diffNext(rb, scansB)
+	if err != nil {
+		return err
+	}
+	for okA || okB {
+		var c int
+		switch {
+		case !okA:
+			c = 1
+		case !okB:
+			c = -1
+		default:
+			c = compareKeys(scansA, keysA, scansB, keysB)
+		}
+		switch {
+		case c < 0:
+			if err = fn(RowDiff{Kind: DiffDeleted, Key: keyValues(scansA, keysA)}); err != nil {
+				return err
+			}
+			if okA, err = diffNext(ra, scansA); err != nil {
+				return err
+			}
+		case c > 0:
+			if err = fn(RowDiff{Kind: DiffInserted, Key: keyValues(scansB, keysB)}); err != nil {
+				return err
+			}
+			if okB, err = diffNext(rb, scansB); err != nil {
+				return err
+			}
+		default:
+			var columns []string
+			for _, p := range common {
+				if !equalValue(parseValue(scansA[p[0]]), parseValue(scansB[p[1]])) {
+					columns = append(columns, a.Fields[p[0]].Name)
+				}
+			}
+			if columns != nil {
+				if err = fn(RowDiff{Kind: DiffChanged, Key: keyValues(scansA, keysA), Columns: columns}); err != nil {
+					return err
+				}
+			}
+			if okA, err = diffNext(ra, scansA); err != nil {
+				return err
+			}
+			if okB, err = diffNext(rb, scansB); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 键字段的位置
+func (t *Table) diffKeys(keyColumns []string) ([]int, error) {
+	keys := make([]int, len(keyColumns))
+	for i, column := range keyColumns {
+		j, err := t.indexOf(column)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = j
+	}
+	return keys, nil
+}
+
+// 按键排序读取所有行，字符串按字节排序，与 Go 中的比较一致
+func (t *Table) diffRows(keys []int) (*sql.Rows, error) {
+	order := make([]string, len(keys))
+	for i, k := range keys {
+		switch t.Fields[k].Type.Value {
+		case TypeChar, TypeVarchar, TypeText, TypeMediumText, TypeLongtext, TypeEnum:
+			order[i] = "BINARY " + t.Fields[k].FullName
+		default:
+			order[i] = t.Fields[k].FullName
+		}
+	}
+	return t.query(fmt.Sprintf("%s ORDER BY %s", t.sqlSelect, strings.Join(order, ", ")))
+}
+
+func diffNext(rows *sql.Rows, scans []interface{}) (bool, error) {
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+	return true, rows.Scan(scans...)
+}
+
+func keyValues(scans []interface{}, keys []int) []interface{} {
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		values[i] = parseValue(scans[k])
+	}
+	return values
+}
+
+func compareKeys(scansA []interface{}, keysA []int, scansB []interface{}, keysB []int) int {
+	for i := range keysA {
+		if c := compareValue(parseValue(scansA[keysA[i]]), parseValue(scansB[keysB[i]])); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// 比较两个值，NULL 最小
+func compareValue(x, y interface{}) int {
+	switch {
+	case x == nil && y == nil:
+		return 0
+	case x == nil:
+		return -1
+	case y == nil:
+		return 1
+	}
+	switch a := x.(type) {
+	case int64:
+		if b, ok := y.(int64); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case float64:
+		if b, ok := y.(float64); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if b, ok := y.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case time.Time:
+		if b, ok := y.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1
+			case a.After(b):
+				return 1
+			}
+			return 0
+		}
+	case []byte:
+		if b, ok := y.([]byte); ok {
+			return bytes.Compare(a, b)
+		}
+	}
+	return strings.Compare(fmt.Sprint(x), fmt.Sprint(y))
+}
+
+func equalValue(x, y interface{}) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	return compareValue(x, y) == 0
+}
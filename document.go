@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// 读取表的注释、字符集和排序规则
+func (t *Table) loadMeta() error {
+	var comment, collation, charset sql.NullString
+	err := QueryRow(`
+	SELECT
+		t.TABLE_COMMENT, t.TABLE_COLLATION, c.CHARACTER_SET_NAME
+	FROM
+		information_schema.TABLES t
+		LEFT JOIN information_schema.COLLATION_CHARACTER_SET_APPLICABILITY c
+		ON c.COLLATION_NAME = t.TABLE_COLLATION
+	WHERE
+		t.TABLE_SCHEMA = ? AND t.TABLE_NAME = ?
+	`, t.DbName, t.TbName).Scan(&comment, &collation, &charset)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	t.Comment, t.Collation, t.Charset = comment.String, collation.String, charset.String
+	return nil
+}
+
+// 字符串字面量
+func quoteString(s string) string {
+	return "'" + strings.Replace(strings.Replace(s, `\`, `\\`, -1), "'", "''", -1) + "'"
+}
+
+// Document 生成 Markdown 格式的数据字典
+//
+// 包括表的注释、字段的类型、是否可空、默认值、键、字符集和注释，以及索引。
+func (t Table) Document() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", t.TbName)
+	if t.Comment != "" {
+		fmt.Fprintf(&b, "%s\n\n", escapeMarkdown(t.Comment))
+	}
+	b.WriteString("| 字段 | 类型 | 可空 | 默认值 | 键 | 字符集 | 说明 |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, f := range t.Fields {
+		null := "否"
+		if f.Null {
+			null = "是"
+		}
+		def := ""
+		if !f.Default.Null {
+			def = f.Default.Value
+		}
+		charset := f.Charset
+		if f.Collation != "" {
+			charset += " / " + f.Collation
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s |\n",
+			escapeMarkdown(f.Name), escapeMarkdown(f.Type.ToSql()), null, escapeMarkdown(def),
+			f.Key, charset, escapeMarkdown(f.Comment))
+	}
+	if len(t.Indexes) > 0 {
+		b.WriteString("\n| 索引 | 字段 | 唯一 |\n")
+		b.WriteString("| --- | --- | --- |\n")
+		for _, idx := range t.Indexes {
+			unique := "否"
+			if idx.Unique {
+				unique = "是"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", escapeMarkdown(idx.Name), escapeMarkdown(strings.Join(idx.Columns, ", ")), unique)
+		}
+	}
+	return b.String()
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+// 表格中的文本
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 结构差异的类型
+const (
+	TableMissing  = "table missing"
+	TableExtra    = "table extra"
+	ColumnMissing = "column missing"
+	ColumnExtra   = "column extra"
+	ColumnChanged = "column changed"
+	IndexMissing  = "index missing"
+	IndexExtra    = "index extra"
+	IndexChanged  = "index changed"
+)
+
+// SchemaChange 一处结构差异
+type SchemaChange struct {
+	Table string
+	Kind  string
+	//字段名或索引名，表级的差异为空
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (c SchemaChange) String() string {
+	s := c.Table
+	if c.Name != "" {
+		s += "." + c.Name
+	}
+	s += ": " + c.Kind
+	if c.Expected != "" || c.Actual != "" {
+		s += fmt.Sprintf(" (expected %s, actual %s)", orNone(c.Expected), orNone(c.Actual))
+	}
+	return s
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// DriftReport 快照与实际结构的差异
+type DriftReport struct {
+	Changes []SchemaChange
+}
+
+// OK 没有差异
+func (r *DriftReport) OK() bool {
+	return len(r.Changes) == 0
+}
+
+// String 可读的报告，每处差异一行
+func (r *DriftReport) String() string {
+	if r.OK() {
+		return "no schema drift"
+	}
+	lines := make([]string, len(r.Changes))
+	for i := range r.Changes {
+		lines[i] = r.Changes[i].String()
+	}
+	return fmt.Sprintf("%d schema changes:\n%s", len(r.Changes), strings.Join(lines, "\n"))
+}
+
+// DetectDrift 比较快照与数据库中的实际结构
+//
+// 只检查快照中的表，适合部署前的检查：
+//
+//	s, err := db.LoadSchema(data)
+//	report, err := db.DetectDrift(s)
+//	if !report.OK() {
+//		log.Fatal(report)
+//	}
+func DetectDrift(expected *SchemaSnapshot) (*DriftReport, error) {
+	report := &DriftReport{}
+	for _, ts := range expected.Tables {
+		var n int64
+		err := QueryRow("SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", ts.Database, ts.Name).Scan(&n)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			report.Changes = append(report.Changes, SchemaChange{Table: ts.Database + "." + ts.Name, Kind: TableMissing})
+			continue
+		}
+		t, err := getTable(ts.Database, ts.Name)
+		if err != nil {
+			return nil, err
+		}
+		report.Changes = append(report.Changes, compareTableSchema(ts, t.Schema())...)
+	}
+	return report, nil
+}
+
+// CompareSchemas 比较两个快照，按数据库名和表名对应
+func CompareSchemas(expected, actual *SchemaSnapshot) *DriftReport {
+	report := &DriftReport{}
+	found := make(map[string]bool)
+	for _, as := range actual.Tables {
+		found[as.Database+"."+as.Name] = false
+	}
+	for _, ts := range expected.Tables {
+		key := ts.Database + "." + ts.Name
+		matched := false
+		for _, as := range actual.Tables {
+			if as.Database+"."+as.Name == key {
+				report.Changes = append(report.Changes, compareTableSchema(ts, as)...)
+				matched = true
+				found[key] = true
+				break
+			}
+		}
+		if !matched {
+			report.Changes = append(report.Changes, SchemaChange{Table: key, Kind: TableMissing})
+		}
+	}
+	extra := make([]string, 0)
+	for key, ok := range found {
+		if !ok {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	for _, key := range extra {
+		report.Changes = append(report.Changes, SchemaChange{Table: key, Kind: TableExtra})
+	}
+	return report
+}
+
+// 比较一个表的字段和索引
+func compareTableSchema(expected, actual TableSchema) []SchemaChange {
+	table := expected.Database + "." + expected.Name
+	changes := make([]SchemaChange, 0)
+	columns := make(map[string]ColumnSchema)
+	for _, c := range actual.Columns {
+		columns[c.Name] = c
+	}
+	for _, c := range expected.Columns {
+		a, ok := columns[c.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Table: table, Kind: ColumnMissing, Name: c.Name, Expected: columnDef(c)})
+			continue
+		}
+		delete(columns, c.Name)
+		if columnDef(c) != columnDef(a) {
+			changes = append(changes, SchemaChange{Table: table, Kind: ColumnChanged, Name: c.Name, Expected: columnDef(c), Actual: columnDef(a)})
+		}
+	}
+	for _, c := range actual.Columns {
+		if _, ok := columns[c.Name]; ok {
+			changes = append(changes, SchemaChange{Table: table, Kind: ColumnExtra, Name: c.Name, Actual: columnDef(c)})
+		}
+	}
+	indexes := make(map[string]IndexSchema)
+	for _, idx := range actual.Indexes {
+		indexes[idx.Name] = idx
+	}
+	for _, idx := range expected.Indexes {
+		a, ok := indexes[idx.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Table: table, Kind: IndexMissing, Name: idx.Name, Expected: indexDef(idx)})
+			continue
+		}
+		delete(indexes, idx.Name)
+		if indexDef(idx) != indexDef(a) {
+			changes = append(changes, SchemaChange{Table: table, Kind: IndexChanged, Name: idx.Name, Expected: indexDef(idx), Actual: indexDef(a)})
+		}
+	}
+	for _, idx := range actual.Indexes {
+		if _, ok := indexes[idx.Name]; ok {
+			changes = append(changes, SchemaChange{Table: table, Kind: IndexExtra, Name: idx.Name, Actual: indexDef(idx)})
+		}
+	}
+	return changes
+}
+
+// 用于比较的字段定义，不包括注释
+func columnDef(c ColumnSchema) string {
+	parts := []string{c.Type}
+	if c.Null {
+		parts = append(parts, "NULL")
+	} else {
+		parts = append(parts, "NOT NULL")
+	}
+	if c.Default != nil {
+		parts = append(parts, "DEFAULT "+*c.Default)
+	}
+	if c.Extra != "" {
+		parts = append(parts, strings.ToLower(c.Extra))
+	}
+	if c.Collation != "" {
+		parts = append(parts, "COLLATE "+c.Collation)
+	}
+	return strings.Join(parts, " ")
+}
+
+// 用于比较的索引定义
+func indexDef(idx IndexSchema) string {
+	return Index{Name: idx.Name, Columns: idx.Columns, Unique: idx.Unique}.ToSql()
+}
@@ -0,0 +1,298 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试用的驱动，模拟数据库 test 中的 users 表：
+//
+//	id BIGINT 主键自增、name VARCHAR(64)、age INT
+//
+// SELECT 返回 fake.rows 行，按需生成，可以模拟很大的结果集。
+type fakeDriver struct{}
+
+// 所有连接共享的服务器状态
+var fake struct {
+	sync.Mutex
+	//users 表的行数
+	rows int64
+	//执行过的 KILL QUERY 的连接号
+	kills []int64
+	//还没有关闭的结果集
+	open int64
+	//连接号和自增主键
+	connID, insertID int64
+	//执行过的修改语句
+	execs []string
+	//包含该字符串的修改语句返回错误
+	fail string
+}
+
+func init() {
+	sql.Register("dbtest", fakeDriver{})
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{id: atomic.AddInt64(&fake.connID, 1)}, nil
+}
+
+// 打开测试驱动的连接池作为当前连接池，返回 users 表
+func openFake(tb testing.TB, rows int64) *Table {
+	tb.Helper()
+	fake.Lock()
+	fake.rows, fake.kills, fake.execs, fake.fail = rows, nil, nil, ""
+	fake.Unlock()
+	sqldb, err := sql.Open("dbtest", "")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	setConn(sqldb, "test")
+	t, err := GetTable("users")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return t
+}
+
+func fakeKills() []int64 {
+	fake.Lock()
+	defer fake.Unlock()
+	return append([]int64(nil), fake.kills...)
+}
+
+// 等待 cond 成立，超时返回 false
+func eventually(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return cond()
+}
+
+type fakeConn struct {
+	id int64
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.query(query, args), nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.exec(query)
+}
+
+func (c *fakeConn) query(query string, args []driver.NamedValue) driver.Rows {
+	switch {
+	case strings.HasPrefix(query, "SELECT COLUMN_NAME FROM information_schema.COLUMNS"):
+		return &fakeRows{columns: []string{"COLUMN_NAME"}, data: [][]driver.Value{{[]byte("id")}, {[]byte("name")}, {[]byte("age")}}}
+	case strings.Contains(query, "information_schema.COLUMNS"):
+		return &fakeRows{
+			columns: []string{"COLUMN_NAME", "COLUMN_TYPE", "COLUMN_DEFAULT", "IS_NULLABLE", "COLUMN_KEY", "EXTRA", "COLUMN_COMMENT", "CHARACTER_SET_NAME", "COLLATION_NAME"},
+			data: [][]driver.Value{
+				{[]byte("id"), []byte("bigint(20)"), nil, []byte("NO"), []byte("PRI"), []byte("auto_increment"), []byte(""), nil, nil},
+				{[]byte("name"), []byte("varchar(64)"), nil, []byte("YES"), []byte(""), []byte(""), []byte(""), []byte("utf8mb4"), []byte("utf8mb4_general_ci")},
+				{[]byte("age"), []byte("int(11)"), nil, []byte("YES"), []byte(""), []byte(""), []byte(""), nil, nil},
+			},
+		}
+	case strings.Contains(query, "information_schema.SCHEMATA"):
+		if len(args) == 1 && args[0].Value == "test" {
+			return &fakeRows{columns: []string{"SCHEMA_NAME"}, data: [][]driver.Value{{[]byte("test")}}}
+		}
+		return &fakeRows{columns: []string{"SCHEMA_NAME"}}
+	case strings.Contains(query, "CONNECTION_ID()"):
+		return &fakeRows{columns: []string{"CONNECTION_ID()"}, data: [][]driver.Value{{c.id}}}
+	case strings.Contains(query, "SELECT EXISTS"):
+		fake.Lock()
+		n := fake.rows
+		fake.Unlock()
+		if n > 0 {
+			n = 1
+		}
+		return &fakeRows{columns: []string{"EXISTS"}, data: [][]driver.Value{{n}}}
+	case strings.Contains(query, "COUNT("):
+		fake.Lock()
+		n := fake.rows
+		fake.Unlock()
+		return &fakeRows{columns: []string{"COUNT"}, data: [][]driver.Value{{n}}}
+	case strings.HasPrefix(query, "SELECT users.`") && strings.Contains(query, " FROM") && !strings.Contains(query[:strings.Index(query, " FROM")], ","):
+		//只查询一个字段，例如受影响的主键
+		fake.Lock()
+		n := fake.rows
+		fake.Unlock()
+		column := strings.TrimPrefix(query, "SELECT users.`")
+		column = column[:strings.IndexByte(column, '`')]
+		data := make([][]driver.Value, 0, n)
+		for i := int64(1); i <= n; i++ {
+			data = append(data, []driver.Value{fakeValue(column, i)})
+		}
+		return &fakeRows{columns: []string{column}, data: data}
+	case strings.HasPrefix(strings.TrimSpace(query), "SELECT") && strings.Contains(query, "test.users"):
+		fake.Lock()
+		n := fake.rows
+		fake.Unlock()
+		if strings.Contains(strings.ToLower(query), "limit 1") && n > 1 {
+			n = 1
+		}
+		atomic.AddInt64(&fake.open, 1)
+		return &fakeRows{columns: []string{"id", "name", "age"}, generate: n, counted: true}
+	}
+	return &fakeRows{}
+}
+
+func (c *fakeConn) exec(query string) (driver.Result, error) {
+	fake.Lock()
+	fake.execs = append(fake.execs, query)
+	fail := fake.fail
+	fake.Unlock()
+	if fail != "" && strings.Contains(query, fail) {
+		return nil, fmt.Errorf("fake: %s failed", fail)
+	}
+	var id int64
+	switch {
+	case strings.HasPrefix(query, "KILL QUERY"):
+		if _, err := fmt.Sscanf(query, "KILL QUERY %d", &id); err == nil {
+			fake.Lock()
+			fake.kills = append(fake.kills, id)
+			fake.Unlock()
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "INSERT"):
+		id = atomic.AddInt64(&fake.insertID, 1)
+	}
+	return fakeResult{id: id, affected: 1}, nil
+}
+
+type fakeResult struct {
+	id, affected int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return r.id, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.exec(s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: args[i]}
+	}
+	return s.c.query(s.query, named), nil
+}
+
+// 固定的数据，或者按需生成的 users 行
+type fakeRows struct {
+	columns  []string
+	data     [][]driver.Value
+	generate int64
+	i        int64
+	//计入 fake.open
+	counted bool
+	closed  bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	if !r.closed && r.counted {
+		atomic.AddInt64(&fake.open, -1)
+	}
+	r.closed = true
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.closed {
+		return io.EOF
+	}
+	if r.data != nil {
+		if r.i >= int64(len(r.data)) {
+			return io.EOF
+		}
+		copy(dest, r.data[r.i])
+		r.i++
+		return nil
+	}
+	if r.i >= r.generate {
+		return io.EOF
+	}
+	r.i++
+	dest[0], dest[1], dest[2] = fakeValue("id", r.i), fakeValue("name", r.i), fakeValue("age", r.i)
+	return nil
+}
+
+// 第 i 行的字段值
+func fakeValue(column string, i int64) driver.Value {
+	switch column {
+	case "name":
+		return []byte(fmt.Sprintf("user%d", i))
+	case "age":
+		return i % 100
+	}
+	return i
+}
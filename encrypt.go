@@ -0,0 +1,199 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCiphertext 密文格式错误
+var ErrCiphertext = errors.New("db: invalid ciphertext")
+
+// KeyProvider 提供加密字段使用的密钥
+//
+// 密钥长度为 16、24 或 32 字节，对应 AES-128、AES-192、AES-256。
+// 每个密文都记录了密钥编号，轮换密钥后旧数据仍然可以用旧密钥解密。
+type KeyProvider interface {
+	//加密使用的当前密钥
+	CurrentKey() (id string, key []byte, err error)
+	//按编号查找密钥
+	Key(id string) ([]byte, error)
+}
+
+// 字段加密设置
+type columnCipher struct {
+	keys    KeyProvider
+	columns map[int]bool
+}
+
+// Encrypt 指定透明加密的字段
+//
+// Add 和 Update 写入前用 AES-GCM 加密，Row 和 Rows 读取后自动解密，
+// 密文以 "密钥编号:base64" 的形式保存，字段应为 varchar、text 或 blob 类型。
+// 密文每次都不同，因此加密字段不能用于查询条件。
+func (t *Table) Encrypt(keys KeyProvider, columns ...string) error {
+	c := &columnCipher{keys: keys, columns: make(map[int]bool)}
+	for i := range columns {
+		k, err := t.indexOf(columns[i])
+		if err != nil {
+			return err
+		}
+		c.columns[k] = true
+	}
+	t.cipher = c
+	return nil
+}
+
+// 加密按字段位置排列的值
+func (c *columnCipher) encryptValues(values []interface{}) ([]interface{}, error) {
+	var out []interface{}
+	for i := range values {
+		if values[i] == nil || !c.columns[i] {
+			continue
+		}
+		//写入 NULL 时不加密
+		if v, ok := values[i].(driver.Valuer); ok {
+			if dv, err := v.Value(); err == nil && dv == nil {
+				continue
+			}
+		}
+		var plain []byte
+		switch v := values[i].(type) {
+		case string:
+			plain = []byte(v)
+		case []byte:
+			plain = v
+		case Expression:
+			return nil, fmt.Errorf("db: the encrypted column (%d) can't be written by an expression", i)
+		default:
+			plain = []byte(fmt.Sprint(v))
+		}
+		text, err := c.seal(plain)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = append([]interface{}(nil), values...)
+		}
+		out[i] = text
+	}
+	if out == nil {
+		return values, nil
+	}
+	return out, nil
+}
+
+func (c *columnCipher) seal(plain []byte) (string, error) {
+	id, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, []byte(id))
+	return id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *columnCipher) open(text string) ([]byte, error) {
+	i := strings.LastIndex(text, ":")
+	if i < 0 {
+		return nil, ErrCiphertext
+	}
+	id := text[:i]
+	sealed, err := base64.StdEncoding.DecodeString(text[i+1:])
+	if err != nil {
+		return nil, ErrCiphertext
+	}
+	key, err := c.keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrCiphertext
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(id))
+}
+
+// 解密读到的值
+func (c *columnCipher) decrypt(scans []interface{}) error {
+	for i := range c.columns {
+		switch v := scans[i].(type) {
+		case *sql.NullString:
+			if !v.Valid {
+				continue
+			}
+			plain, err := c.open(v.String)
+			if err != nil {
+				return err
+			}
+			v.String = string(plain)
+		case *NullBytes:
+			if !v.Valid {
+				continue
+			}
+			plain, err := c.open(string(v.Bytes))
+			if err != nil {
+				return err
+			}
+			v.Bytes = plain
+		}
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// StaticKeys 内存中的密钥表，最后添加的密钥用于加密
+type StaticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeys 创建空的密钥表
+func NewStaticKeys() *StaticKeys {
+	return &StaticKeys{keys: make(map[string][]byte)}
+}
+
+// Add 添加密钥并设为当前密钥
+func (s *StaticKeys) Add(id string, key []byte) *StaticKeys {
+	s.keys[id] = key
+	s.current = id
+	return s
+}
+
+func (s *StaticKeys) CurrentKey() (string, []byte, error) {
+	if s.current == "" {
+		return "", nil, errors.New("db: no encryption key")
+	}
+	return s.current, s.keys[s.current], nil
+}
+
+func (s *StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("db: the encryption key (%s) not found", id)
+	}
+	return key, nil
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// QueryError 带有表名、操作和语句的错误，errors.Unwrap 返回驱动的原始错误
+//
+// 语句中只有占位符，不包含参数值。sql.ErrNoRows 不包装，可以直接比较。
+type QueryError struct {
+	//SELECT、INSERT、UPDATE、DELETE 等
+	Op    string
+	Table string
+	Sql   string
+	Err   error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("db: %s %s: %v [sql: %s]", e.Op, e.Table, e.Err, NormalizeSql(e.Sql))
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// 给错误加上表名、操作和语句
+func (t Table) wrapErr(query string, err error) error {
+	if err == nil || err == sql.ErrNoRows || query == "" {
+		return err
+	}
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		return err
+	}
+	return &QueryError{Op: opOf(query), Table: t.Fullname, Sql: query, Err: err}
+}
@@ -0,0 +1,25 @@
+package db
+
+// Expression 写入时使用的 Sql 片段，例如 NOW() 或 price * ?
+type Expression struct {
+	Sql  string
+	Args []interface{}
+}
+
+// Expr 创建 Sql 片段，可以作为 Add 和 Setter.Values 的值
+//
+// 片段原样拼接到语句中，不要把用户输入放进 query，应通过 args 传入。
+//
+//	t.Add(nil, "name", db.Expr("NOW()"))
+//	t.Update(1).Columns(map[string]interface{}{"price": db.Expr("price * ?", 1.1)})
+func Expr(query string, args ...interface{}) Expression {
+	return Expression{Sql: query, Args: args}
+}
+
+// 值在语句中的占位符和参数
+func valueMark(value interface{}) (string, []interface{}) {
+	if e, ok := value.(Expression); ok {
+		return e.Sql, e.Args
+	}
+	return "?", []interface{}{value}
+}
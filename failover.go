@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FailoverOptions 多主机连接的选项
+type FailoverOptions struct {
+	//探测当前主机的间隔，默认 2 秒
+	Interval time.Duration
+	//单次探测的超时，默认 1 秒
+	Timeout time.Duration
+	//只连接 @@read_only = 0 的主机
+	RequireWritable bool
+	//切换主机后回调，参数为切换前后的主机
+	OnChange func(from, to string)
+}
+
+// Failover 在多个主机之间自动切换的连接
+type Failover struct {
+	username, password, dbname string
+	hosts                      []string
+	opt                        FailoverOptions
+
+	mu      sync.Mutex
+	current int
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	//取消 Close 时的注册
+	unregister func()
+}
+
+// OpenFailover 按顺序连接 hosts 中第一个可用的主机，之后在后台探测，当前主机不可用时切换到下一个
+//
+// hosts 的格式为 "主机:端口"。切换时替换 Open 打开的连接池，未用 Table.On 指定连接池的表随之切换。
+func OpenFailover(username, password string, hosts []string, databasename string, opt FailoverOptions) (*Failover, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("db: no hosts")
+	}
+	if opt.Interval <= 0 {
+		opt.Interval = 2 * time.Second
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = time.Second
+	}
+	f := &Failover{
+		username: username, password: password, dbname: databasename,
+		hosts: hosts, opt: opt, current: -1,
+		stop: make(chan struct{}), done: make(chan struct{}),
+	}
+	if err := f.Check(); err != nil {
+		return nil, err
+	}
+	f.unregister = OnClose(func(ctx context.Context) error {
+		f.Close()
+		return nil
+	})
+	go f.run()
+	return f, nil
+}
+
+// Current 当前连接的主机
+func (f *Failover) Current() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.current < 0 {
+		return ""
+	}
+	return f.hosts[f.current]
+}
+
+// Close 停止探测，不关闭当前连接池，可以重复调用
+//
+// 包级的 Close 会先调用它，再关闭连接池。
+func (f *Failover) Close() {
+	f.once.Do(func() {
+		close(f.stop)
+		f.unregister()
+	})
+	<-f.done
+}
+
+func (f *Failover) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.opt.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.Check()
+		}
+	}
+}
+
+// 探测主机是否可用
+func (f *Failover) probe(sqldb *sql.DB) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), f.opt.Timeout)
+	defer cancel()
+	if !f.opt.RequireWritable {
+		return sqldb.PingContext(ctx) == nil
+	}
+	var readOnly bool
+	return sqldb.QueryRowContext(ctx, "SELECT @@read_only").Scan(&readOnly) == nil && !readOnly
+}
+
+// Check 立即探测当前主机，不可用时依次尝试其他主机，所有主机都不可用时返回错误
+func (f *Failover) Check() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.current >= 0 && f.probe(conn()) {
+		return nil
+	}
+	start := f.current
+	for n := 1; n <= len(f.hosts); n++ {
+		i := (start + n + len(f.hosts)) % len(f.hosts)
+		if start < 0 {
+			i = n - 1
+		}
+		sqldb, err := openDB(dsn(f.username, f.password, f.hosts[i], f.dbname))
+		if err != nil {
+			continue
+		}
+		if !f.probe(sqldb) {
+			sqldb.Close()
+			continue
+		}
+		setConn(sqldb, f.dbname)
+		from := ""
+		if start >= 0 {
+			from = f.hosts[start]
+		}
+		f.current = i
+		if f.opt.OnChange != nil {
+			f.opt.OnChange(from, f.hosts[i])
+		}
+		return nil
+	}
+	return fmt.Errorf("db: no available host in %v", f.hosts)
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Filter 支持的运算符
+var filterOperators = map[string]string{
+	"=":        "=",
+	"!=":       "!=",
+	"<>":       "!=",
+	">":        ">",
+	">=":       ">=",
+	"<":        "<",
+	"<=":       "<=",
+	"LIKE":     "LIKE",
+	"NOT LIKE": "NOT LIKE",
+	"IN":       "IN",
+	"NOT IN":   "NOT IN",
+}
+
+// 解析 "字段 运算符" 形式的键，省略运算符时为 =
+func parseFilterKey(key string) (string, string, error) {
+	key = strings.TrimSpace(key)
+	column, op := key, "="
+	if i := strings.IndexAny(key, " \t!<>="); i >= 0 {
+		column, op = key[:i], strings.ToUpper(strings.Join(strings.Fields(key[i:]), " "))
+	}
+	if _, ok := filterOperators[op]; !ok {
+		return "", "", fmt.Errorf("db: the filter operator (%s) is not supported", op)
+	}
+	return column, filterOperators[op], nil
+}
+
+// 把 map 条件转换为 Condition
+func parseFilter(filter map[string]interface{}) ([]Condition, error) {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	//固定顺序，相同的条件生成相同的SQL
+	sort.Strings(keys)
+	conds := make([]Condition, 0, len(keys))
+	for _, key := range keys {
+		column, op, err := parseFilterKey(key)
+		if err != nil {
+			return nil, err
+		}
+		value := filter[key]
+		switch {
+		case value == nil && op == "=":
+			conds = append(conds, Condition{column: column, op: "IS NULL"})
+		case value == nil && op == "!=":
+			conds = append(conds, Condition{column: column, op: "IS NOT NULL"})
+		case op == "IN" || op == "NOT IN":
+			rv := reflect.ValueOf(value)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				return nil, fmt.Errorf("db: the filter (%s) value %T is not a slice", key, value)
+			}
+			args := make([]interface{}, rv.Len())
+			for i := range args {
+				args[i] = rv.Index(i).Interface()
+			}
+			conds = append(conds, Condition{column: column, op: op, args: args})
+		default:
+			conds = append(conds, Condition{column: column, op: op, args: []interface{}{value}})
+		}
+	}
+	return conds, nil
+}
+
+// Conditions 把 Filter 格式的 map 转换为 Condition，用于 Select 等方法
+func Conditions(filter map[string]interface{}) ([]Condition, error) {
+	return parseFilter(filter)
+}
+
+// Filter 按字段名过滤，所有条件用 AND 连接
+//
+// 键为字段名，可以带运算符后缀：
+//
+//	t.Filter(map[string]interface{}{"status": 1, "age >": 18, "name LIKE": "a%", "id IN": []int{1, 2}})
+//
+// 支持 = != <> > >= < <= LIKE, NOT LIKE, IN, NOT IN，值为 nil 时 = 和 != 生成 IS NULL 和 IS NOT NULL。
+// LIKE 的值原样使用，需要转义时用 EscapeLike。
+func (t *Table) Filter(filter map[string]interface{}) (*Rows, error) {
+	conds, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return t.Where(conds...)
+}
@@ -0,0 +1,35 @@
+package db
+
+import "fmt"
+
+// First 主键最小的一行
+func (t *Table) First() *Row {
+	return t.FirstWhere()
+}
+
+// Last 主键最大的一行
+func (t *Table) Last() *Row {
+	return t.LastWhere()
+}
+
+// FirstWhere 满足条件的行中主键最小的一行
+func (t *Table) FirstWhere(conds ...Condition) *Row {
+	return t.edgeRow("ASC", conds)
+}
+
+// LastWhere 满足条件的行中主键最大的一行
+func (t *Table) LastWhere(conds ...Condition) *Row {
+	return t.edgeRow("DESC", conds)
+}
+
+func (t *Table) edgeRow(order string, conds []Condition) *Row {
+	if t.PrimaryKey == "" {
+		return &Row{t: t, err: fmt.Errorf("db: the table (%s) has no primary key", t.TbName)}
+	}
+	where, args, err := t.sqlWhere(conds)
+	if err != nil {
+		return &Row{t: t, err: err}
+	}
+	strSql := fmt.Sprintf("%s %s ORDER BY %s.`%s` %s limit 1", t.sqlSelect, where, t.TbName, t.PrimaryKey, order)
+	return t.row(strSql, args...)
+}
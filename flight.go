@@ -0,0 +1,92 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 参与合并的方法
+const (
+	//Get、GetByID、Find、First、Last、QueryRow 等返回 Row 的方法
+	FlightRow int = 1 << iota
+	//GetMany、FindMany、Where、Filter、List、Query 等返回 Rows 的方法
+	FlightRows
+)
+
+// SetSingleflight 合并并发的相同查询
+//
+// methods 为 FlightRow、FlightRows 的组合，为 0 时关闭。开启后，
+// 同一时刻相同 SQL 和参数的查询只访问一次数据库，所有调用方共享结果，
+// 结果集会一次读入内存，因此不适合很大的结果集。
+func (t *Table) SetSingleflight(methods int) {
+	t.flight = methods
+}
+
+// 正在进行的查询
+type flightCall struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+type flightGroup struct {
+	mu sync.Mutex
+	m  map[string]*flightCall
+}
+
+var flights = &flightGroup{m: make(map[string]*flightCall)}
+
+// 相同 key 的调用只执行一次 fn
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := new(flightCall)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+	return c.val, c.err
+}
+
+func flightKey(query string, args []interface{}) string {
+	return fmt.Sprintf("%s|%#v", query, args)
+}
+
+func (t *Table) flightRow(query string, args []interface{}) *Row {
+	val, err := flights.do("row|"+flightKey(query, args), func() (interface{}, error) {
+		scans := t.getScans()
+		defer t.putScans(scans)
+		if err := t.queryRow(query, args...).Scan(scans...); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(scans))
+		for i := range scans {
+			values[i] = parseValue(scans[i])
+		}
+		return values, nil
+	})
+	if err != nil {
+		return &Row{t: t, err: err}
+	}
+	return &Row{t: t, src: valuesScanner(val.([]interface{}))}
+}
+
+func (t *Table) flightRows(query string, args []interface{}) (*Rows, error) {
+	val, err := flights.do("rows|"+flightKey(query, args), func() (interface{}, error) {
+		return t.loadRows(query, args)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{t: t, scans: t.getScans(), src: newCachedRows(t, val.([][]interface{}))}, nil
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// 超过限制时 Rows.Err 返回的错误
+var (
+	ErrTooManyRows    = errors.New("db: the result exceeds the row limit")
+	ErrResultTooLarge = errors.New("db: the result exceeds the size limit")
+)
+
+// Guardrails 限制便捷查询返回的结果，防止意外读取整个表
+type Guardrails struct {
+	//最多读取的行数，0 表示不限制
+	MaxRows int
+	//最多读取的字节数，按读到的字符串和字节的长度计算，0 表示不限制
+	MaxBytes int64
+	//超过限制时截断结果而不是返回错误，通过 Rows.Truncated 判断
+	Truncate bool
+	//没有 LIMIT 的查询自动加上 LIMIT，0 表示不加
+	DefaultLimit int
+}
+
+var guardrails atomic.Value
+
+// SetGuardrails 设置全局的结果限制，作用于 GetMany、FindMany、Where、Select 等返回 Rows 的方法
+func SetGuardrails(g Guardrails) {
+	guardrails.Store(g)
+}
+
+func getGuardrails() Guardrails {
+	g, _ := guardrails.Load().(Guardrails)
+	return g
+}
+
+var reLimit = regexp.MustCompile(`(?i)\blimit\s+(\?|\d)`)
+
+// 没有 LIMIT 时加上默认的 LIMIT
+func limitQuery(query string) string {
+	g := getGuardrails()
+	if g.DefaultLimit <= 0 || reLimit.MatchString(query) {
+		return query
+	}
+	return fmt.Sprintf("%s LIMIT %d", query, g.DefaultLimit)
+}
+
+// 按限制读取的数据来源
+type guardedSource struct {
+	rowsSource
+	g         Guardrails
+	n         int
+	bytes     int64
+	err       error
+	truncated bool
+}
+
+// 开启限制时包装 Rows 的数据来源
+func (rs *Rows) guard() *Rows {
+	g := getGuardrails()
+	if g.MaxRows > 0 || g.MaxBytes > 0 {
+		rs.src = &guardedSource{rowsSource: rs.source(), g: g}
+	}
+	return rs
+}
+
+func (s *guardedSource) exceed(err error) {
+	if s.g.Truncate {
+		s.truncated = true
+	} else {
+		s.err = err
+	}
+}
+
+func (s *guardedSource) Next() bool {
+	if s.err != nil || s.truncated || !s.rowsSource.Next() {
+		return false
+	}
+	s.n++
+	if s.g.MaxRows > 0 && s.n > s.g.MaxRows {
+		s.exceed(ErrTooManyRows)
+		return false
+	}
+	return true
+}
+
+func (s *guardedSource) Scan(dest ...interface{}) error {
+	if err := s.rowsSource.Scan(dest...); err != nil {
+		return err
+	}
+	if s.g.MaxBytes <= 0 {
+		return nil
+	}
+	for _, d := range dest {
+		s.bytes += sizeOf(d)
+	}
+	if s.bytes > s.g.MaxBytes {
+		s.exceed(ErrResultTooLarge)
+		if !s.g.Truncate {
+			return ErrResultTooLarge
+		}
+	}
+	return nil
+}
+
+func (s *guardedSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.rowsSource.Err()
+}
+
+// 读到的值的大致字节数
+func sizeOf(dest interface{}) int64 {
+	switch v := dest.(type) {
+	case *sql.NullString:
+		return int64(len(v.String))
+	case *NullBytes:
+		return int64(len(v.Bytes))
+	case *string:
+		return int64(len(*v))
+	case *[]byte:
+		return int64(len(*v))
+	case *sql.RawBytes:
+		return int64(len(*v))
+	}
+	return 8
+}
+
+// Truncated 结果是否因 Guardrails 的限制被截断
+func (rs *Rows) Truncated() bool {
+	s, ok := rs.src.(*guardedSource)
+	return ok && s.truncated
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Index 表的索引
+type Index struct {
+	Name string
+	//按索引中的顺序排列
+	Columns []string
+	Unique  bool
+	//BTREE、FULLTEXT 等
+	Type string
+}
+
+// 读取表的索引
+func getIndexes(dbname, tbname string) ([]Index, error) {
+	rows, err := Query(`
+	SELECT
+		INDEX_NAME, COLUMN_NAME, NON_UNIQUE, INDEX_TYPE
+	FROM
+		information_schema.STATISTICS
+	WHERE
+		TABLE_SCHEMA = ? AND TABLE_NAME = ?
+	ORDER BY
+		INDEX_NAME, SEQ_IN_INDEX
+	`, dbname, tbname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	indexes := make([]Index, 0)
+	for rows.Next() {
+		var name, column, typ string
+		var nonUnique int
+		if err = rows.Scan(&name, &column, &nonUnique, &typ); err != nil {
+			return nil, err
+		}
+		if n := len(indexes); n > 0 && indexes[n-1].Name == name {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, column)
+			continue
+		}
+		indexes = append(indexes, Index{Name: name, Columns: []string{column}, Unique: nonUnique == 0, Type: typ})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
+
+// ToSql 输出建表语句中的索引定义
+func (idx Index) ToSql() string {
+	kind := "KEY"
+	if idx.Unique {
+		kind = "UNIQUE KEY"
+	}
+	return fmt.Sprintf("%s `%s` (`%s`)", kind, idx.Name, strings.Join(idx.Columns, "`,`"))
+}
+
+// UniqueKeys 表的唯一索引，不包括主键，联合唯一索引的字段按索引中的顺序排列
+func (t Table) UniqueKeys() []Index {
+	keys := make([]Index, 0)
+	for _, idx := range t.Indexes {
+		if idx.Unique && idx.Name != "PRIMARY" {
+			keys = append(keys, idx)
+		}
+	}
+	return keys
+}
+
+// UseIndex 返回查询时提示使用指定索引的表
+//
+// 返回的是副本，只影响 SELECT 和 COUNT，索引名必须是表上已有的索引，主键为 PRIMARY。
+// 优化器选错索引的热点查询可以在初始化时准备好副本：
+//
+//	byCreated, err := t.ForceIndex("idx_created")
+func (t Table) UseIndex(names ...string) (*Table, error) {
+	return t.indexHint("USE", names)
+}
+
+// ForceIndex 返回查询时强制使用指定索引的表
+func (t Table) ForceIndex(names ...string) (*Table, error) {
+	return t.indexHint("FORCE", names)
+}
+
+// IgnoreIndex 返回查询时忽略指定索引的表
+func (t Table) IgnoreIndex(names ...string) (*Table, error) {
+	return t.indexHint("IGNORE", names)
+}
+
+func (t Table) indexHint(kind string, names []string) (*Table, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		if !t.hasIndex(name) {
+			return nil, fmt.Errorf("db: the index (%s) not found in table (%s)", name, t.TbName)
+		}
+		quoted[i] = "`" + name + "`"
+	}
+	t.hint = ""
+	if len(names) > 0 {
+		t.hint = fmt.Sprintf("%s INDEX (%s)", kind, strings.Join(quoted, ", "))
+	}
+	t.prepareSql()
+	return &t, nil
+}
+
+func (t Table) hasIndex(name string) bool {
+	for i := range t.Indexes {
+		if strings.EqualFold(t.Indexes[i].Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// 查询使用的 FROM 子句，带有索引提示
+func (t Table) sqlFrom() string {
+	if t.hint == "" {
+		return t.Fullname
+	}
+	return t.Fullname + " " + t.hint
+}
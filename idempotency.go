@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL 唯一键冲突的错误码
+const errDupEntry = 1062
+
+// ErrNoIdempotencyKey 表设置了幂等键字段，但插入的值和表的上下文中都没有幂等键
+var ErrNoIdempotencyKey = errors.New("db: the idempotency key is missing")
+
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey 返回带有幂等键的 ctx
+//
+// 表的上下文（见 WithContext）带有幂等键时，Add 在幂等键字段为 nil 时使用该值。
+// 重试同一次插入时使用同一个 ctx，每次不同的插入使用不同的幂等键。
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// NewIdempotencyKey 生成一个随机的幂等键
+//
+// 调用方在第一次插入前生成，重试时使用同一个值。
+func NewIdempotencyKey() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("db: generate idempotency key error: %s", err))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// SetIdempotencyKey 指定幂等键字段，该字段必须有唯一索引
+//
+// 设置后 Add 在该字段为 nil 时使用表的上下文中的幂等键（见 WithIdempotencyKey），
+// 都没有时返回 ErrNoIdempotencyKey：每次自动生成的键不同，重试时无法去重。
+// 插入因该键重复失败时，返回已存在那一行的主键，而不是错误，这样网络异常后重试插入是安全的。
+// column 为空时取消设置。
+func (t *Table) SetIdempotencyKey(column string) error {
+	if column == "" {
+		t.idempotencyKey = -1
+		return nil
+	}
+	i, err := t.indexOf(column)
+	if err != nil {
+		return err
+	}
+	t.idempotencyKey = i
+	return nil
+}
+
+// 补全幂等键
+func (t Table) fillIdempotencyKey(values []interface{}) ([]interface{}, error) {
+	if len(values) > t.idempotencyKey && values[t.idempotencyKey] != nil {
+		return values, nil
+	}
+	key, _ := t.context().Value(idempotencyKeyKey{}).(string)
+	if key == "" {
+		return nil, ErrNoIdempotencyKey
+	}
+	//复制一份，不修改调用者的切片
+	n := len(values)
+	if n <= t.idempotencyKey {
+		n = t.idempotencyKey + 1
+	}
+	filled := make([]interface{}, n)
+	copy(filled, values)
+	filled[t.idempotencyKey] = key
+	return filled, nil
+}
+
+// 幂等键重复时查出已存在的行
+func (t Table) existingIdempotent(values []interface{}, err error) (int64, bool) {
+	if t.idempotencyKey < 0 || t.PrimaryKey == "" {
+		return 0, false
+	}
+	var e *mysql.MySQLError
+	if !errors.As(err, &e) || e.Number != errDupEntry {
+		return 0, false
+	}
+	var id int64
+	strSql := fmt.Sprintf("SELECT `%s` FROM %s WHERE %s=? LIMIT 1", t.PrimaryKey, t.Fullname, t.Fields[t.idempotencyKey].FullName)
+	if t.Primary().queryRow(strSql, values[t.idempotencyKey]).Scan(&id) != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIdempotencyKeyRequired(t *testing.T) {
+	users := openFake(t, 0)
+	if err := users.SetIdempotencyKey("name"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Add(nil, nil, int64(1)); !errors.Is(err, ErrNoIdempotencyKey) {
+		t.Fatalf("Add without a key = %v, want ErrNoIdempotencyKey", err)
+	}
+	if _, err := users.Add(nil, NewIdempotencyKey(), int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithIdempotencyKey(context.Background(), NewIdempotencyKey())
+	if _, err := users.WithContext(ctx).Add(nil, nil, int64(1)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UpdateByIDs 按主键列表修改，生成一条 UPDATE ... WHERE 主键 IN (...)，返回影响的行数
+//
+// set 的规则与 Selector.Update 相同，值为 nil 时设为 NULL，可以是 Expression。ids 为空时不执行语句。
+func (t *Table) UpdateByIDs(ids []interface{}, set map[string]interface{}) (int64, error) {
+	if t.PrimaryKey == "" {
+		return -1, fmt.Errorf("db: the table (%s) has no primary key", t.TbName)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return t.Select(In(t.PrimaryKey, ids...)).Update(set)
+}
+
+// GetByIDs 按主键列表查询，生成一条 SELECT ... WHERE 主键 IN (...)，结果按主键升序
+//
+// 不存在的主键没有对应的行。需要按主键取用时用 MapBy 建立索引：
+//
+//	rows, err := users.GetByIDs(ids)
+//	byID, err := db.MapBy[int64, User](rows, "id")
+func (t *Table) GetByIDs(ids []interface{}) (*Rows, error) {
+	if t.PrimaryKey == "" {
+		return nil, fmt.Errorf("db: the table (%s) has no primary key", t.TbName)
+	}
+	return t.Select(In(t.PrimaryKey, ids...)).OrderBy(t.PrimaryKey).GetMany()
+}
+
+// GetByIDsInOrder 与 GetByIDs 相同，但结果按 ids 中的顺序排列
+//
+// 使用 ORDER BY FIELD(主键, ...)，重复的主键只返回一行。
+func (t *Table) GetByIDsInOrder(ids []interface{}) (*Rows, error) {
+	if t.PrimaryKey == "" {
+		return nil, fmt.Errorf("db: the table (%s) has no primary key", t.TbName)
+	}
+	if len(ids) == 0 {
+		return t.GetByIDs(ids)
+	}
+	where, args, err := t.sqlWhere([]Condition{In(t.PrimaryKey, ids...)})
+	if err != nil {
+		return nil, err
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	strSql := fmt.Sprintf("%s %s ORDER BY FIELD(%s.`%s`, %s)", t.sqlSelect, where, t.TbName, t.PrimaryKey, marks)
+	return t.rows(strSql, append(args, ids...)...)
+}
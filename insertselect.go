@@ -0,0 +1,33 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AddFromQuery 在服务器端执行 INSERT INTO t (columns) SELECT ...，返回插入的行数
+//
+// src 通过 Fields 指定与 columns 一一对应的字段，不指定时查询 src 表的全部字段。
+// 数据不经过客户端，也不会写入审计表和行缓存。
+//
+//	n, err := archive.AddFromQuery([]string{"id", "msg"}, logs.Select(db.Raw("created < ?", cutoff)).Fields("id", "msg"))
+func (t *Table) AddFromQuery(columns []string, src *Selector) (int64, error) {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		j, err := t.indexOf(column)
+		if err != nil {
+			return 0, err
+		}
+		names[i] = t.Fields[j].FullName
+	}
+	query, args, err := src.sql(true)
+	if err != nil {
+		return 0, err
+	}
+	strSql := fmt.Sprintf("INSERT INTO %s (%s) %s", t.Fullname, strings.Join(names, ", "), query)
+	res, err := t.exec(strSql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 多表修改不能使用排序和分页
+var errJoinLimit = errors.New("db: multi-table UPDATE and DELETE can't use ORDER BY or LIMIT")
+
+// Join 连接另一个表，on 为连接条件，字段用 表名.`字段` 引用
+//
+//	orders.Select(db.Raw("users.`banned`=1")).Join(users, "orders.`uid`=users.`id`").Delete()
+func (s *Selector) Join(other *Table, on string, args ...interface{}) *Selector {
+	return s.join("JOIN", other, on, args)
+}
+
+// LeftJoin 左连接另一个表
+func (s *Selector) LeftJoin(other *Table, on string, args ...interface{}) *Selector {
+	return s.join("LEFT JOIN", other, on, args)
+}
+
+func (s *Selector) join(kind string, other *Table, on string, args []interface{}) *Selector {
+	s.joins = append(s.joins, expr{name: kind, query: fmt.Sprintf("%s ON %s", other.Fullname, on), args: args})
+	s.joined = append(s.joined, other)
+	return s
+}
+
+// 生成连接子句
+func (s *Selector) sqlJoins() (string, []interface{}) {
+	if len(s.joins) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(s.joins))
+	args := make([]interface{}, 0)
+	for i, j := range s.joins {
+		parts[i] = j.name + " " + j.query
+		args = append(args, j.args...)
+	}
+	return strings.Join(parts, " "), args
+}
+
+// 查找字段，column 为 字段 或 表名.字段，返回字段所属的表和位置
+func (s *Selector) fieldOf(column string) (*Table, int, error) {
+	t := s.t
+	if i := strings.IndexByte(column, '.'); i >= 0 {
+		name := column[:i]
+		column = column[i+1:]
+		t = nil
+		for _, o := range append([]*Table{s.t}, s.joined...) {
+			if o.TbName == name {
+				t = o
+				break
+			}
+		}
+		if t == nil {
+			return nil, -1, fmt.Errorf("db: the table (%s) is not joined", name)
+		}
+	}
+	i, err := t.indexOf(column)
+	if err != nil {
+		return nil, -1, err
+	}
+	return t, i, nil
+}
+
+// 生成修改和删除用的 WHERE 及之后的部分，LIMIT 只有行数
+func (s *Selector) writeTail() (string, []interface{}, error) {
+	if s.err != nil {
+		return "", nil, s.err
+	}
+	if len(s.joins) > 0 && (len(s.order) > 0 || s.limit > 0) {
+		return "", nil, errJoinLimit
+	}
+	if s.skip > 0 {
+		return "", nil, errors.New("db: UPDATE and DELETE can't skip rows")
+	}
+	joins, args := s.sqlJoins()
+	where, whereArgs, err := s.t.sqlWhere(s.conds)
+	if err != nil {
+		return "", nil, err
+	}
+	parts := []string{joins, where}
+	args = append(args, whereArgs...)
+	if len(s.order) > 0 {
+		parts = append(parts, "ORDER BY "+strings.Join(s.order, ", "))
+	}
+	if s.limit > 0 {
+		parts = append(parts, "LIMIT ?")
+		args = append(args, s.limit)
+	}
+	return strings.TrimSpace(strings.Join(parts, " ")), args, nil
+}
+
+// Update 修改满足条件的行，columns 的键为 字段 或 表名.字段，值可以是 Expression
+//
+// 连接了其他表时生成多表 UPDATE，可以修改任意一个表的字段，例如
+//
+//	orders.Select().Join(users, "orders.`uid`=users.`id`").Update(map[string]interface{}{"uname": db.Expr("users.`name`")})
+//
+// 值按所属的表检查、转换和加密，与 Setter.Values 相同。
+func (s *Selector) Update(columns map[string]interface{}) (int64, error) {
+	tail, tailArgs, err := s.writeTail()
+	if err != nil {
+		return -1, err
+	}
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	//每个表的修改值按字段位置排列，与 Setter.Values 一样检查、转换和加密
+	type setField struct {
+		t *Table
+		i int
+	}
+	fields := make([]setField, len(names))
+	values := make(map[*Table][]interface{})
+	for k, name := range names {
+		t, i, err := s.fieldOf(name)
+		if err != nil {
+			return -1, err
+		}
+		if values[t] == nil {
+			values[t] = make([]interface{}, t.Len)
+		}
+		values[t][i] = columns[name]
+		fields[k] = setField{t, i}
+	}
+	for t, list := range values {
+		list, err := t.updateValues(list)
+		if err != nil {
+			return -1, err
+		}
+		values[t] = list
+	}
+	listkey := make([]string, 0, len(names))
+	listvalue := make([]interface{}, 0, len(names))
+	for _, f := range fields {
+		value := values[f.t][f.i]
+		if value == nil {
+			value = Expr("NULL")
+		}
+		mark, args := valueMark(value)
+		listkey = append(listkey, f.t.Fields[f.i].FullName+"="+mark)
+		listvalue = append(listvalue, args...)
+	}
+	if len(listkey) == 0 {
+		return 0, nil
+	}
+	table := s.t.sqlUpdate
+	where := tail
+	//参数按语句中的顺序：连接条件、SET、WHERE
+	args := make([]interface{}, 0, len(listvalue)+len(tailArgs))
+	if joins, joinArgs := s.sqlJoins(); joins != "" {
+		table += " " + joins
+		where = strings.TrimSpace(strings.TrimPrefix(tail, joins))
+		args = append(args, joinArgs...)
+		args = append(args, listvalue...)
+		args = append(args, tailArgs[len(joinArgs):]...)
+	} else {
+		args = append(args, listvalue...)
+		args = append(args, tailArgs...)
+	}
+	strSql := fmt.Sprintf("%s SET %s %s", table, strings.Join(listkey, ", "), where)
+	res, err := s.t.write(opUpdate, strSql, args, tail, tailArgs, nil)
+	if err != nil {
+		return -1, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete 删除满足条件的行
+//
+// 连接了其他表时生成多表 DELETE，只删除本表的行，例如
+//
+//	DELETE orders FROM db.orders JOIN db.users ON ... WHERE ...
+func (s *Selector) Delete() (int64, error) {
+	tail, args, err := s.writeTail()
+	if err != nil {
+		return -1, err
+	}
+	strSql := fmt.Sprintf("%s %s", s.t.sqlDelete, tail)
+	if len(s.joins) > 0 {
+		strSql = fmt.Sprintf("DELETE %s FROM %s %s", s.t.TbName, s.t.Fullname, tail)
+	}
+	res, err := s.t.write(opDelete, strSql, args, tail, args, nil)
+	if err != nil {
+		return -1, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSelectorUpdateValidates(t *testing.T) {
+	users := openFake(t, 3)
+	users.SetValidation(true)
+	_, err := users.Select(Eq("id", 1)).Update(map[string]interface{}{"name": strings.Repeat("x", 65)})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Update() = %v, want *ValidationError", err)
+	}
+	if _, err = users.UpdateByIDs([]interface{}{1, 2}, map[string]interface{}{"age": int64(1) << 40}); !errors.As(err, &verr) {
+		t.Fatalf("UpdateByIDs() = %v, want *ValidationError", err)
+	}
+	if _, err = users.Select(Eq("id", 1)).Update(map[string]interface{}{"name": "ok", "age": nil}); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+}
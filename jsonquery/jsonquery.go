@@ -0,0 +1,165 @@
+// Package jsonquery 把 JSON 描述的查询转换为 db.Selector 并返回 JSON 行
+//
+// 只能访问白名单中的表和字段，适合作为内部管理界面的查询接口：
+//
+//	{"table": "users", "columns": ["id", "name"],
+//	 "where": [{"column": "age", "op": ">", "value": 18}],
+//	 "order": [{"column": "id", "desc": true}], "take": 20, "skip": 0}
+package jsonquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/dgf1988/db"
+)
+
+// Request 查询描述
+type Request struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+	Where   []Filter `json:"where"`
+	Order   []Order  `json:"order"`
+	Take    int      `json:"take"`
+	Skip    int      `json:"skip"`
+}
+
+// Filter 一个条件，Op 与 db.Filter 支持的运算符相同，省略时为 =
+type Filter struct {
+	Column string      `json:"column"`
+	Op     string      `json:"op"`
+	Value  interface{} `json:"value"`
+}
+
+// Order 排序
+type Order struct {
+	Column string `json:"column"`
+	Desc   bool   `json:"desc"`
+}
+
+// Engine 执行查询描述
+type Engine struct {
+	//允许访问的表和字段，字段列表为 ["*"] 时允许全部字段
+	Allow map[string][]string
+	//每次最多返回的行数，默认 100
+	MaxTake int
+}
+
+// 检查字段是否在白名单中
+func (e *Engine) allowed(table, column string) bool {
+	for _, c := range e.Allow[table] {
+		if c == "*" || c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Do 执行查询，返回字段名到值的 map 列表
+func (e *Engine) Do(req Request) ([]map[string]interface{}, error) {
+	if _, ok := e.Allow[req.Table]; !ok {
+		return nil, fmt.Errorf("jsonquery: the table (%s) is not allowed", req.Table)
+	}
+	t, err := db.GetTable(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	columns := req.Columns
+	if len(columns) == 0 {
+		for i := range t.Fields {
+			if e.allowed(req.Table, t.Fields[i].Name) {
+				columns = append(columns, t.Fields[i].Name)
+			}
+		}
+	}
+	for _, column := range columns {
+		if !e.allowed(req.Table, column) {
+			return nil, fmt.Errorf("jsonquery: the column (%s) is not allowed", column)
+		}
+	}
+	filter := make(map[string]interface{}, len(req.Where))
+	for _, f := range req.Where {
+		if !e.allowed(req.Table, f.Column) {
+			return nil, fmt.Errorf("jsonquery: the column (%s) is not allowed", f.Column)
+		}
+		key := f.Column
+		if f.Op != "" {
+			key += " " + f.Op
+		}
+		if _, ok := filter[key]; ok {
+			return nil, fmt.Errorf("jsonquery: the filter (%s) is repeated", key)
+		}
+		filter[key] = f.Value
+	}
+	conds, err := db.Conditions(filter)
+	if err != nil {
+		return nil, err
+	}
+	s := t.Select(conds...)
+	for _, o := range req.Order {
+		if !e.allowed(req.Table, o.Column) {
+			return nil, fmt.Errorf("jsonquery: the column (%s) is not allowed", o.Column)
+		}
+		if o.Desc {
+			s.OrderByDesc(o.Column)
+		} else {
+			s.OrderBy(o.Column)
+		}
+	}
+	maxTake := e.MaxTake
+	if maxTake <= 0 {
+		maxTake = 100
+	}
+	if req.Take <= 0 || req.Take > maxTake {
+		req.Take = maxTake
+	}
+	if req.Skip < 0 {
+		req.Skip = 0
+	}
+	rs, err := s.Limit(req.Take, req.Skip).GetMany()
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	data := make([]map[string]interface{}, 0, req.Take)
+	for rs.Next() {
+		m, err := rs.Map()
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			v, ok := m[column]
+			if !ok {
+				return nil, fmt.Errorf("jsonquery: the column (%s) not found", column)
+			}
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			row[column] = v
+		}
+		data = append(data, row)
+	}
+	return data, rs.Err()
+}
+
+// ServeHTTP 从 POST 请求体读取查询描述，返回 JSON 行
+func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := e.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LeaseTableSql 选主租约表的建表语句
+func LeaseTableSql(name string) string {
+	return strings.Join([]string{
+		fmt.Sprintf("CREATE TABLE %s (", name),
+		"\t`name` varchar(128) NOT NULL,",
+		"\t`holder` varchar(255) NOT NULL,",
+		"\t`token` bigint(20) NOT NULL,",
+		"\t`expires` datetime(3) NOT NULL,",
+		"\tPRIMARY KEY (`name`)",
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+	}, "\n")
+}
+
+// ElectionOptions 选主的选项
+type ElectionOptions struct {
+	//租约表，结构见 LeaseTableSql
+	Table string
+	//选举的名称，同名的实例竞争同一个租约
+	Name string
+	//本实例的标识，默认为主机名和进程号
+	ID string
+	//租约时长，默认 15 秒
+	TTL time.Duration
+	//续约间隔，默认 TTL 的三分之一
+	Heartbeat time.Duration
+	//成为主节点时回调，token 为递增的防护令牌
+	OnElected func(token int64)
+	//失去主节点身份时回调
+	OnLost func()
+}
+
+// Elector 基于租约表的选主
+//
+// 主节点定期续约，租约过期后其他实例可以接管，接管时 token 加一。
+// 写入外部系统时带上 token，接收方拒绝更小的 token，即可防止旧的主节点在失联后继续写入。
+type Elector struct {
+	opt ElectionOptions
+
+	mu     sync.RWMutex
+	leader bool
+	token  int64
+	//租约的过期时间，从开始获取租约时计算，不晚于服务器上的过期时间
+	expires time.Time
+}
+
+// NewElector 创建选主组件，调用 Run 开始参与选举
+func NewElector(opt ElectionOptions) *Elector {
+	if opt.ID == "" {
+		host, _ := os.Hostname()
+		opt.ID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if opt.TTL <= 0 {
+		opt.TTL = 15 * time.Second
+	}
+	if opt.Heartbeat <= 0 {
+		opt.Heartbeat = opt.TTL / 3
+	}
+	return &Elector{opt: opt}
+}
+
+// IsLeader 本实例当前是否为主节点
+//
+// 续约失败或者没有按时续约时，租约过期后返回 false，此时其他实例可能已经接管。
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.isLeader()
+}
+
+func (e *Elector) isLeader() bool {
+	return e.leader && time.Now().Before(e.expires)
+}
+
+// Token 本实例成为主节点时的防护令牌，不是主节点时为 0
+func (e *Elector) Token() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if !e.isLeader() {
+		return 0
+	}
+	return e.token
+}
+
+// Run 参与选举直到 ctx 结束，结束时如果是主节点则释放租约
+func (e *Elector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.opt.Heartbeat)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		//每次获取不超过续约间隔，避免卡住的语句让过期的租约仍被视为有效
+		actx, cancel := context.WithTimeout(ctx, e.opt.Heartbeat)
+		leader, token, err := e.acquire(actx)
+		cancel()
+		if err != nil {
+			//无法确认租约时视为失去主节点身份
+			leader = false
+		}
+		e.update(leader, token, start.Add(e.opt.TTL))
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// 获取或续约，返回是否为主节点
+func (e *Elector) acquire(ctx context.Context) (bool, int64, error) {
+	ttl := e.opt.TTL.Microseconds()
+	if _, err := ExecContext(ctx, fmt.Sprintf("INSERT IGNORE INTO %s (`name`, `holder`, `token`, `expires`) VALUES (?, ?, 1, NOW(3) + INTERVAL ? MICROSECOND)", e.opt.Table),
+		e.opt.Name, e.opt.ID, ttl); err != nil {
+		return false, 0, err
+	}
+	if _, err := ExecContext(ctx, fmt.Sprintf("UPDATE %s SET `token` = IF(`holder` = ?, `token`, `token` + 1), `holder` = ?, `expires` = NOW(3) + INTERVAL ? MICROSECOND WHERE `name` = ? AND (`holder` = ? OR `expires` < NOW(3))", e.opt.Table),
+		e.opt.ID, e.opt.ID, ttl, e.opt.Name, e.opt.ID); err != nil {
+		return false, 0, err
+	}
+	var holder string
+	var token int64
+	if err := QueryRowContext(ctx, fmt.Sprintf("SELECT `holder`, `token` FROM %s WHERE `name` = ?", e.opt.Table), e.opt.Name).Scan(&holder, &token); err != nil {
+		return false, 0, err
+	}
+	return holder == e.opt.ID, token, nil
+}
+
+func (e *Elector) update(leader bool, token int64, expires time.Time) {
+	e.mu.Lock()
+	was := e.leader
+	e.leader, e.token, e.expires = leader, token, expires
+	e.mu.Unlock()
+	switch {
+	case leader && !was && e.opt.OnElected != nil:
+		e.opt.OnElected(token)
+	case !leader && was && e.opt.OnLost != nil:
+		e.opt.OnLost()
+	}
+}
+
+// 主动让出租约，其他实例无需等待过期
+func (e *Elector) resign() {
+	if !e.IsLeader() {
+		return
+	}
+	Exec(fmt.Sprintf("UPDATE %s SET `expires` = NOW(3) WHERE `name` = ? AND `holder` = ?", e.opt.Table), e.opt.Name, e.opt.ID)
+	e.update(false, 0, time.Time{})
+}
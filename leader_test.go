@@ -0,0 +1,18 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestElectorLeaseExpires(t *testing.T) {
+	e := NewElector(ElectionOptions{Table: "test.leases", Name: "job"})
+	e.update(true, 7, time.Now().Add(time.Hour))
+	if !e.IsLeader() || e.Token() != 7 {
+		t.Fatalf("IsLeader() = %v, Token() = %d before the lease expires", e.IsLeader(), e.Token())
+	}
+	e.update(true, 7, time.Now().Add(-time.Millisecond))
+	if e.IsLeader() || e.Token() != 0 {
+		t.Fatalf("IsLeader() = %v, Token() = %d after the lease expired", e.IsLeader(), e.Token())
+	}
+}
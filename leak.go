@@ -0,0 +1,87 @@
+package db
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+var leakDetector atomic.Value
+
+// SetLeakDetector 开启泄漏检测，Rows 没有关闭就被回收时调用 fn，参数为创建 Rows 时的调用栈
+//
+// 检测依赖垃圾回收，只用于发现问题，会增加创建 Rows 的开销。fn 为 nil 时关闭。
+func SetLeakDetector(fn func(stack string)) {
+	leakDetector.Store(fn)
+}
+
+// 开启泄漏检测时记录调用栈并设置终结器
+func (rs *Rows) watch() *Rows {
+	fn, _ := leakDetector.Load().(func(string))
+	if fn == nil {
+		return rs
+	}
+	buf := make([]byte, 4096)
+	stack := string(buf[:runtime.Stack(buf, false)])
+	rs.leak = true
+	runtime.SetFinalizer(rs, func(rs *Rows) {
+		fn(stack)
+		rs.source().Close()
+	})
+	return rs
+}
+
+// ForEach 对每一行调用 fn，结束或出错时关闭结果集
+func (rs *Rows) ForEach(fn func(rs *Rows) error) error {
+	defer rs.Close()
+	for rs.Next() {
+		if err := fn(rs); err != nil {
+			return err
+		}
+	}
+	return rs.Err()
+}
+
+// Collect 把所有行读到结构体切片中，读完后关闭结果集
+//
+//	users, err := db.Collect[User](t.GetMany(nil, nil, 1))
+func Collect[T any](rs *Rows, err error) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0)
+	err = rs.ForEach(func(rs *Rows) error {
+		var v T
+		if err := rs.Struct(&v); err != nil {
+			return err
+		}
+		list = append(list, v)
+		return nil
+	})
+	return list, err
+}
+
+// CollectSlice 与 Collect 相同，参数只有结果集
+func CollectSlice[T any](rs *Rows) ([]T, error) {
+	return Collect[T](rs, nil)
+}
+
+// CollectMap 把所有行读到结构体，按 keyFn 返回的键建立索引，读完后关闭结果集
+//
+// 键重复时保留最后一行。
+//
+//	users, err := db.CollectMap(rows, func(u User) int64 { return u.Id })
+func CollectMap[T any, K comparable](rs *Rows, keyFn func(T) K) (map[K]T, error) {
+	data := make(map[K]T)
+	err := rs.ForEach(func(rs *Rows) error {
+		var v T
+		if err := rs.Struct(&v); err != nil {
+			return err
+		}
+		data[keyFn(v)] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooManyQueries 排队等待执行的时间超过了 LimitOptions.MaxWait
+var ErrTooManyQueries = errors.New("db: too many concurrent queries")
+
+// LimitOptions 连接池上同时执行的语句数的限制
+type LimitOptions struct {
+	//同时执行的查询数，为 0 时不限制
+	Reads int
+	//同时执行的修改语句数，为 0 时不限制
+	Writes int
+	//最长排队时间，超过时返回 ErrTooManyQueries，为 0 时一直等到上下文结束
+	MaxWait time.Duration
+}
+
+// LimitStats 连接池上限制的统计
+type LimitStats struct {
+	//正在执行的查询数和修改语句数
+	Reads, Writes int
+	//正在排队的语句数
+	Waiting int64
+	//排队过的语句数和总的排队时间
+	WaitCount    int64
+	WaitDuration time.Duration
+	//因排队超时被拒绝的语句数
+	Rejected int64
+}
+
+type limiter struct {
+	opt           LimitOptions
+	reads, writes chan struct{}
+
+	waiting, waitCount, waitDuration, rejected int64
+}
+
+// 按连接池的限制，键为 nil 时是 Open 打开的连接池
+var limiters sync.Map
+
+// SetLimit 限制连接池上同时执行的语句数，sqldb 为 nil 时限制 Open 打开的连接池，重新连接后仍然有效
+//
+// 超过限制的语句排队等待，避免流量突增时占满服务器的连接。查询从执行语句到结果集关闭
+// 都占用名额，限制查询时在固定的连接上执行。opt 的两个限制都为 0 时取消限制。
+func SetLimit(sqldb *sql.DB, opt LimitOptions) {
+	if opt.Reads <= 0 && opt.Writes <= 0 {
+		limiters.Delete(sqldb)
+		return
+	}
+	l := &limiter{opt: opt}
+	if opt.Reads > 0 {
+		l.reads = make(chan struct{}, opt.Reads)
+	}
+	if opt.Writes > 0 {
+		l.writes = make(chan struct{}, opt.Writes)
+	}
+	limiters.Store(sqldb, l)
+}
+
+// Limits 连接池上限制的统计，sqldb 为 nil 时是 Open 打开的连接池，没有限制时返回 false
+func Limits(sqldb *sql.DB) (LimitStats, bool) {
+	v, ok := limiters.Load(sqldb)
+	if !ok {
+		return LimitStats{}, false
+	}
+	l := v.(*limiter)
+	return LimitStats{
+		Reads:        len(l.reads),
+		Writes:       len(l.writes),
+		Waiting:      atomic.LoadInt64(&l.waiting),
+		WaitCount:    atomic.LoadInt64(&l.waitCount),
+		WaitDuration: time.Duration(atomic.LoadInt64(&l.waitDuration)),
+		Rejected:     atomic.LoadInt64(&l.rejected),
+	}, true
+}
+
+func limiterOf(sqldb *sql.DB) *limiter {
+	if v, ok := limiters.Load(sqldb); ok {
+		return v.(*limiter)
+	}
+	if v, ok := limiters.Load((*sql.DB)(nil)); ok && sqldb == conn() {
+		return v.(*limiter)
+	}
+	return nil
+}
+
+// 取得执行语句的名额，用完后调用返回的函数归还，没有限制时返回 nil
+func acquireSlot(sqldb *sql.DB, ctx context.Context, write bool) (func(), error) {
+	l := limiterOf(sqldb)
+	if l == nil {
+		return nil, nil
+	}
+	sem := l.reads
+	if write {
+		sem = l.writes
+	}
+	if sem == nil {
+		return nil, nil
+	}
+	release := func() { <-sem }
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	atomic.AddInt64(&l.waiting, 1)
+	defer atomic.AddInt64(&l.waiting, -1)
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(&l.waitCount, 1)
+		atomic.AddInt64(&l.waitDuration, int64(time.Since(start)))
+	}()
+	var timeout <-chan time.Time
+	if l.opt.MaxWait > 0 {
+		timer := time.NewTimer(l.opt.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		atomic.AddInt64(&l.rejected, 1)
+		return nil, ErrTooManyQueries
+	}
+}
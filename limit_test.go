@@ -0,0 +1,38 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 读取结果集期间一直占用名额，关闭后才归还
+func TestReadSlotHeldUntilClose(t *testing.T) {
+	users := openFake(t, 10)
+	SetLimit(nil, LimitOptions{Reads: 1, MaxWait: 20 * time.Millisecond})
+	defer SetLimit(nil, LimitOptions{})
+	rs, err := users.GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Next()
+	if _, err = users.GetMany(); !errors.Is(err, ErrTooManyQueries) {
+		t.Fatalf("GetMany() with an open result set = %v, want ErrTooManyQueries", err)
+	}
+	rs.Close()
+	if !eventually(func() bool {
+		stats, _ := Limits(nil)
+		return stats.Reads == 0
+	}) {
+		t.Fatal("the read slot was not released after Close")
+	}
+	rs, err = users.GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Close()
+	var u benchUser
+	if err = users.GetByID(1).Struct(&u); err != nil {
+		t.Fatal(err)
+	}
+}
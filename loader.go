@@ -0,0 +1,167 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// LoaderOptions 批量加载的选项
+type LoaderOptions struct {
+	//第一次 Load 之后等待多久再查询，期间的 Load 合并为一次查询，默认 2 毫秒
+	Wait time.Duration
+	//每次查询最多的主键数，达到时立即查询，默认 100
+	MaxBatch int
+}
+
+// Loader 按主键批量加载的结果，用于 GraphQL 的 resolver 等逐个取数据的场景
+//
+// 一小段时间内并发的 Load 合并为一次 GetByIDs，相同的主键只查询一次，结果缓存在 Loader 中，
+// 因此应为每个请求创建一个 Loader：
+//
+//	users := db.NewLoader[int64, User](userTable.WithContext(r.Context()), db.LoaderOptions{})
+//	user, err := users.Load(post.UserId)
+type Loader[K comparable, T any] struct {
+	t   *Table
+	opt LoaderOptions
+
+	mu    sync.Mutex
+	cache map[K]*loadResult[T]
+	//等待查询的主键及其结果
+	batch   []K
+	pending []*loadResult[T]
+	timer   *time.Timer
+}
+
+// 一个主键的加载结果，done 关闭后可以读取
+type loadResult[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// NewLoader 创建表 t 的批量加载，K 为主键的类型，T 为读取到的结构体
+func NewLoader[K comparable, T any](t *Table, opt LoaderOptions) *Loader[K, T] {
+	if opt.Wait <= 0 {
+		opt.Wait = 2 * time.Millisecond
+	}
+	if opt.MaxBatch <= 0 {
+		opt.MaxBatch = 100
+	}
+	return &Loader[K, T]{t: t, opt: opt, cache: make(map[K]*loadResult[T])}
+}
+
+// Load 加载主键为 id 的行，不存在时返回 sql.ErrNoRows
+func (l *Loader[K, T]) Load(id K) (T, error) {
+	r := l.enqueue(id)
+	<-r.done
+	return r.value, r.err
+}
+
+// LoadMany 加载多个主键，结果和错误与 ids 一一对应
+func (l *Loader[K, T]) LoadMany(ids []K) ([]T, []error) {
+	results := make([]*loadResult[T], len(ids))
+	for i := range ids {
+		results[i] = l.enqueue(ids[i])
+	}
+	values := make([]T, len(ids))
+	errs := make([]error, len(ids))
+	for i, r := range results {
+		<-r.done
+		values[i], errs[i] = r.value, r.err
+	}
+	return values, errs
+}
+
+// Prime 把已经读到的行放入缓存，之后 Load 该主键不再查询
+func (l *Loader[K, T]) Prime(id K, value T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.cache[id]; ok {
+		return
+	}
+	r := &loadResult[T]{done: make(chan struct{}), value: value}
+	close(r.done)
+	l.cache[id] = r
+}
+
+// Clear 从缓存中删除主键，例如修改了该行之后
+func (l *Loader[K, T]) Clear(id K) {
+	l.mu.Lock()
+	delete(l.cache, id)
+	l.mu.Unlock()
+}
+
+// 加入等待查询的主键，已经在缓存中时直接返回
+func (l *Loader[K, T]) enqueue(id K) *loadResult[T] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.cache[id]; ok {
+		return r
+	}
+	r := &loadResult[T]{done: make(chan struct{})}
+	l.cache[id] = r
+	l.batch = append(l.batch, id)
+	l.pending = append(l.pending, r)
+	if len(l.batch) >= l.opt.MaxBatch {
+		l.dispatchLocked()
+	} else if l.timer == nil {
+		l.timer = time.AfterFunc(l.opt.Wait, l.dispatch)
+	}
+	return r
+}
+
+func (l *Loader[K, T]) dispatch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dispatchLocked()
+}
+
+// 取出等待的主键在后台查询，调用时持有锁
+func (l *Loader[K, T]) dispatchLocked() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	if len(l.batch) == 0 {
+		return
+	}
+	ids, results := l.batch, l.pending
+	l.batch, l.pending = nil, nil
+	go l.fetch(ids, results)
+}
+
+// 查询一批主键并填入结果
+func (l *Loader[K, T]) fetch(ids []K, results []*loadResult[T]) {
+	args := make([]interface{}, len(ids))
+	for i := range ids {
+		args[i] = ids[i]
+	}
+	var data map[K]T
+	rows, err := l.t.GetByIDs(args)
+	if err == nil {
+		data, err = MapBy[K, T](rows, l.t.PrimaryKey)
+	}
+	for i, r := range results {
+		switch v, ok := data[ids[i]]; {
+		case err != nil:
+			r.err = err
+		case ok:
+			r.value = v
+		default:
+			r.err = sql.ErrNoRows
+		}
+		close(r.done)
+	}
+	if err == nil {
+		return
+	}
+	//查询失败的主键不缓存，下次 Load 重新查询
+	l.mu.Lock()
+	for i, id := range ids {
+		if l.cache[id] == results[i] {
+			delete(l.cache, id)
+		}
+	}
+	l.mu.Unlock()
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+var location atomic.Value
+
+// SetLocation 设置连接使用的时区，必须在 Open 之前调用，默认为 UTC
+//
+// 驱动按该时区解析 DATETIME 并格式化插入的 time.Time，字符串形式的日期时间也按该时区解析。
+func SetLocation(loc *time.Location) {
+	location.Store(loc)
+}
+
+func getLocation() *time.Location {
+	if loc, ok := location.Load().(*time.Location); ok && loc != nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// 连接参数中的时区
+func locationParam() string {
+	loc := getLocation()
+	if loc == time.UTC {
+		return ""
+	}
+	return "&loc=" + url.QueryEscape(loc.String())
+}
+
+// Reinterpret 保持年月日时分秒不变，把时间解释为 loc 中的时间
+//
+// 与 t.In(loc) 不同，Reinterpret 改变的是时刻而不是显示。
+func Reinterpret(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// SetLocation 设置表中日期时间字段的时区，覆盖连接的时区
+//
+// 读取时把字段的值解释为 loc 中的时间，写入时把 time.Time 参数转换为 loc 中的时间，
+// 适用于按不同时区保存数据的表。loc 为 nil 时使用连接的时区。
+func (t *Table) SetLocation(loc *time.Location) {
+	t.loc = loc
+}
+
+// 把读到的时间解释为表的时区
+func (t Table) localizeScans(scans []interface{}) {
+	for _, s := range scans {
+		switch v := s.(type) {
+		case *NullTime:
+			if v.Valid {
+				v.Time = Reinterpret(v.Time, t.loc)
+			}
+		case *time.Time:
+			if !v.IsZero() {
+				*v = Reinterpret(*v, t.loc)
+			}
+		}
+	}
+}
+
+// 把 time.Time 参数转换为表的时区中的墙上时间，再按连接的时区发送
+func (t Table) localizeArgs(args []interface{}) []interface{} {
+	if t.loc == nil {
+		return args
+	}
+	var converted []interface{}
+	for i, arg := range args {
+		v, ok := arg.(time.Time)
+		if !ok {
+			continue
+		}
+		if converted == nil {
+			converted = make([]interface{}, len(args))
+			copy(converted, args)
+		}
+		converted[i] = Reinterpret(v.In(t.loc), getLocation())
+	}
+	if converted == nil {
+		return args
+	}
+	return converted
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLockNotAcquired 超时前没有获得锁
+var ErrLockNotAcquired = errors.New("db: lock not acquired")
+
+// MySQL 的锁属于连接，持有期间占用一个连接
+var locks struct {
+	sync.Mutex
+	m map[string]*sql.Conn
+}
+
+// Lock 用 GET_LOCK 获取名为 name 的锁，timeout 为负数时一直等待
+//
+// 锁在 Unlock 或连接断开时释放。同一进程中重复获取同名的锁返回错误。
+func Lock(name string, timeout time.Duration) error {
+	if getProxyMode() == Vitess {
+		return ErrProxyUnsupported
+	}
+	locks.Lock()
+	_, held := locks.m[name]
+	locks.Unlock()
+	if held {
+		return fmt.Errorf("db: the lock (%s) is already held by this process", name)
+	}
+	ctx := context.Background()
+	c, err := conn().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	seconds := timeout.Seconds()
+	if timeout < 0 {
+		seconds = -1
+	}
+	var ok sql.NullInt64
+	if err = c.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, seconds).Scan(&ok); err != nil {
+		c.Close()
+		return err
+	}
+	if ok.Int64 != 1 {
+		c.Close()
+		return ErrLockNotAcquired
+	}
+	locks.Lock()
+	if locks.m == nil {
+		locks.m = make(map[string]*sql.Conn)
+	}
+	locks.m[name] = c
+	locks.Unlock()
+	return nil
+}
+
+// Unlock 释放 Lock 获得的锁
+func Unlock(name string) error {
+	locks.Lock()
+	c, ok := locks.m[name]
+	delete(locks.m, name)
+	locks.Unlock()
+	if !ok {
+		return fmt.Errorf("db: the lock (%s) is not held", name)
+	}
+	defer c.Close()
+	var released sql.NullInt64
+	if err := c.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", name).Scan(&released); err != nil {
+		return err
+	}
+	if released.Int64 != 1 {
+		return fmt.Errorf("db: the lock (%s) was lost", name)
+	}
+	return nil
+}
+
+// WithLock 尝试获取锁并执行 fn，锁被其他实例持有时不等待，返回 ErrLockNotAcquired
+//
+// 适合多个实例中只需要一个执行的定时任务。
+func WithLock(name string, fn func() error) error {
+	if err := Lock(name, 0); err != nil {
+		return err
+	}
+	err := fn()
+	if uerr := Unlock(name); err == nil {
+		err = uerr
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MapBy 读取所有行，按指定字段的值建立索引，读取完后关闭 Rows
+//
+// 字段值重复时保留最后一行，[]byte 类型的值转换为 string 作为键。
+func (rs *Rows) MapBy(column string) (map[interface{}]map[string]interface{}, error) {
+	defer rs.Close()
+	k, err := rs.t.indexOf(column)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[interface{}]map[string]interface{})
+	for rs.Next() {
+		row, err := rs.Map()
+		if err != nil {
+			return nil, err
+		}
+		key := parseValue(rs.scans[k])
+		//[]byte 不能作为 map 的键
+		if b, ok := key.([]byte); ok {
+			key = string(b)
+		}
+		data[key] = row
+	}
+	if err = rs.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// MapBy 读取所有行到结构体 T，按指定字段的值转换成 K 建立索引，读取完后关闭 Rows
+//
+//	users, err := db.MapBy[int64, User](rows, "id")
+func MapBy[K comparable, T any](rs *Rows, column string) (map[K]T, error) {
+	defer rs.Close()
+	k, err := rs.t.indexOf(column)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[K]T)
+	for rs.Next() {
+		var row T
+		if err = rs.Struct(&row); err != nil {
+			return nil, err
+		}
+		var key K
+		if err = rs.t.convertValue(&key, rs.scans[k]); err != nil {
+			return nil, fmt.Errorf("db: the column (%s) can't convert to %s: %s", column, reflect.TypeOf(key), err)
+		}
+		data[key] = row
+	}
+	if err = rs.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,278 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 结构体的映射，按 (表结构, 结构体类型) 缓存
+type structPlan struct {
+	//指向结构体的指针类型
+	typ    reflect.Type
+	fields []fieldPlan
+}
+
+// 一个字段的映射
+type fieldPlan struct {
+	//读到的第几列
+	column int
+	//结构体中的字段
+	index []int
+	set   func(field reflect.Value, scan interface{}, strict bool) error
+}
+
+type planKey struct {
+	typ  reflect.Type
+	mode int
+}
+
+// 结构体映射的方式
+const (
+	//字段数必须与表的字段数相同，按位置对应
+	MapStrict int = iota
+	//按位置对应前 min(字段数, 表的字段数) 个字段
+	MapPosition
+	//按 db 标签或不区分大小写的名称对应，没有对应的字段和列都忽略
+	MapName
+)
+
+// SetStructMapping 设置 Struct 读取结构体时的映射方式，默认为 MapStrict
+//
+// 表增加字段后 MapStrict 会使所有读取该表的结构体报错，MapPosition 和 MapName 可以平滑过渡。
+func (t *Table) SetStructMapping(mode int) {
+	t.structMode = mode
+}
+
+// 检查目标并取得映射
+func (t Table) structDest(dest interface{}) (reflect.Value, *structPlan, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr {
+		return rv, nil, fmt.Errorf("db: the object (%s) is not a pointer", rv.Kind())
+	}
+	if rv.Elem().Kind() != reflect.Struct {
+		return rv, nil, fmt.Errorf("db: the pointer (%s) is not point to a struct object", rv.Elem().Kind())
+	}
+	plan, err := t.structPlan(rv.Type())
+	return rv.Elem(), plan, err
+}
+
+func (t Table) structPlan(typ reflect.Type) (*structPlan, error) {
+	if len(t.Fields) == 0 {
+		return nil, fmt.Errorf("db: the table (%s) has no columns", t.TbName)
+	}
+	if t.cache == nil {
+		return t.buildPlan(typ)
+	}
+	key := planKey{typ: typ, mode: t.structMode}
+	if plan, ok := t.cache.plans.Load(key); ok {
+		return plan.(*structPlan), nil
+	}
+	plan, err := t.buildPlan(typ)
+	if err != nil {
+		return nil, err
+	}
+	t.cache.plans.Store(key, plan)
+	return plan, nil
+}
+
+func (t Table) buildPlan(typ reflect.Type) (*structPlan, error) {
+	leaves := structLeaves(typ.Elem(), nil, "")
+	n := t.Len
+	switch t.structMode {
+	case MapName:
+		return t.buildNamePlan(typ, leaves)
+	case MapPosition:
+		if len(leaves) < n {
+			n = len(leaves)
+		}
+	default:
+		if len(leaves) != t.Len {
+			return nil, fmt.Errorf("db: the object field numbers (%d) not equals table column numbers (%d)", len(leaves), t.Len)
+		}
+	}
+	plan := &structPlan{typ: typ, fields: make([]fieldPlan, n)}
+	scans := t.makeNullableScans()
+	for i := 0; i < n; i++ {
+		plan.fields[i] = fieldPlan{column: i, index: leaves[i].index, set: makeSetter(scans[i], leaves[i].field.Type)}
+	}
+	return plan, nil
+}
+
+// 按名称对应的映射
+func (t Table) buildNamePlan(typ reflect.Type, leaves []structLeaf) (*structPlan, error) {
+	plan := &structPlan{typ: typ, fields: make([]fieldPlan, 0, t.Len)}
+	scans := t.makeNullableScans()
+	for _, leaf := range leaves {
+		if leaf.field.PkgPath != "" {
+			continue
+		}
+		name := leaf.name()
+		for k := range t.Fields {
+			if strings.EqualFold(t.Fields[k].Name, name) {
+				plan.fields = append(plan.fields, fieldPlan{column: k, index: leaf.index, set: makeSetter(scans[k], leaf.field.Type)})
+				break
+			}
+		}
+	}
+	return plan, nil
+}
+
+// 结构体中对应一列的字段
+type structLeaf struct {
+	field reflect.StructField
+	index []int
+	//嵌套结构体的列名前缀
+	prefix string
+}
+
+// 对应的列名，标签中的名称优先
+func (l structLeaf) name() string {
+	name := l.field.Name
+	if tag, ok := l.field.Tag.Lookup("db"); ok {
+		if n := strings.Split(tag, ",")[0]; n != "" {
+			name = n
+		}
+	}
+	return l.prefix + name
+}
+
+// 按顺序展开结构体的字段
+//
+// 嵌入的结构体展开为它的字段，例如 User 嵌入 BaseModel{ID, CreatedAt}；
+// 标签带有 prefix 选项的结构体字段也展开，列名为前缀加字段名，
+// 例如 Address Address `db:"address_,prefix"` 的 City 对应 address_city。
+// time.Time 和实现了 sql.Scanner 的结构体作为一个值，skipField 排除的字段不计入。
+func structLeaves(st reflect.Type, index []int, prefix string) []structLeaf {
+	leaves := make([]structLeaf, 0, st.NumField())
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		if skipField(sf) {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		if isComposite(sf.Type) {
+			if sf.Anonymous {
+				leaves = append(leaves, structLeaves(sf.Type, idx, prefix)...)
+				continue
+			}
+			if name, ok := prefixOf(sf); ok {
+				leaves = append(leaves, structLeaves(sf.Type, idx, prefix+name)...)
+				continue
+			}
+		}
+		leaves = append(leaves, structLeaf{field: sf, index: idx, prefix: prefix})
+	}
+	return leaves
+}
+
+// 不对应列的字段：未导出的字段、标签为 db:"-" 的字段以及函数和 channel
+//
+// 嵌入的未导出结构体仍然展开，它的导出字段可以访问。
+func skipField(sf reflect.StructField) bool {
+	if sf.PkgPath != "" && !sf.Anonymous {
+		return true
+	}
+	if sf.Tag.Get("db") == "-" {
+		return true
+	}
+	switch sf.Type.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+// 可以展开的结构体类型
+func isComposite(typ reflect.Type) bool {
+	if _, ok := converterOf(typ); ok {
+		return false
+	}
+	return typ.Kind() == reflect.Struct && typ != timeType && !reflect.PtrTo(typ).Implements(scannerType)
+}
+
+// 标签 db:"前缀,prefix" 中的前缀
+func prefixOf(sf reflect.StructField) (string, bool) {
+	tag, ok := sf.Tag.Lookup("db")
+	if !ok {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if strings.TrimSpace(opt) == "prefix" {
+			return parts[0], true
+		}
+	}
+	return "", false
+}
+
+// 把读到的值写入结构体
+func (p *structPlan) assign(rv reflect.Value, scans []interface{}, strict bool) error {
+	for i := range p.fields {
+		f := &p.fields[i]
+		if err := f.set(rv.FieldByIndex(f.index), scans[f.column], strict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// 常见的类型直接赋值，其余交给 convertValue
+func makeSetter(scan interface{}, ft reflect.Type) func(reflect.Value, interface{}, bool) error {
+	convert := func(field reflect.Value, scan interface{}, strict bool) error {
+		return convertValueMode(field.Addr().Interface(), scan, strict)
+	}
+	if _, ok := converterOf(ft); ok || reflect.PtrTo(ft).Implements(scannerType) {
+		return convert
+	}
+	switch scan.(type) {
+	case *sql.NullInt64:
+		if ft.Kind() == reflect.Int64 {
+			return func(field reflect.Value, scan interface{}, strict bool) error {
+				if v := scan.(*sql.NullInt64); v.Valid {
+					field.SetInt(v.Int64)
+					return nil
+				}
+				return convert(field, scan, strict)
+			}
+		}
+	case *sql.NullString:
+		if ft.Kind() == reflect.String {
+			return func(field reflect.Value, scan interface{}, strict bool) error {
+				if v := scan.(*sql.NullString); v.Valid {
+					field.SetString(v.String)
+					return nil
+				}
+				return convert(field, scan, strict)
+			}
+		}
+	case *sql.NullFloat64:
+		if ft.Kind() == reflect.Float64 {
+			return func(field reflect.Value, scan interface{}, strict bool) error {
+				if v := scan.(*sql.NullFloat64); v.Valid {
+					field.SetFloat(v.Float64)
+					return nil
+				}
+				return convert(field, scan, strict)
+			}
+		}
+	case *NullTime:
+		if ft == timeType {
+			return func(field reflect.Value, scan interface{}, strict bool) error {
+				if v := scan.(*NullTime); v.Valid {
+					field.Set(reflect.ValueOf(v.Time))
+					return nil
+				}
+				return convert(field, scan, strict)
+			}
+		}
+	}
+	return convert
+}
@@ -0,0 +1,42 @@
+package db
+
+import "testing"
+
+type benchUser struct {
+	Id   int64
+	Name string
+	Age  int64
+}
+
+func BenchmarkRowStruct(b *testing.B) {
+	t := openFake(b, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var u benchUser
+		if err := t.GetByID(1).Struct(&u); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRowsStruct(b *testing.B) {
+	t := openFake(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rs, err := t.GetMany()
+		if err != nil {
+			b.Fatal(err)
+		}
+		var u benchUser
+		for rs.Next() {
+			if err = rs.Struct(&u); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err = rs.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaskFunc 脱敏函数
+type MaskFunc func(string) string
+
+type unmaskedKey struct{}
+
+// Unmasked 标记上下文可以读取未脱敏的数据
+func Unmasked(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unmaskedKey{}, true)
+}
+
+func isUnmasked(ctx context.Context) bool {
+	ok, _ := ctx.Value(unmaskedKey{}).(bool)
+	return ok
+}
+
+// Mask 指定读取时脱敏的字段
+//
+// Row 和 Rows 的 Scan、Struct、Slice、Map 读到的值都会经过 fn 处理，
+// 除非表的上下文（见 WithContext）带有 Unmasked 标记。字段不存在时忽略。
+func (t *Table) Mask(fn MaskFunc, columns ...string) {
+	if t.masks == nil {
+		t.masks = make(map[int]MaskFunc)
+	}
+	for i := range columns {
+		if k, err := t.indexOf(columns[i]); err == nil {
+			t.masks[k] = fn
+		}
+	}
+}
+
+func (t Table) mask(scans []interface{}) {
+	for i, fn := range t.masks {
+		switch v := scans[i].(type) {
+		case *sql.NullString:
+			if v.Valid {
+				v.String = fn(v.String)
+			}
+		case *NullBytes:
+			if v.Valid {
+				v.Bytes = []byte(fn(string(v.Bytes)))
+			}
+		}
+	}
+}
+
+// MaskAll 全部替换为 *
+func MaskAll(s string) string {
+	return strings.Repeat("*", utf8.RuneCountInString(s))
+}
+
+// MaskEmail 只保留用户名的第一个字符和域名，例如 a***@example.com
+func MaskEmail(s string) string {
+	i := strings.LastIndex(s, "@")
+	if i <= 0 {
+		return MaskAll(s)
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size] + "***" + s[i:]
+}
+
+// MaskPhone 只保留前三位和后四位，例如 138****5678
+func MaskPhone(s string) string {
+	runes := []rune(s)
+	if len(runes) <= 7 {
+		return MaskAll(s)
+	}
+	return string(runes[:3]) + strings.Repeat("*", len(runes)-7) + string(runes[len(runes)-4:])
+}
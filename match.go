@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Match 以结构体中的非零字段作为相等条件构建查询
+//
+//	rows, err := t.Match(User{Status: 1, City: "Berlin"}).GetMany()
+//
+// 字段通过 `db:"字段名"` 标签对应表的字段，`db:"-"` 表示忽略；没有标签时，
+// 结构体字段数与表的字段数相同则按位置对应，否则按名称不区分大小写对应。
+func (t *Table) Match(example interface{}) *Selector {
+	s := t.Select()
+	conds, err := t.exampleConditions(example)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	return s.Where(conds...)
+}
+
+func (t *Table) exampleConditions(example interface{}) ([]Condition, error) {
+	rv := reflect.Indirect(reflect.ValueOf(example))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("db: the example (%T) is not a struct", example)
+	}
+	leaves := structLeaves(rv.Type(), nil, "")
+	conds := make([]Condition, 0)
+	for i, leaf := range leaves {
+		if leaf.field.PkgPath != "" {
+			continue
+		}
+		column, err := t.columnOf(leaf, i, len(leaves))
+		if err != nil {
+			return nil, err
+		}
+		fv := rv.FieldByIndex(leaf.index)
+		if column == "" || fv.IsZero() {
+			continue
+		}
+		conds = append(conds, Eq(column, fv.Interface()))
+	}
+	return conds, nil
+}
+
+// 结构体字段对应的表字段，忽略时返回空字符串
+func (t *Table) columnOf(leaf structLeaf, i, numField int) (string, error) {
+	if _, ok := leaf.field.Tag.Lookup("db"); ok {
+		name := leaf.name()
+		if _, err := t.indexOf(name); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	if numField == t.Len {
+		return t.Fields[i].Name, nil
+	}
+	name := leaf.name()
+	for k := range t.Fields {
+		if strings.EqualFold(t.Fields[k].Name, name) {
+			return t.Fields[k].Name, nil
+		}
+	}
+	return "", fmt.Errorf("db: the struct field (%s) has no matching column in table (%s)", leaf.field.Name, t.TbName)
+}
@@ -0,0 +1,241 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OnlineAlterOptions 在线修改表结构的选项
+type OnlineAlterOptions struct {
+	//每批复制的行数，默认 1000
+	ChunkSize int
+	//每批之间的间隔
+	Interval time.Duration
+	//读取从库延迟，为 nil 时不限流
+	Lag func() (time.Duration, error)
+	//从库延迟超过该值时暂停复制，默认 1 秒
+	MaxLag time.Duration
+	//保留原表（重命名为 _表名_old），默认删除
+	KeepOld bool
+	//每批复制完成后回调，参数为已复制的行数
+	Progress func(copied int64)
+}
+
+// OnlineAlter 通过影子表修改表结构，避免长时间锁表
+//
+// 过程与 pt-online-schema-change 相同：按原表结构创建影子表并执行 spec，
+// 在原表上建立触发器同步增量修改，按主键分批复制已有数据，最后原子地交换表名。
+// 要求表有整数主键，spec 为 ALTER TABLE 之后的部分，例如 "ADD COLUMN `age` int(11) NOT NULL DEFAULT 0"。
+func (t *Table) OnlineAlter(spec string, opt OnlineAlterOptions) error {
+	if opt.ChunkSize <= 0 {
+		opt.ChunkSize = 1000
+	}
+	if opt.MaxLag <= 0 {
+		opt.MaxLag = time.Second
+	}
+	pk, err := t.indexOf(t.PrimaryKey)
+	if err != nil {
+		return err
+	}
+	//按主键的数值范围分批复制
+	if v := t.Fields[pk].Type.Value; v != TypeInt && v != TypeBigint {
+		return fmt.Errorf("db: online alter requires an integer primary key, (%s) is %s", t.PrimaryKey, t.Fields[pk].Type.Name)
+	}
+	//读取边界和复制都在主库上，与触发器同步的修改一致
+	p := t.Primary()
+	shadow := fmt.Sprintf("%s.`_%s_new`", t.DbName, t.TbName)
+	old := fmt.Sprintf("%s.`_%s_old`", t.DbName, t.TbName)
+	if _, err = p.exec(fmt.Sprintf("CREATE TABLE %s LIKE %s", shadow, t.Fullname)); err != nil {
+		return err
+	}
+	var triggers map[string]string
+	dropTriggers := func() {
+		for name := range triggers {
+			p.exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s.`%s`", t.DbName, name))
+		}
+		triggers = nil
+	}
+	//先删除触发器，否则原表上的写入在影子表删除后失败
+	abort := func(err error) error {
+		dropTriggers()
+		p.exec(fmt.Sprintf("DROP TABLE %s", shadow))
+		return err
+	}
+	if _, err = p.exec(fmt.Sprintf("ALTER TABLE %s %s", shadow, spec)); err != nil {
+		return abort(err)
+	}
+	columns, err := p.shadowColumns(fmt.Sprintf("_%s_new", t.TbName))
+	if err != nil {
+		return abort(err)
+	}
+	triggers = t.shadowTriggers(shadow, columns)
+	for name, body := range triggers {
+		if _, err = p.exec(fmt.Sprintf("CREATE TRIGGER %s.`%s` %s", t.DbName, name, body)); err != nil {
+			return abort(err)
+		}
+	}
+	if err = p.copyChunks(shadow, t.Fields[pk].FullName, columns, opt); err != nil {
+		return abort(err)
+	}
+	if _, err = p.exec(fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", t.Fullname, old, shadow, t.Fullname)); err != nil {
+		return abort(err)
+	}
+	dropTriggers()
+	if !opt.KeepOld {
+		if _, err = p.exec(fmt.Sprintf("DROP TABLE %s", old)); err != nil {
+			return err
+		}
+	}
+	return t.Refresh()
+}
+
+// 原表和影子表共有的字段
+func (t *Table) shadowColumns(shadow string) ([]string, error) {
+	rows, err := t.query("SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+		t.DbName, shadow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make([]string, 0, len(t.Fields))
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if _, err = t.indexOf(name); err == nil {
+			columns = append(columns, name)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("db: the table (%s.%s) does not exist", t.DbName, shadow)
+	}
+	return columns, nil
+}
+
+// 同步增量修改的触发器
+func (t *Table) shadowTriggers(shadow string, columns []string) map[string]string {
+	cols := "`" + strings.Join(columns, "`, `") + "`"
+	values := "NEW.`" + strings.Join(columns, "`, NEW.`") + "`"
+	replace := fmt.Sprintf("REPLACE INTO %s (%s) VALUES (%s)", shadow, cols, values)
+	//修改了主键时删除影子表中的旧行
+	moved := fmt.Sprintf("DELETE IGNORE FROM %s WHERE NOT (OLD.`%s` <=> NEW.`%s`) AND `%s` <=> OLD.`%s`",
+		shadow, t.PrimaryKey, t.PrimaryKey, t.PrimaryKey, t.PrimaryKey)
+	return map[string]string{
+		fmt.Sprintf("_%s_ins", t.TbName): fmt.Sprintf("AFTER INSERT ON %s FOR EACH ROW %s", t.Fullname, replace),
+		fmt.Sprintf("_%s_upd", t.TbName): fmt.Sprintf("AFTER UPDATE ON %s FOR EACH ROW BEGIN %s; %s; END", t.Fullname, moved, replace),
+		fmt.Sprintf("_%s_del", t.TbName): fmt.Sprintf("AFTER DELETE ON %s FOR EACH ROW DELETE IGNORE FROM %s WHERE `%s` = OLD.`%s`",
+			t.Fullname, shadow, t.PrimaryKey, t.PrimaryKey),
+	}
+}
+
+// 按主键分批复制
+func (t *Table) copyChunks(shadow, pk string, columns []string, opt OnlineAlterOptions) error {
+	cols := "`" + strings.Join(columns, "`, `") + "`"
+	var last int64
+	var copied int64
+	var started bool
+	for {
+		if err := waitLag(opt); err != nil {
+			return err
+		}
+		var upper sql.NullInt64
+		var err error
+		if started {
+			err = t.queryRow(fmt.Sprintf("SELECT MAX(x.pk) FROM (SELECT %s AS pk FROM %s WHERE %s > ? ORDER BY %s LIMIT ?) x",
+				pk, t.Fullname, pk, pk), last, opt.ChunkSize).Scan(&upper)
+		} else {
+			err = t.queryRow(fmt.Sprintf("SELECT MAX(x.pk) FROM (SELECT %s AS pk FROM %s ORDER BY %s LIMIT ?) x",
+				pk, t.Fullname, pk), opt.ChunkSize).Scan(&upper)
+		}
+		if err != nil {
+			return err
+		}
+		if !upper.Valid {
+			return nil
+		}
+		var res sql.Result
+		if started {
+			res, err = t.exec(fmt.Sprintf("INSERT IGNORE INTO %s (%s) SELECT %s FROM %s WHERE %s > ? AND %s <= ? LOCK IN SHARE MODE",
+				shadow, cols, cols, t.Fullname, pk, pk), last, upper.Int64)
+		} else {
+			res, err = t.exec(fmt.Sprintf("INSERT IGNORE INTO %s (%s) SELECT %s FROM %s WHERE %s <= ? LOCK IN SHARE MODE",
+				shadow, cols, cols, t.Fullname, pk), upper.Int64)
+		}
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		copied += n
+		if opt.Progress != nil {
+			opt.Progress(copied)
+		}
+		last, started = upper.Int64, true
+		if opt.Interval > 0 {
+			time.Sleep(opt.Interval)
+		}
+	}
+}
+
+// 从库延迟过大时等待
+func waitLag(opt OnlineAlterOptions) error {
+	if opt.Lag == nil {
+		return nil
+	}
+	for {
+		lag, err := opt.Lag()
+		if err != nil {
+			return err
+		}
+		if lag <= opt.MaxLag {
+			return nil
+		}
+		time.Sleep(lag - opt.MaxLag)
+	}
+}
+
+// ReplicaLag 读取从库的复制延迟，可以用作 OnlineAlterOptions.Lag
+//
+// 8.0.22 起为 SHOW REPLICA STATUS，8.4 删除了 SHOW SLAVE STATUS，旧版本的服务器上改用后者。
+func ReplicaLag(replica *sql.DB) (time.Duration, error) {
+	rows, err := replica.Query("SHOW REPLICA STATUS")
+	if err != nil {
+		if rows, err = replica.Query("SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, fmt.Errorf("db: the server is not a replica")
+	}
+	values := make([]sql.RawBytes, len(columns))
+	scans := make([]interface{}, len(columns))
+	for i := range values {
+		scans[i] = &values[i]
+	}
+	if err = rows.Scan(scans...); err != nil {
+		return 0, err
+	}
+	for i := range columns {
+		if columns[i] == "Seconds_Behind_Master" || columns[i] == "Seconds_Behind_Source" {
+			if values[i] == nil {
+				return 0, fmt.Errorf("db: the replica is not running")
+			}
+			var seconds int64
+			if err = convertValue(&seconds, []byte(values[i])); err != nil {
+				return 0, err
+			}
+			return time.Duration(seconds) * time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("db: the replica lag column not found")
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOnlineAlterCleanup(t *testing.T) {
+	users := openFake(t, 3)
+	fake.Lock()
+	fake.fail = "CREATE TRIGGER"
+	fake.Unlock()
+	if err := users.OnlineAlter("ADD COLUMN `x` int(11)", OnlineAlterOptions{}); err == nil {
+		t.Fatal("OnlineAlter succeeded with a failing trigger")
+	}
+	fake.Lock()
+	execs := append([]string(nil), fake.execs...)
+	fake.Unlock()
+	//触发器都在影子表之前删除
+	drops, dropTable := 0, -1
+	for i, q := range execs {
+		switch {
+		case strings.HasPrefix(q, "DROP TRIGGER"):
+			if dropTable >= 0 {
+				t.Fatalf("trigger dropped after the shadow table: %q", execs)
+			}
+			drops++
+		case strings.HasPrefix(q, "DROP TABLE test.`_users_new`"):
+			dropTable = i
+		}
+	}
+	if drops != 3 || dropTable < 0 {
+		t.Fatalf("statements = %q", execs)
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import "errors"
+
+// 分页读取的数据来源，多查询一行用来判断是否还有下一页
+type pagedSource struct {
+	rowsSource
+	take int
+	n    int
+	more bool
+}
+
+func (s *pagedSource) Next() bool {
+	if s.n >= s.take {
+		//只在读完本页时多读一次
+		if s.n == s.take {
+			s.more = s.rowsSource.Next()
+			s.n++
+		}
+		return false
+	}
+	if !s.rowsSource.Next() {
+		return false
+	}
+	s.n++
+	return true
+}
+
+// GetPage 查询一页，多读一行判断后面是否还有数据，通过 Rows.HasMore 读取
+//
+// 适用于“加载更多”的场景，不需要额外的 COUNT 查询。忽略 Limit 设置的分页。
+func (s *Selector) GetPage(take, skip int) (*Rows, error) {
+	if take <= 0 {
+		return nil, errors.New("db: the page size must be positive")
+	}
+	limit, offset := s.limit, s.skip
+	s.limit, s.skip = take+1, skip
+	rs, err := s.GetMany()
+	s.limit, s.skip = limit, offset
+	if err != nil {
+		return nil, err
+	}
+	rs.page = &pagedSource{rowsSource: rs.source(), take: take}
+	return rs, nil
+}
+
+// HasMore GetPage 返回的结果后面是否还有数据，读完本页之后才有效
+func (rs *Rows) HasMore() bool {
+	return rs.page != nil && rs.page.more
+}
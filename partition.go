@@ -0,0 +1,87 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Partitioning 表的分区方式
+type Partitioning struct {
+	//RANGE、RANGE COLUMNS、LIST、LIST COLUMNS、HASH、LINEAR HASH、KEY、LINEAR KEY
+	Method string
+	//分区表达式或字段列表，例如 "to_days(`created`)"
+	Expression string
+	Partitions []Partition
+}
+
+// Partition 一个分区
+type Partition struct {
+	Name string
+	//RANGE 分区的上界或 LIST 分区的取值，HASH 和 KEY 分区为空
+	Description string
+	Comment     string
+}
+
+// 读取表的分区，未分区时返回 nil
+func getPartitioning(dbname, tbname string) (*Partitioning, error) {
+	rows, err := Query(`
+	SELECT
+		PARTITION_NAME, PARTITION_METHOD, PARTITION_EXPRESSION,
+		PARTITION_DESCRIPTION, PARTITION_COMMENT
+	FROM
+		information_schema.PARTITIONS
+	WHERE
+		TABLE_SCHEMA = ? AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL
+	ORDER BY
+		PARTITION_ORDINAL_POSITION
+	`, dbname, tbname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var p *Partitioning
+	for rows.Next() {
+		var name, method string
+		var expression, description sql.NullString
+		var comment string
+		if err = rows.Scan(&name, &method, &expression, &description, &comment); err != nil {
+			return nil, err
+		}
+		if p == nil {
+			p = &Partitioning{Method: method, Expression: expression.String}
+		}
+		p.Partitions = append(p.Partitions, Partition{Name: name, Description: description.String, Comment: comment})
+	}
+	return p, rows.Err()
+}
+
+// ToSql 生成 PARTITION BY 子句
+func (p Partitioning) ToSql() string {
+	method := strings.ToUpper(p.Method)
+	if strings.HasSuffix(method, "HASH") || strings.HasSuffix(method, "KEY") {
+		return fmt.Sprintf("PARTITION BY %s (%s) PARTITIONS %d", method, p.Expression, len(p.Partitions))
+	}
+	items := make([]string, len(p.Partitions))
+	for i := range p.Partitions {
+		items[i] = "\t" + p.Partitions[i].toSql(method)
+	}
+	return fmt.Sprintf("PARTITION BY %s (%s) (\n%s\n)", method, p.Expression, strings.Join(items, ",\n"))
+}
+
+func (p Partition) toSql(method string) string {
+	var values string
+	switch {
+	case strings.HasPrefix(method, "RANGE") && p.Description == "MAXVALUE":
+		values = "VALUES LESS THAN MAXVALUE"
+	case strings.HasPrefix(method, "RANGE"):
+		values = fmt.Sprintf("VALUES LESS THAN (%s)", p.Description)
+	case strings.HasPrefix(method, "LIST"):
+		values = fmt.Sprintf("VALUES IN (%s)", p.Description)
+	}
+	strSql := fmt.Sprintf("PARTITION `%s` %s", p.Name, values)
+	if p.Comment != "" {
+		strSql += fmt.Sprintf(" COMMENT '%s'", strings.ReplaceAll(p.Comment, "'", "''"))
+	}
+	return strSql
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Pluck 取出一列的值追加到 dest 指向的切片，可以附加过滤条件
+//
+//	var emails []string
+//	err := t.Pluck("email", &emails)
+func (t *Table) Pluck(column string, dest interface{}, conds ...Condition) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("db: the dest (%T) is not a pointer", dest)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("db: the pointer (%s) is not point to a slice", rv.Kind())
+	}
+	i, err := t.indexOf(column)
+	if err != nil {
+		return err
+	}
+	where, args, err := t.sqlWhere(conds)
+	if err != nil {
+		return err
+	}
+	rows, err := t.query(fmt.Sprintf("SELECT %s FROM %s %s", t.Fields[i].FullName, t.Fullname, where), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	//其他字段始终为 NULL，解密、脱敏等处理只作用于这一列
+	scans := t.makeNullableScans()
+	for rows.Next() {
+		if err = t.scan(columnScanner{rows, i}, scans); err != nil {
+			return err
+		}
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		//NULL 使用零值
+		if parseValue(scans[i]) != nil {
+			if err = t.convertElem(elem, scans[i]); err != nil {
+				return err
+			}
+		}
+		rv.Set(reflect.Append(rv, elem))
+	}
+	return rows.Err()
+}
+
+// 只查询了一列的结果集，读取到 scans 中对应的位置
+type columnScanner struct {
+	rows *sql.Rows
+	i    int
+}
+
+func (s columnScanner) Scan(dest ...interface{}) error {
+	return s.rows.Scan(dest[s.i])
+}
+
+// convertValue 直接支持的整数类型
+var directInts = map[reflect.Type]bool{
+	reflect.TypeOf(int64(0)):         true,
+	reflect.TypeOf(int16(0)):         true,
+	reflect.TypeOf(time.Duration(0)): true,
+}
+
+// 转换读到的值，convertValue 不支持的整数和浮点类型先转换为 int64 或 float64
+func (t Table) convertElem(elem reflect.Value, scan interface{}) error {
+	if _, ok := converterOf(elem.Type()); ok || directInts[elem.Type()] || elem.Addr().Type().Implements(scannerType) {
+		return t.convertValue(elem.Addr().Interface(), scan)
+	}
+	switch elem.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if err := t.convertValue(&n, scan); err != nil {
+			return err
+		}
+		if elem.OverflowInt(n) {
+			return fmt.Errorf("db: the int64(%v) overflows %s", n, elem.Type())
+		}
+		elem.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n int64
+		if err := t.convertValue(&n, scan); err != nil {
+			return err
+		}
+		if n < 0 || elem.OverflowUint(uint64(n)) {
+			return fmt.Errorf("db: the int64(%v) overflows %s", n, elem.Type())
+		}
+		elem.SetUint(uint64(n))
+		return nil
+	case reflect.Float32:
+		var f float64
+		if err := t.convertValue(&f, scan); err != nil {
+			return err
+		}
+		elem.SetFloat(f)
+		return nil
+	}
+	return t.convertValue(elem.Addr().Interface(), scan)
+}
+
+// ScalarInt64 查询单个整数，NULL 返回 0
+func ScalarInt64(query string, args ...interface{}) (int64, error) {
+	var v sql.NullInt64
+	if err := QueryRow(query, args...).Scan(&v); err != nil {
+		return 0, err
+	}
+	return v.Int64, nil
+}
+
+// ScalarFloat64 查询单个浮点数，NULL 返回 0
+func ScalarFloat64(query string, args ...interface{}) (float64, error) {
+	var v sql.NullFloat64
+	if err := QueryRow(query, args...).Scan(&v); err != nil {
+		return 0, err
+	}
+	return v.Float64, nil
+}
+
+// ScalarString 查询单个字符串，NULL 返回空字符串
+func ScalarString(query string, args ...interface{}) (string, error) {
+	var v sql.NullString
+	if err := QueryRow(query, args...).Scan(&v); err != nil {
+		return "", err
+	}
+	return v.String, nil
+}
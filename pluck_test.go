@@ -0,0 +1,31 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPluck(t *testing.T) {
+	users := openFake(t, 3)
+	var ids []int
+	if err := users.Pluck("id", &ids); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Fatalf("ids = %v", ids)
+	}
+	var ages []uint8
+	if err := users.Pluck("age", &ages); err != nil || len(ages) != 3 || ages[1] != 2 {
+		t.Fatalf("ages = %v, %v", ages, err)
+	}
+	users.Mask(MaskAll, "name")
+	var names []string
+	if err := users.Pluck("name", &names); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if strings.Trim(name, "*") != "" {
+			t.Fatalf("Pluck returned the unmasked name %q", name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package db
+
+import "sync"
+
+// 一次读取表结构得到的缓存，表的副本共用，Refresh 后随新的表结构重新创建，旧的随旧表回收
+type schemaCache struct {
+	//读取缓冲
+	scans sync.Pool
+	//结构体映射，键为 planKey
+	plans sync.Map
+}
+
+// 表结构确定之后创建缓存
+func (t *Table) newSchemaCache() {
+	fields := Table{Fields: t.Fields, Len: t.Len}
+	c := &schemaCache{}
+	c.scans.New = func() interface{} {
+		return fields.makeNullableScans()
+	}
+	t.cache = c
+}
+
+// 取得一组读取缓冲，用完后用 putScans 归还
+func (t Table) getScans() []interface{} {
+	if t.cache != nil {
+		return t.cache.scans.Get().([]interface{})
+	}
+	return t.makeNullableScans()
+}
+
+// 归还读取缓冲，归还后不能再使用
+func (t Table) putScans(scans []interface{}) {
+	if t.cache != nil && len(scans) == t.Len {
+		t.cache.scans.Put(scans)
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import "testing"
+
+func BenchmarkGetMany100k(b *testing.B) {
+	t := openFake(b, 100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rs, err := t.GetMany()
+		if err != nil {
+			b.Fatal(err)
+		}
+		var id, age int64
+		var name string
+		for rs.Next() {
+			if err = rs.Scan(&id, &name, &age); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err = rs.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetScans(b *testing.B) {
+	t := openFake(b, 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.putScans(t.getScans())
+	}
+}
+
+func TestSchemaCache(t *testing.T) {
+	users := openFake(t, 1)
+	old := users.cache
+	if old == nil {
+		t.Fatal("GetTable did not create the schema cache")
+	}
+	if users.Primary().cache != old {
+		t.Fatal("a copy of the table does not share the schema cache")
+	}
+	var u benchUser
+	if err := users.GetByID(1).Struct(&u); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if users.cache == nil || users.cache == old {
+		t.Fatal("Refresh kept the old schema cache")
+	}
+	n := 0
+	users.cache.plans.Range(func(key, value interface{}) bool {
+		n++
+		return true
+	})
+	if n != 0 {
+		t.Fatalf("the new schema cache has %d plans", n)
+	}
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// 执行时给出的参数
+type placeholder struct{}
+
+// Prepared 预编译的查询，可以在多个 goroutine 中同时使用
+type Prepared struct {
+	t *Table
+	//条件参数，placeholder 的位置在执行时依次填入
+	args  []interface{}
+	slots int
+
+	stmtRow   *sql.Stmt
+	stmtRows  *sql.Stmt
+	stmtCount *sql.Stmt
+}
+
+// Prepare 预编译按条件查询的语句
+//
+// 省略了值的 Eq 条件作为参数，执行时按顺序给出：
+//
+//	q, err := t.Prepare(db.Eq("id"))
+//	row := q.Get(42)
+//
+// 不再使用时调用 Close 释放语句。
+func (t *Table) Prepare(conds ...Condition) (*Prepared, error) {
+	q := &Prepared{t: t, args: make([]interface{}, 0)}
+	conds = q.markSlots(conds)
+	where, args, err := t.sqlWhere(conds)
+	if err != nil {
+		return nil, err
+	}
+	q.args = args
+	ctx := t.context()
+	if q.stmtRow, err = prepareOn(t.sqlDB(), ctx, defaultExecutionTime(fmt.Sprintf("%s %s limit 1", t.sqlSelect, where))); err != nil {
+		return nil, err
+	}
+	if q.stmtRows, err = prepareOn(t.sqlDB(), ctx, defaultExecutionTime(limitQuery(fmt.Sprintf("%s %s", t.sqlSelect, where)))); err != nil {
+		q.Close()
+		return nil, err
+	}
+	if q.stmtCount, err = prepareOn(t.sqlDB(), ctx, defaultExecutionTime(fmt.Sprintf("%s %s", t.sqlSelectCount, where))); err != nil {
+		q.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// 把省略了值的 Eq 条件换成参数占位，包括 And、Or、Not 中的子条件
+func (q *Prepared) markSlots(conds []Condition) []Condition {
+	conds = append([]Condition(nil), conds...)
+	for i := range conds {
+		switch {
+		case conds[i].conds != nil:
+			conds[i].conds = q.markSlots(conds[i].conds)
+		case conds[i].op == "=" && conds[i].args == nil:
+			conds[i].args = []interface{}{placeholder{}}
+			q.slots++
+		}
+	}
+	return conds
+}
+
+// 填入执行时的参数
+func (q *Prepared) bind(args []interface{}) ([]interface{}, error) {
+	if len(args) != q.slots {
+		return nil, fmt.Errorf("db: the prepared query expects %d arguments, got %d", q.slots, len(args))
+	}
+	if q.slots == 0 {
+		return q.args, nil
+	}
+	bound := make([]interface{}, len(q.args))
+	n := 0
+	for i := range q.args {
+		if _, ok := q.args[i].(placeholder); ok {
+			bound[i] = args[n]
+			n++
+		} else {
+			bound[i] = q.args[i]
+		}
+	}
+	return bound, nil
+}
+
+// Get 查询一行
+func (q *Prepared) Get(args ...interface{}) *Row {
+	bound, err := q.bind(args)
+	if err != nil {
+		return &Row{t: q.t, err: err}
+	}
+	return &Row{
+		Row: q.stmtRow.QueryRowContext(q.t.context(), convertArgs(bound)...), t: q.t,
+	}
+}
+
+// GetMany 查询多行
+func (q *Prepared) GetMany(args ...interface{}) (*Rows, error) {
+	bound, err := q.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.stmtRows.QueryContext(q.t.context(), convertArgs(bound)...)
+	if err != nil {
+		return nil, err
+	}
+	return (&Rows{Rows: rows, t: q.t, scans: q.t.getScans()}).guard().watch(), nil
+}
+
+// Count 统计行数
+func (q *Prepared) Count(args ...interface{}) (int64, error) {
+	bound, err := q.bind(args)
+	if err != nil {
+		return -1, err
+	}
+	var num int64
+	if err = q.stmtCount.QueryRowContext(q.t.context(), convertArgs(bound)...).Scan(&num); err != nil {
+		return -1, err
+	}
+	return num, nil
+}
+
+// Close 释放预编译的语句
+func (q *Prepared) Close() error {
+	var err error
+	for _, stmt := range []*sql.Stmt{q.stmtRow, q.stmtRows, q.stmtCount} {
+		if stmt == nil {
+			continue
+		}
+		if e := stmt.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPreparedGetManyGuarded(t *testing.T) {
+	users := openFake(t, 5)
+	SetGuardrails(Guardrails{MaxRows: 2})
+	defer SetGuardrails(Guardrails{})
+	q, err := users.Prepare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	rs, err := q.GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	n := 0
+	for rs.Next() {
+		n++
+	}
+	if n != 2 || !errors.Is(rs.Err(), ErrTooManyRows) {
+		t.Fatalf("read %d rows, Err() = %v, want 2 rows and ErrTooManyRows", n, rs.Err())
+	}
+}
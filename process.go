@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Process 服务器上的一个连接及其正在执行的语句
+type Process struct {
+	Id      int64
+	User    string
+	Host    string
+	Db      string
+	Command string
+	Time    time.Duration
+	State   string
+	Info    string
+}
+
+// ProcessList 列出所有连接，执行时间长的排在前面
+func ProcessList() ([]Process, error) {
+	rows, err := Query(`
+	SELECT
+		ID, USER, HOST, DB, COMMAND, TIME, STATE, INFO
+	FROM
+		information_schema.PROCESSLIST
+	ORDER BY
+		TIME DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := make([]Process, 0)
+	for rows.Next() {
+		var p Process
+		var dbname, state, info sql.NullString
+		var seconds int64
+		if err = rows.Scan(&p.Id, &p.User, &p.Host, &dbname, &p.Command, &seconds, &state, &info); err != nil {
+			return nil, err
+		}
+		p.Db, p.State, p.Info = dbname.String, state.String, info.String
+		p.Time = time.Duration(seconds) * time.Second
+		list = append(list, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// KillQuery 终止连接上正在执行的语句，连接本身保留
+func KillQuery(id int64) error {
+	_, err := Exec(fmt.Sprintf("KILL QUERY %d", id))
+	return err
+}
+
+// KillConnection 断开指定的连接
+func KillConnection(id int64) error {
+	_, err := Exec(fmt.Sprintf("KILL CONNECTION %d", id))
+	return err
+}
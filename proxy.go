@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
+)
+
+// 代理兼容模式
+const (
+	//直接连接 MySQL
+	ProxyNone int32 = iota
+	//通过 ProxySQL 连接
+	ProxySQL
+	//通过 Vitess 的 vtgate 连接
+	Vitess
+)
+
+// ErrProxyUnsupported 当前代理模式下不支持的操作
+var ErrProxyUnsupported = errors.New("db: the operation is not supported behind the proxy")
+
+var proxyMode int32
+
+// SetProxyMode 设置代理兼容模式，必须在 Open 之前调用
+//
+// 代理模式下：
+//   - 连接参数加上 interpolateParams=true，参数在客户端拼接，不使用服务端预编译语句，
+//     NamedQuery 也不再预编译；
+//   - 不执行依赖会话状态的语句，Lock 在 Vitess 模式下返回 ErrProxyUnsupported；
+//   - WithRoute 和 WithKeyspaceID 设置的路由注释加在语句前面。
+//
+// 包内从不执行 USE 和多语句。
+func SetProxyMode(mode int32) {
+	atomic.StoreInt32(&proxyMode, mode)
+}
+
+func getProxyMode() int32 {
+	return atomic.LoadInt32(&proxyMode)
+}
+
+// 连接字符串
+func dsn(username, password, addr, databasename string) string {
+	return dsnConfig{username: username, password: password, addr: addr, dbname: databasename}.String()
+}
+
+type routeKey struct{}
+
+// WithRoute 在上下文中设置路由注释 /* route=... */，供 ProxySQL 的查询规则匹配
+//
+//	ctx := db.WithRoute(ctx, "primary")
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, "/* route="+escapeTag(route)+" */")
+}
+
+// WithKeyspaceID 在上下文中设置 Vitess 的分片注释，把语句直接发往 keyspace id 所在的分片
+func WithKeyspaceID(ctx context.Context, keyspaceID []byte) context.Context {
+	return context.WithValue(ctx, routeKey{}, "/* vtgate:: keyspace_id:"+hex.EncodeToString(keyspaceID)+" */")
+}
+
+// 代理模式下加上路由注释
+func routeQuery(ctx context.Context, query string) string {
+	if getProxyMode() == ProxyNone {
+		return query
+	}
+	if route, ok := ctx.Value(routeKey{}).(string); ok {
+		return route + " " + query
+	}
+	return query
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryLog 一条语句的执行记录
+type QueryLog struct {
+	//通过 Table 执行时为表名
+	Table string
+	Sql   string
+	//参数，敏感的值已替换为 "***"
+	Args     []interface{}
+	Duration time.Duration
+	Err      error
+}
+
+// 替换敏感参数的值
+const redacted = "***"
+
+var queryLog struct {
+	sync.RWMutex
+	logger   func(QueryLog)
+	redactor func(table, column string, arg interface{}) bool
+}
+
+// SetQueryLogger 设置语句日志，每条经过包内连接池的语句执行后调用 fn，fn 为 nil 时关闭
+func SetQueryLogger(fn func(QueryLog)) {
+	queryLog.Lock()
+	queryLog.logger = fn
+	queryLog.Unlock()
+}
+
+// SetRedactor 设置判断参数是否敏感的回调，返回 true 时日志中的值替换为 "***"
+//
+// column 是参数对应的字段名，无法确定时为空字符串。
+func SetRedactor(fn func(table, column string, arg interface{}) bool) {
+	queryLog.Lock()
+	queryLog.redactor = fn
+	queryLog.Unlock()
+}
+
+// Redact 把字段标记为敏感，日志和错误中不出现这些字段的参数值
+func (t *Table) Redact(columns ...string) error {
+	redact := make(map[string]bool, len(t.redact)+len(columns))
+	for k := range t.redact {
+		redact[k] = true
+	}
+	for _, column := range columns {
+		if _, err := t.indexOf(column); err != nil {
+			return err
+		}
+		redact[column] = true
+	}
+	t.redact = redact
+	return nil
+}
+
+type logTableKey struct{}
+
+// 开启日志时在上下文中记录执行语句的表
+func (t Table) logContext(ctx context.Context) context.Context {
+	queryLog.RLock()
+	on := queryLog.logger != nil
+	queryLog.RUnlock()
+	if !on {
+		return ctx
+	}
+	return context.WithValue(ctx, logTableKey{}, &t)
+}
+
+// 记录执行的语句
+func logQuery(ctx context.Context, query string, args []interface{}, start time.Time, err error) {
+	queryLog.RLock()
+	logger, redactor := queryLog.logger, queryLog.redactor
+	queryLog.RUnlock()
+	if logger == nil {
+		return
+	}
+	entry := QueryLog{Sql: query, Duration: time.Since(start), Err: err}
+	t, _ := ctx.Value(logTableKey{}).(*Table)
+	if t != nil {
+		entry.Table = t.Fullname
+	}
+	entry.Args = redactArgs(t, redactor, query, args)
+	logger(entry)
+}
+
+// 替换敏感的参数
+func redactArgs(t *Table, redactor func(table, column string, arg interface{}) bool, query string, args []interface{}) []interface{} {
+	if len(args) == 0 || ((t == nil || len(t.redact) == 0) && redactor == nil) {
+		return args
+	}
+	columns := placeholderColumns(query, len(args))
+	table := ""
+	if t != nil {
+		table = t.Fullname
+	}
+	safe := make([]interface{}, len(args))
+	for i := range args {
+		switch {
+		case t != nil && t.redact[columns[i]]:
+			safe[i] = redacted
+		case redactor != nil && redactor(table, columns[i], args[i]):
+			safe[i] = redacted
+		default:
+			safe[i] = args[i]
+		}
+	}
+	return safe
+}
+
+var (
+	reInsertColumns = regexp.MustCompile("(?is)^\\s*(?:INSERT|REPLACE)(?:\\s+IGNORE)?\\s+INTO\\s+\\S+\\s*\\(([^)]*)\\)\\s*VALUES")
+	reToken         = regexp.MustCompile("`([^`]+)`|\\?|'(?:[^'\\\\]|\\\\.)*'|\\b(?i:LIMIT|OFFSET)\\b")
+)
+
+// 按语句推断每个占位符对应的字段，推断不出时为空字符串
+//
+// INSERT 的 VALUES 按字段列表依次对应，其他占位符对应之前最近的字段名。
+func placeholderColumns(query string, n int) []string {
+	columns := make([]string, n)
+	i := 0
+	if m := reInsertColumns.FindStringSubmatchIndex(query); m != nil {
+		names := strings.Split(query[m[2]:m[3]], ",")
+		for k := range names {
+			names[k] = strings.Trim(strings.TrimSpace(names[k][strings.LastIndex(names[k], ".")+1:]), "`")
+		}
+		//VALUES 部分，直到 ON DUPLICATE KEY UPDATE
+		rest := query[m[1]:]
+		if j := strings.Index(strings.ToUpper(rest), "ON DUPLICATE"); j >= 0 {
+			rest = rest[:j]
+		}
+		for c := strings.Count(rest, "?"); c > 0 && i < n; c-- {
+			columns[i] = names[i%len(names)]
+			i++
+		}
+		query = query[m[1]+len(rest):]
+	}
+	last := ""
+	for _, tok := range reToken.FindAllStringSubmatch(query, -1) {
+		switch {
+		case tok[0] == "?":
+			if i < n {
+				columns[i] = last
+				i++
+			}
+		case tok[1] != "":
+			last = tok[1]
+		case tok[0][0] != '\'':
+			last = ""
+		}
+	}
+	return columns
+}
@@ -0,0 +1,403 @@
+// Package queue 基于数据表的任务队列
+//
+// 任务表的结构见 TableSql。工作者用 SELECT ... FOR UPDATE SKIP LOCKED 领取任务，
+// 不支持 SKIP LOCKED 的 MySQL 5.7 以下版本可以使用乐观领取。
+// 失败的任务按退避时间重试，超过最大次数后标记为 dead，留在表中供排查。
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgf1988/db"
+)
+
+// 任务状态
+const (
+	StatusReady   = "ready"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusDead    = "dead"
+)
+
+// TableSql 任务表的建表语句
+func TableSql(name string) string {
+	return strings.Join([]string{
+		fmt.Sprintf("CREATE TABLE %s (", name),
+		"\t`id` bigint(20) NOT NULL AUTO_INCREMENT,",
+		"\t`queue` varchar(64) NOT NULL,",
+		"\t`payload` mediumtext NOT NULL,",
+		"\t`priority` int(11) NOT NULL DEFAULT 0,",
+		"\t`run_at` datetime(3) NOT NULL,",
+		"\t`status` varchar(16) NOT NULL,",
+		"\t`attempts` int(11) NOT NULL DEFAULT 0,",
+		"\t`max_attempts` int(11) NOT NULL,",
+		"\t`last_error` text NULL,",
+		"\t`locked_by` varchar(255) NULL,",
+		"\t`locked_at` datetime(3) NULL,",
+		"\t`created` datetime(3) NOT NULL,",
+		"\tPRIMARY KEY (`id`),",
+		"\tKEY `queue_status_run_at` (`queue`, `status`, `run_at`)",
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+	}, "\n")
+}
+
+var (
+	// ErrLostClaim 任务已经被其他工作者重新领取，完成或失败的结果没有记录
+	ErrLostClaim = errors.New("queue: the job was claimed by another worker")
+	// ErrTimedOut 工作者失联的任务已经用完执行次数，不再执行，标记为 dead
+	ErrTimedOut = errors.New("queue: the job timed out with no attempts left")
+)
+
+// 任务表必须有的字段
+var columns = []string{"id", "queue", "payload", "priority", "run_at", "status", "attempts", "max_attempts", "last_error", "locked_by", "locked_at", "created"}
+
+// Options 队列的选项
+type Options struct {
+	//队列名称，同一个表可以存放多个队列，默认 "default"
+	Name string
+	//默认的最大执行次数，默认 3
+	MaxAttempts int
+	//第 attempt 次失败后等待多久重试，默认 2^attempt 秒
+	Backoff func(attempt int) time.Duration
+	//任务执行超过该时间未完成时视为工作者失联，重新领取，默认 5 分钟
+	Visibility time.Duration
+	//没有任务时的轮询间隔，默认 1 秒
+	PollInterval time.Duration
+	//不使用 SKIP LOCKED，改为乐观领取
+	NoSkipLocked bool
+	//任务进入 dead 状态时回调
+	OnDead func(job *Job, err error)
+}
+
+// Job 一个任务
+type Job struct {
+	ID          int64
+	Queue       string
+	Payload     []byte
+	Priority    int
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	//领取任务的工作者
+	worker string
+}
+
+// Decode 把 JSON 格式的任务内容解析到 v
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// EnqueueOptions 入队的选项
+type EnqueueOptions struct {
+	//优先级，越大越先执行
+	Priority int
+	//最早执行时间，默认立即执行
+	RunAt time.Time
+	//最大执行次数，默认使用 Options.MaxAttempts
+	MaxAttempts int
+}
+
+// Stats 队列的计数
+type Stats struct {
+	Enqueued  int64
+	Claimed   int64
+	Completed int64
+	Failed    int64
+	Dead      int64
+}
+
+// Queue 任务队列
+type Queue struct {
+	t     *db.Table
+	opt   Options
+	stats Stats
+}
+
+// New 使用 table 表作为队列，表结构见 TableSql
+func New(table string, opt Options) (*Queue, error) {
+	t, err := db.GetTable(table)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		found := false
+		for i := range t.Fields {
+			if t.Fields[i].Name == column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("queue: the table (%s) has no column (%s)", table, column)
+		}
+	}
+	if opt.Name == "" {
+		opt.Name = "default"
+	}
+	if opt.MaxAttempts <= 0 {
+		opt.MaxAttempts = 3
+	}
+	if opt.Backoff == nil {
+		opt.Backoff = func(attempt int) time.Duration {
+			return time.Duration(1<<uint(attempt)) * time.Second
+		}
+	}
+	if opt.Visibility <= 0 {
+		opt.Visibility = 5 * time.Minute
+	}
+	if opt.PollInterval <= 0 {
+		opt.PollInterval = time.Second
+	}
+	return &Queue{t: t, opt: opt}, nil
+}
+
+// Enqueue 把 payload 编码为 JSON 入队，返回任务 ID
+func (q *Queue) Enqueue(ctx context.Context, payload interface{}, o EnqueueOptions) (int64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	if o.RunAt.IsZero() {
+		o.RunAt = now
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = q.opt.MaxAttempts
+	}
+	res, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (`queue`, `payload`, `priority`, `run_at`, `status`, `attempts`, `max_attempts`, `created`) VALUES (?, ?, ?, ?, ?, 0, ?, ?)", q.t.Fullname),
+		q.opt.Name, string(data), o.Priority, o.RunAt, StatusReady, o.MaxAttempts, now)
+	if err != nil {
+		return 0, err
+	}
+	atomic.AddInt64(&q.stats.Enqueued, 1)
+	return res.LastInsertId()
+}
+
+// 可以领取的任务：到期的 ready 任务和工作者失联的 running 任务
+func (q *Queue) claimable() (string, []interface{}) {
+	now := time.Now()
+	return "`queue` = ? AND ((`status` = ? AND `run_at` <= ?) OR (`status` = ? AND `locked_at` < ?))",
+		[]interface{}{q.opt.Name, StatusReady, now, StatusRunning, now.Add(-q.opt.Visibility)}
+}
+
+// Claim 领取一个任务，没有可领取的任务时返回 nil
+//
+// 工作者失联的任务已经用完执行次数时不再执行，标记为 dead 后领取下一个。
+func (q *Queue) Claim(ctx context.Context, worker string) (*Job, error) {
+	for {
+		var job *Job
+		var err error
+		if q.opt.NoSkipLocked {
+			job, err = q.claimOptimistic(ctx, worker)
+		} else {
+			job, err = q.claimSkipLocked(ctx, worker)
+		}
+		if job == nil || err != nil {
+			return nil, err
+		}
+		job.worker = worker
+		if job.Attempts <= job.MaxAttempts {
+			atomic.AddInt64(&q.stats.Claimed, 1)
+			return job, nil
+		}
+		if err = q.bury(ctx, job, ErrTimedOut); err != nil && err != ErrLostClaim {
+			return nil, err
+		}
+	}
+}
+
+func (q *Queue) selectSql(suffix string) (string, []interface{}) {
+	where, args := q.claimable()
+	return fmt.Sprintf("SELECT `id`, `payload`, `priority`, `attempts`, `max_attempts`, `run_at` FROM %s WHERE %s ORDER BY `priority` DESC, `run_at` LIMIT 1 %s",
+		q.t.Fullname, where, suffix), args
+}
+
+func (q *Queue) scanJob(row *sql.Row) (*Job, error) {
+	job := &Job{Queue: q.opt.Name}
+	err := row.Scan(&job.ID, &job.Payload, &job.Priority, &job.Attempts, &job.MaxAttempts, &job.RunAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (q *Queue) claimSkipLocked(ctx context.Context, worker string) (*Job, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	query, args := q.selectSql("FOR UPDATE SKIP LOCKED")
+	job, err := q.scanJob(tx.QueryRowContext(ctx, query, args...))
+	if job == nil || err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET `status` = ?, `attempts` = `attempts` + 1, `locked_by` = ?, `locked_at` = ? WHERE `id` = ?", q.t.Fullname),
+		StatusRunning, worker, time.Now(), job.ID); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	job.Attempts++
+	return job, nil
+}
+
+// 先读取再按原状态更新，被其他工作者抢先时重试
+func (q *Queue) claimOptimistic(ctx context.Context, worker string) (*Job, error) {
+	for i := 0; i < 3; i++ {
+		query, args := q.selectSql("")
+		job, err := q.scanJob(db.QueryRowContext(ctx, query, args...))
+		if job == nil || err != nil {
+			return nil, err
+		}
+		where, whereArgs := q.claimable()
+		res, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET `status` = ?, `attempts` = `attempts` + 1, `locked_by` = ?, `locked_at` = ? WHERE `id` = ? AND `attempts` = ? AND %s", q.t.Fullname, where),
+			append([]interface{}{StatusRunning, worker, time.Now(), job.ID, job.Attempts}, whereArgs...)...)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			job.Attempts++
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// 只修改仍由 job 的领取者持有的任务，被其他工作者重新领取时返回 ErrLostClaim
+func (q *Queue) update(ctx context.Context, job *Job, set string, args ...interface{}) error {
+	res, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s, `locked_by` = NULL, `locked_at` = NULL WHERE `id` = ? AND `locked_by` = ? AND `attempts` = ?", q.t.Fullname, set),
+		append(args, job.ID, job.worker, job.Attempts)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLostClaim
+	}
+	return nil
+}
+
+// Complete 标记任务完成，任务已经被其他工作者重新领取时返回 ErrLostClaim
+func (q *Queue) Complete(ctx context.Context, job *Job) error {
+	err := q.update(ctx, job, "`status` = ?", StatusDone)
+	if err == nil {
+		atomic.AddInt64(&q.stats.Completed, 1)
+	}
+	return err
+}
+
+// Fail 记录失败，未超过最大次数时按退避时间重新排队，否则标记为 dead
+//
+// 任务已经被其他工作者重新领取时返回 ErrLostClaim。
+func (q *Queue) Fail(ctx context.Context, job *Job, cause error) error {
+	if job.Attempts >= job.MaxAttempts {
+		return q.bury(ctx, job, cause)
+	}
+	err := q.update(ctx, job, "`status` = ?, `run_at` = ?, `last_error` = ?", StatusReady, time.Now().Add(q.opt.Backoff(job.Attempts)), cause.Error())
+	if err == nil {
+		atomic.AddInt64(&q.stats.Failed, 1)
+	}
+	return err
+}
+
+// 把任务标记为 dead
+func (q *Queue) bury(ctx context.Context, job *Job, cause error) error {
+	if err := q.update(ctx, job, "`status` = ?, `last_error` = ?", StatusDead, cause.Error()); err != nil {
+		return err
+	}
+	atomic.AddInt64(&q.stats.Failed, 1)
+	atomic.AddInt64(&q.stats.Dead, 1)
+	if q.opt.OnDead != nil {
+		q.opt.OnDead(job, cause)
+	}
+	return nil
+}
+
+// Retry 把 dead 任务重新放回队列，执行次数清零
+func (q *Queue) Retry(ctx context.Context, id int64) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET `status` = ?, `attempts` = 0, `run_at` = ? WHERE `id` = ? AND `status` = ?", q.t.Fullname),
+		StatusReady, time.Now(), id, StatusDead)
+	return err
+}
+
+// Work 启动 concurrency 个工作者执行任务，直到 ctx 结束
+//
+// handler 返回错误时任务按 Fail 处理，否则标记完成。db.Close 时等待正在执行的任务完成后返回。
+func (q *Queue) Work(ctx context.Context, worker string, concurrency int, handler func(ctx context.Context, job *Job) error) error {
+	var stopper db.Stopper
+	ctx = stopper.Start(ctx)
+	defer stopper.Done()
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				job, err := q.Claim(ctx, id)
+				if err != nil || job == nil {
+					select {
+					case <-ctx.Done():
+					case <-time.After(q.opt.PollInterval):
+					}
+					continue
+				}
+				if err = handler(ctx, job); err != nil {
+					q.Fail(context.Background(), job, err)
+				} else {
+					q.Complete(context.Background(), job)
+				}
+			}
+		}(fmt.Sprintf("%s-%d", worker, i))
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Stats 本进程中的队列计数
+func (q *Queue) Stats() Stats {
+	return Stats{
+		Enqueued:  atomic.LoadInt64(&q.stats.Enqueued),
+		Claimed:   atomic.LoadInt64(&q.stats.Claimed),
+		Completed: atomic.LoadInt64(&q.stats.Completed),
+		Failed:    atomic.LoadInt64(&q.stats.Failed),
+		Dead:      atomic.LoadInt64(&q.stats.Dead),
+	}
+}
+
+// Depth 按状态统计队列中的任务数
+func (q *Queue) Depth(ctx context.Context) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT `status`, COUNT(*) FROM %s WHERE `queue` = ? GROUP BY `status`", q.t.Fullname), q.opt.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	depth := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var n int64
+		if err = rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		depth[status] = n
+	}
+	return depth, rows.Err()
+}
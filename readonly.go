@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 被拒绝的语句返回的错误
+var (
+	ErrReadOnly   = errors.New("db: write statement rejected in read-only mode")
+	ErrNotAllowed = errors.New("db: statement not in the allowlist")
+)
+
+var statementPolicy struct {
+	sync.RWMutex
+	readOnly  bool
+	allowlist []*regexp.Regexp
+}
+
+// SetReadOnly 开启只读模式，之后经过包内连接池的写语句都被拒绝，返回 ErrReadOnly
+//
+// 只允许 SELECT、SHOW、EXPLAIN、DESCRIBE 开头的语句，以及公用表表达式之后是 SELECT 的 WITH 语句。
+// 只读模式下 BeginTx 只能开始只读事务（sql.TxOptions.ReadOnly），其他事务返回 ErrReadOnly；
+// 审计、Pipeline、归档等包内使用事务的功能同样检查事务中的每条语句。
+func SetReadOnly(on bool) {
+	statementPolicy.Lock()
+	statementPolicy.readOnly = on
+	statementPolicy.Unlock()
+}
+
+// SetAllowlist 只允许匹配其中任一模式的语句，其他语句返回 ErrNotAllowed，不传参数时取消
+//
+// 匹配的是去掉注释后的语句。
+func SetAllowlist(patterns ...*regexp.Regexp) {
+	statementPolicy.Lock()
+	statementPolicy.allowlist = patterns
+	statementPolicy.Unlock()
+}
+
+// 语句的第一个关键字
+func firstKeyword(query string) string {
+	query = strings.TrimLeft(reComment.ReplaceAllString(query, ""), " \t\r\n(")
+	if i := strings.IndexAny(query, " \t\r\n("); i > 0 {
+		query = query[:i]
+	}
+	return strings.ToUpper(query)
+}
+
+// 是否开启了只读模式
+func isReadOnly() bool {
+	statementPolicy.RLock()
+	defer statementPolicy.RUnlock()
+	return statementPolicy.readOnly
+}
+
+// WITH 语句中公用表表达式之后的部分，无法解析时返回空字符串
+//
+//	WITH [RECURSIVE] name [(columns)] AS (subquery) [, name [(columns)] AS (subquery)] ... body
+func withBody(query string) string {
+	s := strings.TrimSpace(reComment.ReplaceAllString(query, ""))
+	s = strings.TrimSpace(s[len("WITH"):])
+	if len(s) > len("RECURSIVE") && strings.EqualFold(s[:len("RECURSIVE")], "RECURSIVE") {
+		s = strings.TrimSpace(s[len("RECURSIVE"):])
+	}
+	for {
+		//名称
+		if strings.HasPrefix(s, "`") {
+			end := strings.Index(s[1:], "`")
+			if end < 0 {
+				return ""
+			}
+			s = s[end+2:]
+		} else {
+			end := strings.IndexAny(s, " \t\r\n(")
+			if end <= 0 {
+				return ""
+			}
+			s = s[end:]
+		}
+		s = strings.TrimSpace(s)
+		//字段列表
+		if strings.HasPrefix(s, "(") {
+			end := skipGroup(s)
+			if end < 0 {
+				return ""
+			}
+			s = strings.TrimSpace(s[end:])
+		}
+		if len(s) < 2 || !strings.EqualFold(s[:2], "AS") {
+			return ""
+		}
+		s = strings.TrimSpace(s[2:])
+		if !strings.HasPrefix(s, "(") {
+			return ""
+		}
+		end := skipGroup(s)
+		if end < 0 {
+			return ""
+		}
+		s = strings.TrimSpace(s[end:])
+		if !strings.HasPrefix(s, ",") {
+			return s
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+}
+
+// s 以 ( 开始，返回匹配的 ) 之后的位置，跳过字符串和标识符，不匹配时返回 -1
+func skipGroup(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'', '"', '`':
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// 按只读模式和白名单检查语句
+func checkStatement(query string) error {
+	statementPolicy.RLock()
+	defer statementPolicy.RUnlock()
+	if statementPolicy.readOnly {
+		switch firstKeyword(query) {
+		case "SELECT", "SHOW", "EXPLAIN", "DESCRIBE", "DESC":
+		case "WITH":
+			//MySQL 8 允许 WITH ... UPDATE 和 WITH ... DELETE
+			if firstKeyword(withBody(query)) != "SELECT" {
+				return ErrReadOnly
+			}
+		default:
+			return ErrReadOnly
+		}
+	}
+	if len(statementPolicy.allowlist) > 0 {
+		stripped := strings.TrimSpace(reComment.ReplaceAllString(query, ""))
+		for _, re := range statementPolicy.allowlist {
+			if re.MatchString(stripped) {
+				return nil
+			}
+		}
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+// 已经结束的上下文，使被拒绝的语句不发往服务器并返回 err，*sql.Row 也能带上该错误
+type rejectedCtx struct {
+	context.Context
+	err error
+}
+
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+func (c rejectedCtx) Done() <-chan struct{}       { return closedChan }
+func (c rejectedCtx) Err() error                  { return c.err }
+func (c rejectedCtx) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+// 检查语句，被拒绝时返回已经结束的上下文
+func policyContext(ctx context.Context, query string) context.Context {
+	if err := checkStatement(query); err != nil {
+		return rejectedCtx{Context: ctx, err: err}
+	}
+	return ctx
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestReadOnlyWith(t *testing.T) {
+	SetReadOnly(true)
+	defer SetReadOnly(false)
+	cases := []struct {
+		sql string
+		err error
+	}{
+		{"WITH a AS (SELECT 1) SELECT * FROM a", nil},
+		{"WITH RECURSIVE a (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM a WHERE n < 3) SELECT n FROM a", nil},
+		{"WITH `a` AS (SELECT ')'), b AS (SELECT 2) (SELECT * FROM a)", nil},
+		{"/* c */ WITH a AS (SELECT id FROM t) UPDATE t, a SET t.x = 1 WHERE t.id = a.id", ErrReadOnly},
+		{"WITH a AS (SELECT id FROM t) DELETE t FROM t JOIN a ON t.id = a.id", ErrReadOnly},
+		{"WITH a AS (SELECT 1", ErrReadOnly},
+	}
+	for _, c := range cases {
+		if err := checkStatement(c.sql); err != c.err {
+			t.Errorf("checkStatement(%q) = %v, want %v", c.sql, err, c.err)
+		}
+	}
+}
+
+func TestReadOnlyBeginTx(t *testing.T) {
+	openFake(t, 0)
+	SetReadOnly(true)
+	defer SetReadOnly(false)
+	if _, err := BeginTx(context.Background(), nil); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("BeginTx in read-only mode = %v, want ErrReadOnly", err)
+	}
+	tx, err := BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+}
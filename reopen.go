@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotOpen 还没有调用 Open
+var ErrNotOpen = errors.New("db: the connection is not open, call Open first")
+
+// Open 之前使用的连接池，建立连接时返回 ErrNotOpen
+var notOpen = sql.OpenDB(notOpenConnector{})
+
+type notOpenConnector struct{}
+
+func (notOpenConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, ErrNotOpen
+}
+
+func (notOpenConnector) Driver() driver.Driver {
+	return notOpenDriver{}
+}
+
+type notOpenDriver struct{}
+
+func (notOpenDriver) Open(string) (driver.Conn, error) {
+	return nil, ErrNotOpen
+}
+
+// 上次 Open 的参数，用于更换密码后重新连接
+var openParams struct {
+	sync.Mutex
+	username, addr, dbname string
+}
+
+func setOpenParams(username, addr, dbname string) {
+	openParams.Lock()
+	openParams.username, openParams.addr, openParams.dbname = username, addr, dbname
+	openParams.Unlock()
+}
+
+// Reopen 用新的用户名和密码连接上次 Open 的主机和数据库，成功后替换连接池
+//
+// username 为空时沿用上次的用户名。旧连接池在后台关闭，不影响正在执行的语句。
+func Reopen(username, password string) error {
+	openParams.Lock()
+	addr, dbname := openParams.addr, openParams.dbname
+	if username == "" {
+		username = openParams.username
+	}
+	openParams.Unlock()
+	if addr == "" {
+		return ErrNotOpen
+	}
+	sqldb, err := openDB(dsn(username, password, addr, dbname))
+	if err != nil {
+		return err
+	}
+	if err = sqldb.Ping(); err != nil {
+		sqldb.Close()
+		return err
+	}
+	setConn(sqldb, dbname)
+	setOpenParams(username, addr, dbname)
+	return nil
+}
+
+// Credentials 返回当前的用户名和密码
+type Credentials func(ctx context.Context) (username, password string, err error)
+
+// RotateCredentials 每隔 interval 取得一次用户名和密码并重新连接，直到 ctx 结束或 Close
+//
+// 用于定期更换的密码，例如从密钥管理服务读取。取得密码或连接失败时保留原来的连接池，
+// 错误交给 onError，onError 可以为 nil。
+func RotateCredentials(ctx context.Context, interval time.Duration, provider Credentials, onError func(error)) {
+	var stopper Stopper
+	ctx = stopper.Start(ctx)
+	go func() {
+		defer stopper.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			username, password, err := provider(ctx)
+			if err == nil {
+				err = Reopen(username, password)
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 只读从库，为 nil 时读写都使用主库
+var replica atomic.Value
+
+type replicaHolder struct {
+	db *sql.DB
+}
+
+// SetReplica 设置只读从库，Table 的查询改为在从库上执行，写入仍在主库上执行
+//
+// 用 Table.On 指定了连接池的表不受影响。replica 为 nil 时取消。
+func SetReplica(sqldb *sql.DB) {
+	replica.Store(replicaHolder{db: sqldb})
+}
+
+func getReplica() *sql.DB {
+	h, _ := replica.Load().(replicaHolder)
+	return h.db
+}
+
+// Primary 返回总是在主库上执行查询的表
+func (t Table) Primary() *Table {
+	t.db = t.sqlDB()
+	return &t
+}
+
+// 会话中最近一次写入后主库的 GTID 集合
+type session struct {
+	mu   sync.Mutex
+	gtid string
+}
+
+type sessionKey struct{}
+
+// ReadYourWrites 返回保证读到自己写入的上下文，与 WithContext 一起使用
+//
+// 通过该上下文写入后记录主库的 GTID 集合，之后的查询在从库追上之前改为在主库上执行。
+// 要求主从开启 GTID。
+func ReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// LastGTID 上下文中最近一次写入后主库的 GTID 集合
+func LastGTID(ctx context.Context) string {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gtid
+}
+
+// 写入后记录主库的 GTID 集合
+func (t Table) trackWrite(sqldb *sql.DB) {
+	s, ok := t.context().Value(sessionKey{}).(*session)
+	if !ok || getReplica() == nil {
+		return
+	}
+	var gtid string
+	if sqldb.QueryRowContext(t.context(), "SELECT @@GLOBAL.gtid_executed").Scan(&gtid) != nil {
+		//无法确认时之后的查询都在主库上执行
+		gtid = "*"
+	}
+	s.mu.Lock()
+	s.gtid = gtid
+	s.mu.Unlock()
+}
+
+// 查询使用的连接池
+func (t Table) readDB() *sql.DB {
+	if t.db != nil {
+		return t.db
+	}
+	if hasConn(t.ctx) {
+		return connFrom(t.ctx)
+	}
+	r := getReplica()
+	if r == nil {
+		return conn()
+	}
+	gtid := LastGTID(t.context())
+	if gtid == "" {
+		return r
+	}
+	if gtid != "*" {
+		var caughtUp bool
+		if r.QueryRowContext(t.context(), "SELECT GTID_SUBSET(?, @@GLOBAL.gtid_executed)", gtid).Scan(&caughtUp) == nil && caughtUp {
+			return r
+		}
+	}
+	return conn()
+}
+
+// WaitForReplica 等待从库执行完 gtid，例如 LastGTID 返回的值
+func WaitForReplica(ctx context.Context, gtid string) error {
+	r := getReplica()
+	if r == nil {
+		return fmt.Errorf("db: no replica configured")
+	}
+	timeout := 0.0
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline).Seconds()
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+	var timedOut int
+	if err := r.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", gtid, timeout).Scan(&timedOut); err != nil {
+		return err
+	}
+	if timedOut != 0 {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 结果集缓存的后端，通过 getResultCache 读取
+var resultCache atomic.Value
+
+func init() {
+	resultCache.Store(cacheHolder{NewLRUCache(1024)})
+}
+
+// atomic.Value 要求每次存入相同的具体类型
+type cacheHolder struct {
+	Cache
+}
+
+func getResultCache() Cache {
+	return resultCache.Load().(cacheHolder).Cache
+}
+
+// 命中和未命中的次数
+var resultHits, resultMisses uint64
+
+// SetResultCache 设置结果集缓存的后端，默认为 1024 项的 LRUCache
+func SetResultCache(c Cache) {
+	resultCache.Store(cacheHolder{c})
+}
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRatio 命中率
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// ResultCacheStats 结果集缓存的统计
+func ResultCacheStats() CacheStats {
+	return CacheStats{Hits: atomic.LoadUint64(&resultHits), Misses: atomic.LoadUint64(&resultMisses)}
+}
+
+// Cached 返回缓存查询结果的表
+//
+// 返回的表上 GetMany、FindMany、Where、Filter、List、Query 等返回 Rows 的查询，
+// 相同的 SQL 和参数在 ttl 内直接返回缓存的结果集。缓存不会因写入自动失效，
+// 需要时调用 BustResultCache。
+func (t Table) Cached(ttl time.Duration) *Table {
+	t.resultTTL = ttl
+	return &t
+}
+
+// BustResultCache 使表的所有缓存结果集失效
+//
+// 每个表有一个版本号，缓存键包含版本号，失效时只需要递增版本号。
+func BustResultCache(t *Table) {
+	getResultCache().Set(resultVersionKey(t), []byte(strconv.FormatInt(time.Now().UnixNano(), 10)), 0)
+}
+
+func resultVersionKey(t *Table) string {
+	return "version:" + t.Fullname
+}
+
+func resultKey(t *Table, query string, args []interface{}) string {
+	version, _ := getResultCache().Get(resultVersionKey(t))
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%#v", query, args)))
+	return fmt.Sprintf("result:%s:%s:%s", t.Fullname, version, hex.EncodeToString(sum[:]))
+}
+
+// 从缓存读取结果集，未命中时查询并缓存
+func (t *Table) cachedRows(query string, args []interface{}) (*Rows, error) {
+	key := resultKey(t, query, args)
+	if buf, ok := getResultCache().Get(key); ok {
+		var data [][]interface{}
+		if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&data); err == nil {
+			atomic.AddUint64(&resultHits, 1)
+			return &Rows{t: t, scans: t.getScans(), src: newCachedRows(t, data)}, nil
+		}
+	}
+	atomic.AddUint64(&resultMisses, 1)
+	data, err := t.loadRows(query, args)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if gob.NewEncoder(&buf).Encode(data) == nil {
+		getResultCache().Set(key, buf.Bytes(), t.resultTTL)
+	}
+	return &Rows{t: t, scans: t.getScans(), src: newCachedRows(t, data)}, nil
+}
+
+// 读取整个结果集的原始值
+func (t *Table) loadRows(query string, args []interface{}) ([][]interface{}, error) {
+	rows, err := t.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return t.readAll(rows)
+}
+
+// 读出结果集的所有行
+func (t *Table) readAll(rows rowsSource) ([][]interface{}, error) {
+	scans := t.getScans()
+	defer t.putScans(scans)
+	data := make([][]interface{}, 0)
+	for rows.Next() {
+		if err := rows.Scan(scans...); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(scans))
+		for i := range scans {
+			values[i] = parseValue(scans[i])
+		}
+		data = append(data, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// 内存中的结果集
+type cachedRows struct {
+	data    [][]interface{}
+	i       int
+	columns []string
+}
+
+// 表字段的结果集
+func newCachedRows(t *Table, data [][]interface{}) *cachedRows {
+	columns := make([]string, len(t.Fields))
+	for i := range t.Fields {
+		columns[i] = t.Fields[i].Name
+	}
+	return &cachedRows{data: data, i: -1, columns: columns}
+}
+
+func (c *cachedRows) Next() bool {
+	if c.i+1 >= len(c.data) {
+		c.i = len(c.data)
+		return false
+	}
+	c.i++
+	return true
+}
+
+func (c *cachedRows) Scan(dest ...interface{}) error {
+	if c.i < 0 || c.i >= len(c.data) {
+		return fmt.Errorf("db: Scan called without calling Next")
+	}
+	return valuesScanner(c.data[c.i]).Scan(dest...)
+}
+
+func (c *cachedRows) Err() error {
+	return nil
+}
+
+func (c *cachedRows) Close() error {
+	c.i = len(c.data)
+	return nil
+}
+
+func (c *cachedRows) Columns() ([]string, error) {
+	return append([]string(nil), c.columns...), nil
+}
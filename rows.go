@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"runtime"
+)
+
+// Rows 的数据来源，*sql.Rows 和缓存的结果集都满足
+type rowsSource interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+	Columns() ([]string, error)
+}
+
+// 执行查询并返回 Rows，按 Guardrails 限制结果
+func (t *Table) rows(query string, args ...interface{}) (*Rows, error) {
+	rs, err := t.openRows(limitQuery(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return rs.guard().watch(), nil
+}
+
+func (t *Table) openRows(query string, args ...interface{}) (*Rows, error) {
+	if t.resultTTL > 0 {
+		return t.cachedRows(query, args)
+	}
+	if t.flight&FlightRows != 0 {
+		return t.flightRows(query, args)
+	}
+	rows, cancel, err := t.queryRows(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{
+		Rows: rows, t: t, scans: t.getScans(), cancel: cancel,
+	}, nil
+}
+
+// 执行查询并返回 Row
+func (t *Table) row(query string, args ...interface{}) *Row {
+	if t.flight&FlightRow != 0 {
+		return t.flightRow(query, args)
+	}
+	return &Row{
+		Row: t.queryRow(query, args...), t: t, query: query,
+	}
+}
+
+func (rs *Rows) source() rowsSource {
+	if rs.page != nil {
+		return rs.page
+	}
+	if rs.src != nil {
+		return rs.src
+	}
+	return rs.Rows
+}
+
+// Next 准备读取下一行，没有下一行时自动关闭结果集
+//
+// 表的上下文（见 WithContext）取消后不再读取，关闭结果集并返回 false，Err 返回 ctx.Err()。
+// 结果来自缓存时同样如此。
+func (rs *Rows) Next() bool {
+	if err := rs.t.context().Err(); err != nil {
+		rs.cancelled = err
+		rs.Close()
+		return false
+	}
+	if rs.source().Next() {
+		return true
+	}
+	if rs.Rows != nil {
+		forgetKill(rs.Rows)
+	}
+	rs.Close()
+	return false
+}
+
+// Err 读取过程中的错误，上下文取消时为 ctx.Err()
+func (rs *Rows) Err() error {
+	if rs.cancelled != nil {
+		return rs.cancelled
+	}
+	return rs.source().Err()
+}
+
+// Columns 结果集的列名，结果来自缓存时为表的字段名
+func (rs *Rows) Columns() ([]string, error) {
+	return rs.source().Columns()
+}
+
+// ColumnTypes 结果集的列类型，结果来自缓存时返回错误
+func (rs *Rows) ColumnTypes() ([]*sql.ColumnType, error) {
+	if rs.Rows == nil {
+		return nil, errors.New("db: the column types of cached rows are not available")
+	}
+	return rs.Rows.ColumnTypes()
+}
+
+// NextResultSet 准备读取下一个结果集，结果来自缓存时返回 false
+func (rs *Rows) NextResultSet() bool {
+	if rs.Rows == nil {
+		return false
+	}
+	return rs.Rows.NextResultSet()
+}
+
+// Close 关闭结果集，可以重复调用
+//
+// 关闭后读取缓冲归还给表复用，不能再读取数据。
+func (rs *Rows) Close() error {
+	if rs.Rows != nil {
+		settleKill(rs.Rows)
+	}
+	err := rs.source().Close()
+	if rs.cancel != nil {
+		rs.cancel()
+		rs.cancel = nil
+	}
+	if rs.scans != nil && len(rs.scans) == rs.t.Len {
+		rs.t.putScans(rs.scans)
+	}
+	rs.scans = nil
+	if rs.leak {
+		runtime.SetFinalizer(rs, nil)
+		rs.leak = false
+	}
+	return err
+}
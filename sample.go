@@ -0,0 +1,42 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Sample 随机抽取最多 n 行
+//
+// 在主键的取值范围内随机取点，每个点取主键不小于它的第一行，
+// 不需要 ORDER BY RAND() 扫描全表，但要求主键是整数。
+// 主键不连续时抽样不完全均匀，重复命中的行只返回一次。
+func (t *Table) Sample(n int) (*Rows, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("db: the sample size (%d) must be positive", n)
+	}
+	pk, err := t.indexOf(t.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	if v := t.Fields[pk].Type.Value; v != TypeInt && v != TypeBigint {
+		return nil, fmt.Errorf("db: the table (%s) primary key is not an integer", t.TbName)
+	}
+	column := t.Fields[pk].FullName
+	var min, max sql.NullInt64
+	if err = t.queryRow(fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", column, column, t.Fullname)).Scan(&min, &max); err != nil {
+		return nil, err
+	}
+	if !min.Valid {
+		//空表
+		return t.Query("WHERE 1=0")
+	}
+	parts := make([]string, n)
+	args := make([]interface{}, n)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("(%s WHERE %s >= ? ORDER BY %s limit 1)", t.sqlSelect, column, column)
+		args[i] = min.Int64 + rand.Int63n(max.Int64-min.Int64+1)
+	}
+	return t.rows(strings.Join(parts, " UNION "), args...)
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 读取一个整数，Count 和 Exists 使用
+//
+// 与其他查询一样经过 queryRow，超时、预算、限流、熔断、日志和统计都对其生效。
+func (t Table) scalarInt64(query string, args ...interface{}) (int64, error) {
+	var num int64
+	if err := t.queryRow(query, args...).Scan(&num); err != nil {
+		return -1, t.wrapErr(query, err)
+	}
+	return num, nil
+}
+
+// Exists 是否存在满足条件的行，参数与 Get 相同
+func (t Table) Exists(args ...interface{}) (bool, error) {
+	keys := make([]string, 0)
+	param := make([]interface{}, 0)
+	for i := range args {
+		if args[i] == nil {
+			continue
+		}
+		keys = append(keys, t.Fields[i].FullName+"=?")
+		param = append(param, args[i])
+	}
+	strSql := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", t.Fullname)
+	if len(keys) > 0 {
+		strSql = fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s)", t.Fullname, strings.Join(keys, " AND "))
+	}
+	num, err := t.scalarInt64(strSql, param...)
+	if err != nil {
+		return false, err
+	}
+	return num == 1, nil
+}
@@ -0,0 +1,25 @@
+package db
+
+import "testing"
+
+func BenchmarkCount(b *testing.B) {
+	t := openFake(b, 42)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if n, err := t.Count(); err != nil || n != 42 {
+			b.Fatal(n, err)
+		}
+	}
+}
+
+func BenchmarkExists(b *testing.B) {
+	t := openFake(b, 42)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if ok, err := t.Exists(nil, "user1"); err != nil || !ok {
+			b.Fatal(ok, err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package db
+
+// Database 指定的数据库，不改变 Use 设置的当前数据库
+type Database struct {
+	Name string
+}
+
+// Schema 引用一个数据库，可以在同一个连接池上同时使用多个数据库中的表
+//
+//	t, err := db.Schema("otherdb").GetTable("users")
+func Schema(name string) Database {
+	return Database{Name: name}
+}
+
+// GetTable 读取该数据库中的表
+func (d Database) GetTable(tablename string) (*Table, error) {
+	return getTable(d.Name, tablePrefix()+tablename)
+}
+
+// ShowTables 列出该数据库中的表
+func (d Database) ShowTables() ([]string, error) {
+	return showTables(d.Name)
+}
@@ -0,0 +1,42 @@
+package db
+
+import "fmt"
+
+// DefineScope 定义命名的查询条件，conds 用 AND 连接，同名时覆盖
+//
+//	users.DefineScope("active", db.Eq("status", 1), db.IsNull("deleted_at"))
+//	rows, err := users.Scope("active").List(20, 0)
+func (t *Table) DefineScope(name string, conds ...Condition) {
+	if t.scopes == nil {
+		t.scopes = make(map[string]Condition)
+	}
+	t.scopes[name] = And(append([]Condition(nil), conds...)...)
+}
+
+// Scope 以命名的查询条件开始构建查询，多个名称的条件用 AND 连接
+func (t *Table) Scope(names ...string) *Selector {
+	return t.Select().Scope(names...)
+}
+
+// Scope 追加命名的查询条件，名称未定义时执行返回错误
+func (s *Selector) Scope(names ...string) *Selector {
+	for _, name := range names {
+		cond, ok := s.t.scopes[name]
+		if !ok {
+			if s.err == nil {
+				s.err = fmt.Errorf("db: the scope (%s) is not defined in table (%s)", name, s.t.TbName)
+			}
+			continue
+		}
+		s.conds = append(s.conds, cond)
+	}
+	return s
+}
+
+// List 分页查询，没有指定排序时按主键升序
+func (s *Selector) List(take, skip int) (*Rows, error) {
+	if len(s.order) == 0 && s.t.PrimaryKey != "" {
+		s.OrderBy(s.t.PrimaryKey)
+	}
+	return s.Limit(take, skip).GetMany()
+}
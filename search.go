@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// 全文搜索模式，可按位组合
+const (
+	//自然语言模式
+	NaturalLanguage int = 1 << iota
+	//布尔模式
+	BooleanMode
+	//查询扩展
+	QueryExpansion
+	//在结果末尾附加相关度列
+	WithRelevance
+)
+
+// 读取全文索引的字段
+func getFullText(dbname, tbname string) ([]string, error) {
+	rows, err := Query(`
+	SELECT
+		INDEX_NAME, COLUMN_NAME
+	FROM
+		information_schema.STATISTICS
+	WHERE
+		TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_TYPE = 'FULLTEXT'
+	ORDER BY
+		INDEX_NAME, SEQ_IN_INDEX
+	`, dbname, tbname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var first string
+	columns := make([]string, 0)
+	for rows.Next() {
+		var index, column string
+		if err = rows.Scan(&index, &column); err != nil {
+			return nil, err
+		}
+		if first == "" {
+			first = index
+		}
+		//只取第一个全文索引
+		if index != first {
+			break
+		}
+		columns = append(columns, column)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// 生成 MATCH ... AGAINST 表达式
+func (t Table) sqlMatch(mode int) string {
+	cols := make([]string, len(t.FullText))
+	for i := range t.FullText {
+		cols[i] = fmt.Sprintf("%s.`%s`", t.TbName, t.FullText[i])
+	}
+	var modifier string
+	switch {
+	case mode&BooleanMode != 0:
+		modifier = "IN BOOLEAN MODE"
+	case mode&QueryExpansion != 0:
+		modifier = "WITH QUERY EXPANSION"
+	default:
+		modifier = "IN NATURAL LANGUAGE MODE"
+	}
+	return fmt.Sprintf("MATCH (%s) AGAINST (? %s)", strings.Join(cols, ","), modifier)
+}
+
+// Search 全文搜索，按相关度从高到低排序
+//
+// mode 为 NaturalLanguage、BooleanMode、QueryExpansion 之一，
+// 可以再组合 WithRelevance，在每行末尾多返回一列相关度，通过 Rows.Relevance 读取。
+func (t *Table) Search(query string, mode int) (*Rows, error) {
+	if len(t.FullText) == 0 {
+		return nil, fmt.Errorf("db: the table (%s) has no fulltext index", t.TbName)
+	}
+	match := t.sqlMatch(mode)
+	var strSql string
+	if mode&WithRelevance != 0 {
+		strSql = fmt.Sprintf("%s WHERE %s ORDER BY relevance DESC",
+			strings.Replace(t.sqlSelect, " FROM ", fmt.Sprintf(", %s AS relevance FROM ", match), 1), match)
+	} else {
+		strSql = fmt.Sprintf("%s WHERE %s ORDER BY %s DESC", t.sqlSelect, match, match)
+	}
+	if mode&WithRelevance == 0 {
+		return t.rows(strSql, query, query)
+	}
+	//多一列相关度，不能使用结果集缓存和读取缓冲池
+	rows, cancel, err := t.queryRows(limitQuery(strSql), query, query)
+	if err != nil {
+		return nil, err
+	}
+	scans := append(t.makeNullableScans(), new(sql.NullFloat64))
+	return (&Rows{Rows: rows, t: t, scans: scans, cancel: cancel}).guard().watch(), nil
+}
+
+// Relevance 当前行的相关度，只有 Search 使用 WithRelevance 时有效
+func (rs *Rows) Relevance() float64 {
+	if len(rs.scans) <= rs.t.Len {
+		return 0
+	}
+	return rs.scans[rs.t.Len].(*sql.NullFloat64).Float64
+}
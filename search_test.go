@@ -0,0 +1,25 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchGuarded(t *testing.T) {
+	users := openFake(t, 5)
+	users.FullText = []string{"name"}
+	SetGuardrails(Guardrails{MaxRows: 2})
+	defer SetGuardrails(Guardrails{})
+	rs, err := users.Search("user", NaturalLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	n := 0
+	for rs.Next() {
+		n++
+	}
+	if n != 2 || !errors.Is(rs.Err(), ErrTooManyRows) {
+		t.Fatalf("read %d rows, Err() = %v, want 2 rows and ErrTooManyRows", n, rs.Err())
+	}
+}
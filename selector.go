@@ -0,0 +1,232 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Selector 组合条件、排序和分页的查询
+type Selector struct {
+	t     *Table
+	conds []Condition
+	order []string
+	limit int
+	skip  int
+	//WITH 子句
+	ctes []expr
+	//附加在表字段之后的列
+	extras []expr
+	//只查询的字段，为空时查询全部字段
+	fields []string
+	//连接的表
+	joins  []expr
+	joined []*Table
+	//构建过程中的错误，执行时返回
+	err error
+}
+
+// 带参数的 SQL 片段
+type expr struct {
+	name  string
+	query string
+	args  []interface{}
+}
+
+// Select 以指定的条件开始构建查询
+func (t *Table) Select(conds ...Condition) *Selector {
+	return &Selector{t: t, conds: append([]Condition(nil), conds...)}
+}
+
+// Where 追加条件，所有条件用 AND 连接
+func (s *Selector) Where(conds ...Condition) *Selector {
+	s.conds = append(s.conds, conds...)
+	return s
+}
+
+// OrderBy 按字段升序排列
+func (s *Selector) OrderBy(column string) *Selector {
+	return s.orderBy(column, "ASC")
+}
+
+// OrderByDesc 按字段降序排列
+func (s *Selector) OrderByDesc(column string) *Selector {
+	return s.orderBy(column, "DESC")
+}
+
+func (s *Selector) orderBy(column, direction string) *Selector {
+	i, err := s.t.indexOf(column)
+	if err != nil {
+		if s.err == nil {
+			s.err = err
+		}
+		return s
+	}
+	s.order = append(s.order, s.t.Fields[i].FullName+" "+direction)
+	return s
+}
+
+// Fields 只查询指定的字段，用于 WITH 子句和 Table.AddFromQuery
+func (s *Selector) Fields(columns ...string) *Selector {
+	for _, column := range columns {
+		i, err := s.t.indexOf(column)
+		if err != nil {
+			if s.err == nil {
+				s.err = err
+			}
+			return s
+		}
+		s.fields = append(s.fields, s.t.Fields[i].FullName)
+	}
+	return s
+}
+
+// Limit 分页，与 List 的参数相同
+func (s *Selector) Limit(take, skip int) *Selector {
+	s.limit, s.skip = take, skip
+	return s
+}
+
+// 生成 WHERE 及之后的部分
+func (s *Selector) sqlTail() (string, []interface{}, error) {
+	if s.err != nil {
+		return "", nil, s.err
+	}
+	where, args, err := s.t.sqlWhere(s.conds)
+	if err != nil {
+		return "", nil, err
+	}
+	parts := []string{where}
+	if len(s.order) > 0 {
+		parts = append(parts, "ORDER BY "+strings.Join(s.order, ", "))
+	}
+	if s.limit > 0 {
+		parts = append(parts, "limit ?, ?")
+		args = append(args, s.skip, s.limit)
+	}
+	return strings.Join(parts, " "), args, nil
+}
+
+// 生成完整的语句，参数顺序依次为 WITH 子句、附加列、连接、条件和分页
+func (s *Selector) sql(extras bool) (string, []interface{}, error) {
+	tail, tailArgs, err := s.sqlTail()
+	if err != nil {
+		return "", nil, err
+	}
+	args := make([]interface{}, 0)
+	parts := make([]string, 0, 3)
+	if len(s.ctes) > 0 {
+		ctes := make([]string, len(s.ctes))
+		for i, c := range s.ctes {
+			ctes[i] = fmt.Sprintf("`%s` AS (%s)", c.name, c.query)
+			args = append(args, c.args...)
+		}
+		parts = append(parts, "WITH "+strings.Join(ctes, ", "))
+	}
+	selectSql := s.t.sqlSelect
+	if len(s.fields) > 0 {
+		selectSql = fmt.Sprintf("SELECT %s FROM %s ", strings.Join(s.fields, ", "), s.t.sqlFrom())
+	}
+	if extras && len(s.extras) > 0 {
+		columns := make([]string, len(s.extras))
+		for i, e := range s.extras {
+			columns[i] = fmt.Sprintf("%s AS `%s`", e.query, e.name)
+			args = append(args, e.args...)
+		}
+		selectSql = strings.Replace(selectSql, " FROM ", ", "+strings.Join(columns, ", ")+" FROM ", 1)
+	}
+	if joins, joinArgs := s.sqlJoins(); joins != "" {
+		selectSql += joins
+		args = append(args, joinArgs...)
+	}
+	parts = append(parts, selectSql, tail)
+	return strings.Join(parts, " "), append(args, tailArgs...), nil
+}
+
+// With 添加 WITH 子句（MySQL 8），sub 的参数排在最前面
+//
+//	recent := t.Select(db.Raw("created > ?", since))
+//	rows, err := t.Select(db.Raw("id IN (SELECT id FROM recent)")).With("recent", recent).GetMany()
+func (s *Selector) With(name string, sub *Selector) *Selector {
+	query, args, err := sub.sql(true)
+	if err != nil {
+		if s.err == nil {
+			s.err = err
+		}
+		return s
+	}
+	return s.WithRaw(name, query, args...)
+}
+
+// WithRaw 以 SQL 语句添加 WITH 子句
+func (s *Selector) WithRaw(name, query string, args ...interface{}) *Selector {
+	s.ctes = append(s.ctes, expr{name: name, query: query, args: args})
+	return s
+}
+
+// Column 在表字段之后附加一列，例如窗口函数，通过 Rows.Extra 读取
+//
+// 只有 GetMany 返回附加列。
+func (s *Selector) Column(alias, query string, args ...interface{}) *Selector {
+	s.extras = append(s.extras, expr{name: alias, query: query, args: args})
+	return s
+}
+
+// 只查询部分字段时无法映射到 Row 或 Rows
+var errFieldsSubset = errors.New("db: Selector with Fields can only be used as a subquery")
+
+// Get 查询第一行，不包括附加列
+func (s *Selector) Get() *Row {
+	if len(s.fields) > 0 {
+		return &Row{t: s.t, err: errFieldsSubset}
+	}
+	query, args, err := s.sql(false)
+	if err != nil {
+		return &Row{t: s.t, err: err}
+	}
+	if s.limit <= 0 {
+		query += " limit 1"
+	}
+	return s.t.row(query, args...)
+}
+
+// GetMany 查询所有满足条件的行
+func (s *Selector) GetMany() (*Rows, error) {
+	if len(s.fields) > 0 {
+		return nil, errFieldsSubset
+	}
+	query, args, err := s.sql(true)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.extras) == 0 {
+		return s.t.rows(query, args...)
+	}
+	rows, cancel, err := s.t.queryRows(limitQuery(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	scans := s.t.makeNullableScans()
+	names := make([]string, len(s.extras))
+	for i := range s.extras {
+		scans = append(scans, new(interface{}))
+		names[i] = s.extras[i].name
+	}
+	rs := &Rows{Rows: rows, t: s.t, scans: scans, extras: names, cancel: cancel}
+	return rs.guard().watch(), nil
+}
+
+// Count 统计满足条件的行数，忽略排序和分页
+func (s *Selector) Count() (int64, error) {
+	if s.err != nil {
+		return -1, s.err
+	}
+	where, args, err := s.t.sqlWhere(s.conds)
+	if err != nil {
+		return -1, err
+	}
+	if joins, joinArgs := s.sqlJoins(); joins != "" {
+		return s.t.scalarInt64(fmt.Sprintf("SELECT COUNT(%s.`%s`) FROM %s %s %s", s.t.TbName, s.t.PrimaryKey, s.t.sqlFrom(), joins, where), append(joinArgs, args...)...)
+	}
+	return s.t.scalarInt64(fmt.Sprintf("%s %s", s.t.sqlSelectCount, where), args...)
+}
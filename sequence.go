@@ -0,0 +1,103 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SequenceTableSql 序列表的建表语句
+func SequenceTableSql(name string) string {
+	return strings.Join([]string{
+		fmt.Sprintf("CREATE TABLE %s (", name),
+		"\t`name` varchar(128) NOT NULL,",
+		"\t`value` bigint(20) NOT NULL,",
+		"\tPRIMARY KEY (`name`)",
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+	}, "\n")
+}
+
+var sequences struct {
+	sync.Mutex
+	//序列表，默认 _sequence
+	table string
+	//每次从数据库分配的个数
+	batch int64
+	m     map[string]*Seq
+}
+
+// SetSequenceTable 设置序列表和每次分配的个数，默认为 _sequence 和 100
+//
+// 批量越大访问数据库越少，但进程退出时未用完的值会被跳过。
+func SetSequenceTable(table string, batch int64) {
+	sequences.Lock()
+	sequences.table, sequences.batch = table, batch
+	sequences.m = nil
+	sequences.Unlock()
+}
+
+// Seq 不依赖自增字段的序列，同名的序列在所有进程中不会重复
+type Seq struct {
+	name  string
+	table string
+	batch int64
+
+	mu   sync.Mutex
+	next int64
+	max  int64
+}
+
+// Sequence 返回名为 name 的序列，同一进程中同名的序列共享已分配的范围
+func Sequence(name string) *Seq {
+	sequences.Lock()
+	defer sequences.Unlock()
+	if s, ok := sequences.m[name]; ok {
+		return s
+	}
+	if sequences.m == nil {
+		sequences.m = make(map[string]*Seq)
+	}
+	s := &Seq{name: name, table: sequences.table, batch: sequences.batch}
+	if s.table == "" {
+		s.table = "_sequence"
+	}
+	if s.batch <= 0 {
+		s.batch = 100
+	}
+	sequences.m[name] = s
+	return s
+}
+
+// Next 序列的下一个值，从 1 开始
+func (s *Seq) Next() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == 0 || s.next > s.max {
+		max, err := s.allocate()
+		if err != nil {
+			return 0, err
+		}
+		s.next, s.max = max-s.batch+1, max
+	}
+	v := s.next
+	s.next++
+	return v, nil
+}
+
+// 在数据库中原子地分配一段，返回这一段的最大值
+func (s *Seq) allocate() (int64, error) {
+	strSql := fmt.Sprintf("UPDATE %s SET `value` = LAST_INSERT_ID(`value` + ?) WHERE `name` = ?", s.table)
+	for i := 0; i < 2; i++ {
+		res, err := Exec(strSql, s.batch, s.name)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return res.LastInsertId()
+		}
+		if _, err = Exec(fmt.Sprintf("INSERT IGNORE INTO %s (`name`, `value`) VALUES (?, 0)", s.table), s.name); err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("db: the sequence (%s) allocate failed", s.name)
+}
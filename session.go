@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+)
+
+var sessionInit struct {
+	sync.RWMutex
+	stmts []string
+}
+
+// SetSessionInit 设置每个新连接建立后执行的语句，必须在 Open 之前调用
+//
+// 用于 DSN 参数无法表达的会话设置，例如：
+//
+//	db.SetSessionInit("SET SESSION sql_mode = 'STRICT_ALL_TABLES'", "SET time_zone = '+00:00'")
+//
+// 任一语句失败时连接被丢弃，错误由触发建立连接的查询返回。
+func SetSessionInit(stmts ...string) {
+	sessionInit.Lock()
+	sessionInit.stmts = stmts
+	sessionInit.Unlock()
+}
+
+// 打开连接池，设置了会话初始化语句时通过 initConnector 建立连接
+func openDB(dsn string) (*sql.DB, error) {
+	sessionInit.RLock()
+	n := len(sessionInit.stmts)
+	sessionInit.RUnlock()
+	if n == 0 {
+		return sql.Open("mysql", dsn)
+	}
+	drv, err := driverContext()
+	if err != nil {
+		return nil, err
+	}
+	base, err := drv.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return openConnector(base), nil
+}
+
+// 用 connector 打开连接池，设置了会话初始化语句时包装为 initConnector
+func openConnector(base driver.Connector) *sql.DB {
+	sessionInit.RLock()
+	stmts := sessionInit.stmts
+	sessionInit.RUnlock()
+	if len(stmts) == 0 {
+		return sql.OpenDB(base)
+	}
+	return sql.OpenDB(&initConnector{Connector: base, stmts: stmts})
+}
+
+// 建立连接后执行初始化语句
+type initConnector struct {
+	driver.Connector
+	stmts []string
+}
+
+func (c *initConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range c.stmts {
+		if err = execConn(ctx, conn, stmt); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("db: session init (%s): %w", stmt, err)
+		}
+	}
+	return conn, nil
+}
+
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
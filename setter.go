@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// ErrNoRowsAffected 修改时没有影响任何行
+//
+// 默认只有值发生变化的行计入影响的行数，修改为相同的值时也返回该错误；
+// SetFoundRows 打开后匹配条件的行都计入，该错误只表示没有匹配的行。
+var ErrNoRowsAffected = errors.New("db: no rows affected")
+
+var foundRows int32
+
+// SetFoundRows 打开或关闭连接的 clientFoundRows，必须在 Open 之前调用，默认关闭
+//
+// 打开后 UPDATE 的 RowsAffected 为匹配条件的行数，而不是值发生变化的行数，对所有修改语句生效。
+func SetFoundRows(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&foundRows, v)
+}
+
+// 连接参数中的 clientFoundRows
+func foundRowsParam() string {
+	if atomic.LoadInt32(&foundRows) == 1 {
+		return "&clientFoundRows=true"
+	}
+	return ""
+}
+
+// Columns 按字段名修改，值为 nil 时设为 NULL
+func (s *Setter) Columns(columns map[string]interface{}) (int64, error) {
+	values := make([]interface{}, s.t.Len)
+	for name, value := range columns {
+		i, err := s.t.indexOf(name)
+		if err != nil {
+			return -1, err
+		}
+		if value == nil {
+			value = sql.NullString{}
+		}
+		values[i] = value
+	}
+	return s.Values(values...)
+}
+
+// StructDiff 比较同一类型的两个结构体，只修改值发生变化的字段
+//
+// 字段与表字段的对应规则与 Table.Match 相同。没有变化时不执行语句，返回 0。
+func (s *Setter) StructDiff(old, new interface{}) (int64, error) {
+	ov := reflect.Indirect(reflect.ValueOf(old))
+	nv := reflect.Indirect(reflect.ValueOf(new))
+	if ov.Kind() != reflect.Struct || ov.Type() != nv.Type() {
+		return -1, fmt.Errorf("db: the objects (%T, %T) are not structs of the same type", old, new)
+	}
+	leaves := structLeaves(ov.Type(), nil, "")
+	changed := make(map[string]interface{})
+	for i, leaf := range leaves {
+		if leaf.field.PkgPath != "" {
+			continue
+		}
+		column, err := s.t.columnOf(leaf, i, len(leaves))
+		if err != nil {
+			return -1, err
+		}
+		of, nf := ov.FieldByIndex(leaf.index), nv.FieldByIndex(leaf.index)
+		if column == "" || reflect.DeepEqual(of.Interface(), nf.Interface()) {
+			continue
+		}
+		changed[column] = nf.Interface()
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	return s.Columns(changed)
+}
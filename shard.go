@@ -0,0 +1,161 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// Shards 按分片键把同一个表分布到多个连接池
+type Shards struct {
+	tables []*Table
+	//分片键的位置
+	key int
+}
+
+// NewShards 创建分片路由
+//
+// 表结构从 Open 打开的连接池读取，所有分片的表结构必须相同。
+// key 为分片键字段，按其值的哈希选择分片，分片的顺序不能改变。
+func NewShards(tablename, key string, conns ...*sql.DB) (*Shards, error) {
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("db: no shard connections")
+	}
+	t, err := GetTable(tablename)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.indexOf(key)
+	if err != nil {
+		return nil, err
+	}
+	s := &Shards{tables: make([]*Table, len(conns)), key: k}
+	for i := range conns {
+		shard := *t
+		shard.db = conns[i]
+		s.tables[i] = &shard
+	}
+	return s, nil
+}
+
+// Shard 分片键的值所在分片的表
+func (s *Shards) Shard(key interface{}) *Table {
+	h := fnv.New32a()
+	fmt.Fprint(h, key)
+	return s.tables[h.Sum32()%uint32(len(s.tables))]
+}
+
+// Tables 所有分片的表
+func (s *Shards) Tables() []*Table {
+	return s.tables
+}
+
+// 参数中的分片键
+func (s *Shards) keyOf(args []interface{}) (interface{}, error) {
+	if s.key >= len(args) || args[s.key] == nil {
+		return nil, fmt.Errorf("db: the shard key (%s) is required", s.tables[0].Fields[s.key].Name)
+	}
+	return args[s.key], nil
+}
+
+// Add 插入到分片键所在的分片，values 必须包含分片键
+func (s *Shards) Add(values ...interface{}) (int64, error) {
+	key, err := s.keyOf(values)
+	if err != nil {
+		return -1, err
+	}
+	return s.Shard(key).Add(values...)
+}
+
+// Get 在分片键所在的分片查询，args 必须包含分片键
+func (s *Shards) Get(args ...interface{}) *Row {
+	key, err := s.keyOf(args)
+	if err != nil {
+		return &Row{t: s.tables[0], err: err}
+	}
+	return s.Shard(key).Get(args...)
+}
+
+// Del 在分片键所在的分片删除，args 必须包含分片键
+func (s *Shards) Del(args ...interface{}) (int64, error) {
+	key, err := s.keyOf(args)
+	if err != nil {
+		return -1, err
+	}
+	return s.Shard(key).Del(args...)
+}
+
+// Update 在分片键所在的分片修改，args 必须包含分片键
+//
+// 修改的值不能改变分片键，否则数据会留在原来的分片。
+func (s *Shards) Update(args ...interface{}) (*Setter, error) {
+	key, err := s.keyOf(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Shard(key).Update(args...), nil
+}
+
+// GetMany 查询多行，args 包含分片键时只查询一个分片，否则并发查询所有分片并合并结果
+//
+// 合并后的结果按分片顺序排列，各分片的结果集会一次读入内存，读到 Guardrails 的限制为止。
+func (s *Shards) GetMany(args ...interface{}) (*Rows, error) {
+	if key, err := s.keyOf(args); err == nil {
+		return s.Shard(key).GetMany(args...)
+	}
+	t := s.tables[0]
+	listwhere := make([]string, 0)
+	listparam := make([]interface{}, 0)
+	for i := range args {
+		if args[i] == nil {
+			continue
+		}
+		listwhere = append(listwhere, t.Fields[i].FullName+"=?")
+		listparam = append(listparam, args[i])
+	}
+	where := ""
+	if len(listwhere) > 0 {
+		where = "WHERE " + strings.Join(listwhere, " AND ")
+	}
+	results := make([][][]interface{}, len(s.tables))
+	errs := make([]error, len(s.tables))
+	var wg sync.WaitGroup
+	for i := range s.tables {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = s.tables[i].loadShard(fmt.Sprintf("%s %s", t.sqlSelect, where), listparam)
+		}(i)
+	}
+	wg.Wait()
+	data := make([][]interface{}, 0)
+	for i := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		data = append(data, results[i]...)
+	}
+	return (&Rows{t: t, scans: t.getScans(), src: newCachedRows(t, data)}).guard().watch(), nil
+}
+
+// 读取一个分片的结果，超过 Guardrails 的限制后停止读取
+//
+// 行数多读一行，字节数读到超过为止，合并后的结果集按同样的限制返回错误或截断。
+func (t *Table) loadShard(query string, args []interface{}) ([][]interface{}, error) {
+	rows, err := t.query(limitQuery(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	g := getGuardrails()
+	if g.MaxRows <= 0 && g.MaxBytes <= 0 {
+		return t.readAll(rows)
+	}
+	if g.MaxRows > 0 {
+		g.MaxRows++
+	}
+	g.Truncate = true
+	return t.readAll(&guardedSource{rowsSource: rows, g: g})
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestShardsGetManyGuarded(t *testing.T) {
+	openFake(t, 1<<40)
+	sqldb, err := sql.Open("dbtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewShards("users", "id", sqldb, sqldb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetGuardrails(Guardrails{MaxRows: 3})
+	defer SetGuardrails(Guardrails{})
+	rs, err := s.GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	n := 0
+	for rs.Next() {
+		n++
+	}
+	if n != 3 || !errors.Is(rs.Err(), ErrTooManyRows) {
+		t.Fatalf("read %d rows, Err() = %v, want 3 rows and ErrTooManyRows", n, rs.Err())
+	}
+}
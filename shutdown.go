@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+var closers = struct {
+	sync.Mutex
+	next int
+	fns  map[int]func(ctx context.Context) error
+}{fns: make(map[int]func(ctx context.Context) error)}
+
+// OnClose 注册 Close 时调用的函数，返回取消注册的函数
+//
+// 后台运行的组件用它接入 Close，fn 应停止接收新的工作并等待进行中的工作完成，
+// ctx 结束时不再等待并返回 ctx.Err()。
+func OnClose(fn func(ctx context.Context) error) (cancel func()) {
+	closers.Lock()
+	id := closers.next
+	closers.next++
+	closers.fns[id] = fn
+	closers.Unlock()
+	return func() {
+		closers.Lock()
+		delete(closers.fns, id)
+		closers.Unlock()
+	}
+}
+
+// Close 停止后台组件并关闭 Open 打开的连接池
+//
+// 依次按注册的相反顺序停止异步写入、清理器、CDC 等后台组件，再关闭连接池，
+// 连接池关闭时等待已开始的查询完成。ctx 结束时不再等待，返回遇到的第一个错误。
+// 关闭后包级函数和未指定连接池的表都无法再执行查询。
+func Close(ctx context.Context) error {
+	closers.Lock()
+	ids := make([]int, 0, len(closers.fns))
+	for id := range closers.fns {
+		ids = append(ids, id)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	fns := make([]func(ctx context.Context) error, len(ids))
+	for i, id := range ids {
+		fns[i] = closers.fns[id]
+		delete(closers.fns, id)
+	}
+	closers.Unlock()
+
+	var first error
+	for _, fn := range fns {
+		if err := fn(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	dbMu.RLock()
+	sqldb := db
+	dbMu.RUnlock()
+	if sqldb == nil {
+		return first
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- sqldb.Close()
+	}()
+	select {
+	case err := <-done:
+		if first == nil {
+			first = err
+		}
+	case <-ctx.Done():
+		if first == nil {
+			first = ctx.Err()
+		}
+	}
+	return first
+}
+
+// Stopper 把阻塞运行直到 ctx 结束的函数接入 Close
+//
+// Run 类方法在开始时调用 Start 得到新的 ctx，返回前调用 Done；
+// Close 时取消该 ctx 并等待 Done。
+type Stopper struct {
+	cancel     context.CancelFunc
+	done       chan struct{}
+	unregister func()
+}
+
+// Start 派生一个 Close 时取消的 ctx
+func (s *Stopper) Start(ctx context.Context) context.Context {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	s.unregister = OnClose(func(c context.Context) error {
+		s.cancel()
+		select {
+		case <-s.done:
+			return nil
+		case <-c.Done():
+			return c.Err()
+		}
+	})
+	return ctx
+}
+
+// Done 运行结束，取消注册
+func (s *Stopper) Done() {
+	s.unregister()
+	s.cancel()
+	close(s.done)
+}
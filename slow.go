@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlowQueryOptions 慢查询记录的选项
+type SlowQueryOptions struct {
+	//超过该时间的语句被记录
+	Threshold time.Duration
+	//保留最近的语句条数，默认 100
+	Size int
+	//第一次出现的慢 SELECT 在固定的连接上重新执行一次，按 SHOW STATUS 中 Handler_read 计数的变化统计读取的行数，
+	//代理模式下不统计
+	Examine bool
+}
+
+// SlowQuery 一条慢查询
+type SlowQuery struct {
+	//去掉参数和字面量的语句
+	Sql      string
+	Duration time.Duration
+	Time     time.Time
+}
+
+// SlowQueryStat 同一语句的汇总
+type SlowQueryStat struct {
+	Sql   string
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+	P95   time.Duration
+	//重新执行时服务器读取的行数，未开启 Examine 时为 0
+	RowsExamined int64
+}
+
+// SlowReport 慢查询报告
+type SlowReport struct {
+	//最近的慢查询，按时间从旧到新
+	Recent []SlowQuery
+	//按总耗时从大到小
+	Stats []SlowQueryStat
+}
+
+// 每条语句保留用于计算 P95 的耗时个数
+const slowSamples = 256
+
+type slowDigest struct {
+	stat    SlowQueryStat
+	samples []time.Duration
+	next    int
+}
+
+type slowLog struct {
+	mu      sync.Mutex
+	opt     SlowQueryOptions
+	ring    []SlowQuery
+	next    int
+	full    bool
+	digests map[string]*slowDigest
+}
+
+var slow struct {
+	sync.RWMutex
+	log *slowLog
+}
+
+// SetSlowQuery 开始记录慢查询，opt 为 nil 时停止并清空记录
+func SetSlowQuery(opt *SlowQueryOptions) {
+	var l *slowLog
+	if opt != nil {
+		if opt.Size <= 0 {
+			opt.Size = 100
+		}
+		l = &slowLog{opt: *opt, ring: make([]SlowQuery, opt.Size), digests: make(map[string]*slowDigest)}
+	}
+	slow.Lock()
+	slow.log = l
+	slow.Unlock()
+}
+
+// SlowQueries 返回慢查询报告
+func SlowQueries() SlowReport {
+	slow.RLock()
+	l := slow.log
+	slow.RUnlock()
+	if l == nil {
+		return SlowReport{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var report SlowReport
+	if l.full {
+		report.Recent = append(report.Recent, l.ring[l.next:]...)
+	}
+	report.Recent = append(report.Recent, l.ring[:l.next]...)
+	for _, d := range l.digests {
+		stat := d.stat
+		stat.P95 = percentile(d.samples, 0.95)
+		report.Stats = append(report.Stats, stat)
+	}
+	sort.Slice(report.Stats, func(i, j int) bool {
+		return report.Stats[i].Total > report.Stats[j].Total
+	})
+	return report
+}
+
+// 记录执行时间超过阈值的语句
+func observeSlow(sqldb *sql.DB, query string, args []interface{}, start time.Time) {
+	slow.RLock()
+	l := slow.log
+	slow.RUnlock()
+	if l == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	if elapsed < l.opt.Threshold {
+		return
+	}
+	normalized := NormalizeSql(query)
+	l.mu.Lock()
+	l.ring[l.next] = SlowQuery{Sql: normalized, Duration: elapsed, Time: start}
+	l.next++
+	if l.next == len(l.ring) {
+		l.next, l.full = 0, true
+	}
+	d, ok := l.digests[normalized]
+	if !ok {
+		d = &slowDigest{stat: SlowQueryStat{Sql: normalized}}
+		l.digests[normalized] = d
+	}
+	d.stat.Count++
+	d.stat.Total += elapsed
+	if elapsed > d.stat.Max {
+		d.stat.Max = elapsed
+	}
+	if len(d.samples) < slowSamples {
+		d.samples = append(d.samples, elapsed)
+	} else {
+		d.samples[d.next] = elapsed
+		d.next = (d.next + 1) % slowSamples
+	}
+	l.mu.Unlock()
+	if !ok && l.opt.Examine && isSelect(query) && getProxyMode() == ProxyNone {
+		go l.examine(sqldb, normalized, query, args)
+	}
+}
+
+// 在固定的连接上重新执行，用会话的 Handler_read 计数统计读取的行数
+func (l *slowLog) examine(sqldb *sql.DB, normalized, query string, args []interface{}) {
+	ctx := context.Background()
+	c, err := sqldb.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	//SHOW STATUS 本身也会增加计数，连续读取两次得到它的开销
+	before, err := handlerReads(ctx, c)
+	if err != nil {
+		return
+	}
+	base, err := handlerReads(ctx, c)
+	if err != nil {
+		return
+	}
+	rows, err := c.QueryContext(ctx, query, convertArgs(args)...)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return
+	}
+	after, err := handlerReads(ctx, c)
+	if err != nil {
+		return
+	}
+	examined := after - base - (base - before)
+	if examined < 0 {
+		examined = 0
+	}
+	l.mu.Lock()
+	if d, ok := l.digests[normalized]; ok {
+		d.stat.RowsExamined = examined
+	}
+	l.mu.Unlock()
+}
+
+// 会话中 Handler_read_first、Handler_read_key、Handler_read_rnd_next 等计数的和
+func handlerReads(ctx context.Context, c *sql.Conn) (int64, error) {
+	rows, err := c.QueryContext(ctx, "SHOW SESSION STATUS LIKE 'Handler_read%'")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var total int64
+	for rows.Next() {
+		var name string
+		var value int64
+		if err = rows.Scan(&name, &value); err != nil {
+			return 0, err
+		}
+		total += value
+	}
+	return total, rows.Err()
+}
+
+func isSelect(query string) bool {
+	trimmed := strings.TrimLeft(query, " \t\r\n")
+	return len(trimmed) >= 6 && strings.EqualFold(trimmed[:6], "SELECT")
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+var (
+	reComment = regexp.MustCompile(`/\*.*?\*/`)
+	reString  = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.)*"`)
+	reNumber  = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	reList    = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	reSpace   = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeSql 去掉注释和字面量，合并 IN 列表和空白，使同一语句的不同调用归为一类
+func NormalizeSql(query string) string {
+	query = reComment.ReplaceAllString(query, "")
+	query = reString.ReplaceAllString(query, "?")
+	query = reNumber.ReplaceAllString(query, "?")
+	query = reList.ReplaceAllString(query, "(...)")
+	return strings.TrimSpace(reSpace.ReplaceAllString(query, " "))
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaSnapshot 表结构的快照，可以导出为 JSON 或 YAML，不需要连接就能还原为 Table
+type SchemaSnapshot struct {
+	Tables []TableSchema `json:"tables"`
+}
+
+// TableSchema 一个表的结构
+type TableSchema struct {
+	Database   string         `json:"database"`
+	Name       string         `json:"name"`
+	Comment    string         `json:"comment,omitempty"`
+	Charset    string         `json:"charset,omitempty"`
+	Collation  string         `json:"collation,omitempty"`
+	PrimaryKey string         `json:"primary_key,omitempty"`
+	Columns    []ColumnSchema `json:"columns"`
+	Indexes    []IndexSchema  `json:"indexes,omitempty"`
+	Partition  *Partitioning  `json:"partition,omitempty"`
+}
+
+// ColumnSchema 一个字段的结构
+type ColumnSchema struct {
+	Name string `json:"name"`
+	//字段类型，例如 int(11) unsigned、varchar(64)、enum('a','b')
+	Type string `json:"type"`
+	Null bool   `json:"null"`
+	//默认值，nil 表示没有默认值或默认为 NULL
+	Default *string `json:"default,omitempty"`
+	//默认值是表达式，例如 CURRENT_TIMESTAMP
+	DefaultExpression bool   `json:"default_expression,omitempty"`
+	Key               string `json:"key,omitempty"`
+	Extra             string `json:"extra,omitempty"`
+	Comment           string `json:"comment,omitempty"`
+	Charset           string `json:"charset,omitempty"`
+	Collation         string `json:"collation,omitempty"`
+}
+
+// IndexSchema 一个索引的结构
+type IndexSchema struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique,omitempty"`
+	Type    string   `json:"type,omitempty"`
+}
+
+// Schema 生成表结构的快照
+func (t Table) Schema() TableSchema {
+	s := TableSchema{
+		Database: t.DbName, Name: t.TbName, Comment: t.Comment,
+		Charset: t.Charset, Collation: t.Collation, PrimaryKey: t.PrimaryKey,
+		Columns: make([]ColumnSchema, len(t.Fields)), Partition: t.Partition,
+	}
+	for i, f := range t.Fields {
+		c := ColumnSchema{
+			Name: f.Name, Type: f.Type.ToSql(), Null: f.Null, Key: f.Key,
+			Extra: f.Extra, Comment: f.Comment, Charset: f.Charset, Collation: f.Collation,
+		}
+		if !f.Default.Null {
+			value := f.Default.Value
+			c.Default = &value
+			c.DefaultExpression = f.Default.CurrentTimestamp || f.Default.Expression
+		}
+		s.Columns[i] = c
+	}
+	for _, idx := range t.Indexes {
+		s.Indexes = append(s.Indexes, IndexSchema{Name: idx.Name, Columns: idx.Columns, Unique: idx.Unique, Type: idx.Type})
+	}
+	return s
+}
+
+// Table 从快照还原表，不访问数据库
+//
+// 还原的表可以用于 ToSql、Document 和比较结构；执行查询时使用 Open 打开的连接池。
+func (s TableSchema) Table() (*Table, error) {
+	var table Table
+	table.DbName, table.TbName = s.Database, s.Name
+	table.Comment, table.Charset, table.Collation = s.Comment, s.Charset, s.Collation
+	table.PrimaryKey = s.PrimaryKey
+	table.Partition = s.Partition
+	table.UniqueIndex = make([]string, 0)
+	table.idempotencyKey = -1
+	for _, c := range s.Columns {
+		f := Field{
+			Name: c.Name, Null: c.Null, Key: c.Key, Extra: c.Extra,
+			Comment: c.Comment, Charset: c.Charset, Collation: c.Collation,
+			FullName: fmt.Sprintf("%s.`%s`", s.Name, c.Name),
+		}
+		if err := f.Type.Scan(c.Type); err != nil {
+			return nil, fmt.Errorf("db: the column (%s.%s) type: %w", s.Name, c.Name, err)
+		}
+		if c.Default == nil {
+			f.Default = FieldDefault{Null: true, Value: "NULL"}
+		} else {
+			f.Default = FieldDefault{Value: *c.Default}
+			if c.DefaultExpression {
+				if err := f.Default.Scan([]byte(*c.Default)); err != nil {
+					return nil, err
+				}
+				f.Default.Expression = !f.Default.CurrentTimestamp
+			}
+		}
+		table.Fields = append(table.Fields, f)
+		table.sqlArgMark = append(table.sqlArgMark, "?")
+		if c.Key == "UNI" {
+			table.UniqueIndex = append(table.UniqueIndex, c.Name)
+		}
+	}
+	table.Len = len(table.Fields)
+	if table.Len == 0 {
+		return nil, fmt.Errorf("the table (%s) columns no found", s.Name)
+	}
+	for _, idx := range s.Indexes {
+		table.Indexes = append(table.Indexes, Index{Name: idx.Name, Columns: idx.Columns, Unique: idx.Unique, Type: idx.Type})
+		if idx.Type == "FULLTEXT" && table.FullText == nil {
+			table.FullText = idx.Columns
+		}
+	}
+	table.prepareSql()
+	table.newSchemaCache()
+	return &table, nil
+}
+
+// Snapshot 生成多个表的快照
+func Snapshot(tables ...*Table) *SchemaSnapshot {
+	s := &SchemaSnapshot{Tables: make([]TableSchema, len(tables))}
+	for i := range tables {
+		s.Tables[i] = tables[i].Schema()
+	}
+	return s
+}
+
+// Snapshot 读取数据库中所有表的结构
+func (d Database) Snapshot() (*SchemaSnapshot, error) {
+	names, err := d.ShowTables()
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]*Table, len(names))
+	for i := range names {
+		if tables[i], err = getTable(d.Name, names[i]); err != nil {
+			return nil, err
+		}
+	}
+	return Snapshot(tables...), nil
+}
+
+// JSON 导出为 JSON
+func (s *SchemaSnapshot) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// YAML 导出为 YAML
+func (s *SchemaSnapshot) YAML() ([]byte, error) {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err = json.Unmarshal(buf, &tree); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	writeYAML(&b, tree, 0)
+	return b.Bytes(), nil
+}
+
+// LoadSchema 读取 JSON 或 YAML 格式的快照
+//
+// 支持 JSON 和 YAML 导出的格式，YAML 只支持块格式的映射和序列以及 JSON 风格的标量。
+func LoadSchema(data []byte) (*SchemaSnapshot, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		tree, err := parseYAML(data)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(tree); err != nil {
+			return nil, err
+		}
+	}
+	var s SchemaSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Build 还原快照中的所有表
+func (s *SchemaSnapshot) Build() ([]*Table, error) {
+	tables := make([]*Table, len(s.Tables))
+	for i := range s.Tables {
+		t, err := s.Tables[i].Table()
+		if err != nil {
+			return nil, err
+		}
+		tables[i] = t
+	}
+	return tables, nil
+}
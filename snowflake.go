@@ -0,0 +1,145 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// IDGenerator 在客户端生成主键，Seq 和 Snowflake 都实现了该接口
+type IDGenerator interface {
+	Next() (int64, error)
+}
+
+// Snowflake 按时间递增的 64 位 ID
+//
+// 从高到低为 41 位毫秒时间戳（从 2020-01-01 起）、10 位机器号和 12 位序号，
+// 每台机器每毫秒最多生成 4096 个。
+type Snowflake struct {
+	mu      sync.Mutex
+	machine int64
+	last    int64
+	seq     int64
+}
+
+// Snowflake 的起始时间，毫秒
+var snowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+
+// NewSnowflake 创建 ID 生成器，machine 取值 0 到 1023，同时运行的实例必须不同
+func NewSnowflake(machine int64) (*Snowflake, error) {
+	if machine < 0 || machine > 1023 {
+		return nil, fmt.Errorf("db: the snowflake machine id (%d) must be in [0, 1023]", machine)
+	}
+	return &Snowflake{machine: machine}, nil
+}
+
+// Next 生成下一个 ID，时钟回拨超过 1 秒时返回错误
+func (s *Snowflake) Next() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+	if now < s.last {
+		if s.last-now > 1000 {
+			return 0, fmt.Errorf("db: the clock moved backwards by %dms", s.last-now)
+		}
+		now = s.last
+	}
+	if now == s.last {
+		s.seq = (s.seq + 1) & 4095
+		if s.seq == 0 {
+			//本毫秒的序号用完，等待下一毫秒
+			for now <= s.last {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+			}
+		}
+	} else {
+		s.seq = 0
+	}
+	s.last = now
+	return now<<22 | s.machine<<12 | s.seq, nil
+}
+
+// SetIDGenerator 设置主键生成器，Add、AddStruct 和 AddAsync 在省略主键时用它生成
+//
+// 生成的主键由 Add 返回，不再依赖 LastInsertId。gen 为 nil 时取消。
+func (t *Table) SetIDGenerator(gen IDGenerator) error {
+	if gen != nil {
+		if _, err := t.indexOf(t.PrimaryKey); err != nil {
+			return err
+		}
+	}
+	t.idGen = gen
+	return nil
+}
+
+// 省略主键时生成主键，返回新的切片和生成的值
+func (t Table) fillID(values []interface{}) ([]interface{}, int64, error) {
+	i, err := t.indexOf(t.PrimaryKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	if i < len(values) && values[i] != nil {
+		return values, 0, nil
+	}
+	id, err := t.idGen.Next()
+	if err != nil {
+		return nil, 0, err
+	}
+	filled := make([]interface{}, t.Len)
+	copy(filled, values)
+	filled[i] = id
+	return filled, id, nil
+}
+
+// AddStruct 按字段映射插入结构体，规则与 Match 相同
+//
+// 主键字段为零值时省略，设置了 IDGenerator 时生成主键并写回结构体。
+func (t Table) AddStruct(object interface{}) (int64, error) {
+	rv := reflect.ValueOf(object)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return -1, fmt.Errorf("db: the object (%T) is not a pointer to struct", object)
+	}
+	rv = rv.Elem()
+	leaves := structLeaves(rv.Type(), nil, "")
+	values := make([]interface{}, t.Len)
+	var pkField []int
+	for i, leaf := range leaves {
+		if leaf.field.PkgPath != "" {
+			continue
+		}
+		column, err := t.columnOf(leaf, i, len(leaves))
+		if err != nil {
+			return -1, err
+		}
+		if column == "" {
+			continue
+		}
+		k, _ := t.indexOf(column)
+		fv := rv.FieldByIndex(leaf.index)
+		if column == t.PrimaryKey {
+			pkField = leaf.index
+			if fv.IsZero() {
+				continue
+			}
+		}
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			continue
+		}
+		values[k] = fv.Interface()
+	}
+	id, err := t.Add(values...)
+	if err != nil {
+		return id, err
+	}
+	if pkField != nil && rv.FieldByIndex(pkField).IsZero() {
+		switch fv := rv.FieldByIndex(pkField); fv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fv.SetInt(id)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fv.SetUint(uint64(id))
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NamedQuery SQL 文件中的一条命名语句
+//
+// Query、QueryRow 和 Exec 第一次在某个连接池上执行时预编译语句，之后复用。
+// 预编译语句与 QueryContext 等一样经过策略、预算、并发限制、熔断、超时和日志；
+// 需要加注释、超时提示或固定连接时直接发送语句文本；代理模式下不预编译。
+type NamedQuery struct {
+	Name string
+	Sql  string
+	//语句中 ? 占位符的个数，执行时参数个数必须相同
+	Params int
+
+	//每个连接池上预编译的语句
+	stmts sync.Map
+}
+
+// Queries 从 SQL 文件中读取的命名语句
+type Queries struct {
+	queries map[string]*NamedQuery
+}
+
+// 命名语句的开始：-- name: GetUserByEmail
+var reQueryName = regexp.MustCompile(`^\s*--\s*name:\s*(\S+)\s*$`)
+
+// ParseQueries 读取 SQL 文本中的命名语句
+//
+// 每条语句以 "-- name: 名称" 开始，到下一个名称或文本结束为止，末尾的分号可以省略：
+//
+//	-- name: GetUserByEmail
+//	SELECT * FROM users WHERE email = ?;
+//
+// 名称重复或第一个名称之前有语句时返回错误。
+func ParseQueries(r io.Reader) (*Queries, error) {
+	qs := &Queries{queries: make(map[string]*NamedQuery)}
+	if err := qs.parse(r, ""); err != nil {
+		return nil, err
+	}
+	return qs, nil
+}
+
+// LoadQueries 读取 fsys 中与 patterns 匹配的 .sql 文件，可以使用 embed.FS 或 os.DirFS
+//
+//	//go:embed queries/*.sql
+//	var files embed.FS
+//	queries, err := db.LoadQueries(files, "queries/*.sql")
+//
+// 不同文件中的名称也不能重复。
+func LoadQueries(fsys fs.FS, patterns ...string) (*Queries, error) {
+	qs := &Queries{queries: make(map[string]*NamedQuery)}
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			f, err := fsys.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			err = qs.parse(f, name)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return qs, nil
+}
+
+func (qs *Queries) parse(r io.Reader, file string) error {
+	var q *NamedQuery
+	var lines []string
+	finish := func() error {
+		if q == nil {
+			return nil
+		}
+		q.Sql = strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";")
+		if q.Sql == "" {
+			return fmt.Errorf("db: the query (%s) in %s is empty", q.Name, file)
+		}
+		q.Params = countParams(q.Sql)
+		qs.queries[q.Name] = q
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if m := reQueryName.FindStringSubmatch(line); m != nil {
+			if err := finish(); err != nil {
+				return err
+			}
+			if _, ok := qs.queries[m[1]]; ok {
+				return fmt.Errorf("db: the query (%s) in %s:%d is duplicated", m[1], file, n)
+			}
+			q, lines = &NamedQuery{Name: m[1]}, nil
+			continue
+		}
+		if q == nil {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return fmt.Errorf("db: the statement in %s:%d has no name", file, n)
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return finish()
+}
+
+// 统计语句中的 ? 占位符，跳过字符串、标识符和注释
+func countParams(query string) int {
+	n := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(query) && query[i] != c; i++ {
+				if query[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case c == '#' || c == '-' && strings.HasPrefix(query[i:], "-- "):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+		case c == '?':
+			n++
+		}
+	}
+	return n
+}
+
+// Get 按名称取得语句
+func (qs *Queries) Get(name string) (*NamedQuery, error) {
+	if q, ok := qs.queries[name]; ok {
+		return q, nil
+	}
+	return nil, fmt.Errorf("db: the query (%s) is not defined", name)
+}
+
+// Names 所有语句的名称，按名称排序
+func (qs *Queries) Names() []string {
+	names := make([]string, 0, len(qs.queries))
+	for name := range qs.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check 在服务器上预编译所有语句，启动时调用可以尽早发现语法错误和不存在的表或字段
+func (qs *Queries) Check(ctx context.Context) error {
+	sqldb := connFrom(ctx)
+	for _, name := range qs.Names() {
+		stmt, err := prepareOn(sqldb, ctx, qs.queries[name].Sql)
+		if err != nil {
+			return fmt.Errorf("db: the query (%s): %w", name, err)
+		}
+		stmt.Close()
+	}
+	return nil
+}
+
+// Close 释放所有连接池上预编译的语句，之后执行时重新预编译
+func (qs *Queries) Close() error {
+	var err error
+	for _, q := range qs.queries {
+		if e := q.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (q *NamedQuery) close() error {
+	var err error
+	q.stmts.Range(func(key, value interface{}) bool {
+		q.stmts.Delete(key)
+		if e := value.(*sql.Stmt).Close(); e != nil && err == nil {
+			err = e
+		}
+		return true
+	})
+	return err
+}
+
+// 取得连接池上预编译的语句，代理模式下返回 nil
+func (q *NamedQuery) stmt(ctx context.Context, sqldb *sql.DB) (*sql.Stmt, error) {
+	if getProxyMode() != ProxyNone {
+		return nil, nil
+	}
+	if v, ok := q.stmts.Load(sqldb); ok {
+		return v.(*sql.Stmt), nil
+	}
+	stmt, err := prepareOn(sqldb, ctx, q.Sql)
+	if err != nil {
+		return nil, err
+	}
+	if v, loaded := q.stmts.LoadOrStore(sqldb, stmt); loaded {
+		//其他 goroutine 已经预编译
+		stmt.Close()
+		return v.(*sql.Stmt), nil
+	}
+	return stmt, nil
+}
+
+// 检查参数个数
+func (q *NamedQuery) check(args []interface{}) error {
+	if len(args) != q.Params {
+		return fmt.Errorf("db: the query (%s) expects %d arguments, got %d", q.Name, q.Params, len(args))
+	}
+	return nil
+}
+
+// Query 执行查询，连接池与 QueryContext 相同
+func (q *NamedQuery) Query(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
+	if err := q.check(args); err != nil {
+		return nil, err
+	}
+	sqldb := connFrom(ctx)
+	stmt, err := q.stmt(ctx, sqldb)
+	if err != nil {
+		return nil, err
+	}
+	return queryStmtOn(sqldb, stmt, ctx, q.Sql, args...)
+}
+
+// QueryRow 查询一行，连接池与 QueryRowContext 相同，参数个数不对时 Scan 返回错误
+func (q *NamedQuery) QueryRow(ctx context.Context, args ...interface{}) *sql.Row {
+	if err := q.check(args); err != nil {
+		return QueryRowContext(rejectedCtx{Context: ctx, err: err}, q.Sql, args...)
+	}
+	sqldb := connFrom(ctx)
+	stmt, err := q.stmt(ctx, sqldb)
+	if err != nil {
+		return QueryRowContext(rejectedCtx{Context: ctx, err: err}, q.Sql, args...)
+	}
+	return queryRowStmtOn(sqldb, stmt, ctx, q.Sql, args...)
+}
+
+// Exec 执行语句，连接池与 ExecContext 相同
+func (q *NamedQuery) Exec(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	if err := q.check(args); err != nil {
+		return nil, err
+	}
+	sqldb := connFrom(ctx)
+	stmt, err := q.stmt(ctx, sqldb)
+	if err != nil {
+		return nil, err
+	}
+	return execStmtOn(sqldb, stmt, ctx, q.Sql, args...)
+}
+
+// Rows 通过表执行查询，结果可以使用 Struct、Map 等方法读取
+//
+// 语句查询的字段及顺序必须与表相同，例如 SELECT users.* FROM users JOIN ...
+func (q *NamedQuery) Rows(t *Table, args ...interface{}) (*Rows, error) {
+	if err := q.check(args); err != nil {
+		return nil, err
+	}
+	return t.rows(q.Sql, args...)
+}
+
+// Row 通过表查询一行，要求与 Rows 相同
+func (q *NamedQuery) Row(t *Table, args ...interface{}) *Row {
+	if err := q.check(args); err != nil {
+		return &Row{t: t, err: err}
+	}
+	return t.row(q.Sql, args...)
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNamedQueryHooks(t *testing.T) {
+	openFake(t, 2)
+	qs, err := ParseQueries(strings.NewReader("-- name: Rename\nUPDATE test.users SET name = ? WHERE id = ?;\n-- name: Users\nSELECT * FROM test.users;\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qs.Close()
+	var mu sync.Mutex
+	var logged []string
+	SetQueryLogger(func(l QueryLog) {
+		mu.Lock()
+		logged = append(logged, l.Sql)
+		mu.Unlock()
+	})
+	defer SetQueryLogger(nil)
+	rename, _ := qs.Get("Rename")
+	users, _ := qs.Get("Users")
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err = rename.Exec(ctx, "a", 1); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := users.Query(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+	}
+	mu.Lock()
+	n := len(logged)
+	mu.Unlock()
+	if n != 4 {
+		t.Fatalf("logged %d statements, want 4", n)
+	}
+	//预编译之后开启只读模式，语句仍然被拒绝
+	SetReadOnly(true)
+	defer SetReadOnly(false)
+	if _, err = rename.Exec(ctx, "b", 1); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Exec in read-only mode = %v, want ErrReadOnly", err)
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpStats 一个表上一种操作的统计
+type OpStats struct {
+	Table string
+	//SELECT、INSERT、UPDATE、DELETE、REPLACE 或 OTHER
+	Op     string
+	Count  int64
+	Errors int64
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// Avg 平均耗时
+func (s OpStats) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+type opKey struct {
+	table string
+	op    string
+}
+
+var opStats struct {
+	sync.Mutex
+	m map[opKey]*OpStats
+}
+
+// Stats 返回通过 Table 执行的语句按表和操作的统计，按总耗时从大到小排列
+func Stats() []OpStats {
+	opStats.Lock()
+	defer opStats.Unlock()
+	s := make([]OpStats, 0, len(opStats.m))
+	for _, v := range opStats.m {
+		s = append(s, *v)
+	}
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Total > s[j].Total
+	})
+	return s
+}
+
+// ResetStats 清空统计
+func ResetStats() {
+	opStats.Lock()
+	opStats.m = nil
+	opStats.Unlock()
+}
+
+// 语句的操作类型
+func opOf(query string) string {
+	trimmed := strings.TrimLeft(query, " \t\r\n(")
+	if i := strings.IndexAny(trimmed, " \t\r\n"); i > 0 {
+		trimmed = trimmed[:i]
+	}
+	switch op := strings.ToUpper(trimmed); op {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE":
+		return op
+	case "WITH":
+		return "SELECT"
+	}
+	return "OTHER"
+}
+
+func recordStats(table, query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	key := opKey{table: table, op: opOf(query)}
+	opStats.Lock()
+	if opStats.m == nil {
+		opStats.m = make(map[opKey]*OpStats)
+	}
+	s, ok := opStats.m[key]
+	if !ok {
+		s = &OpStats{Table: key.table, Op: key.op}
+		opStats.m[key] = s
+	}
+	s.Count++
+	if err != nil {
+		s.Errors++
+	}
+	s.Total += elapsed
+	if elapsed > s.Max {
+		s.Max = elapsed
+	}
+	opStats.Unlock()
+}
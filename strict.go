@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// float64 能精确表示的最大整数 2^53
+const maxExactFloat = 1 << 53
+
+// 打开了严格转换的连接池，键为 nil 时是 Open 打开的连接池
+var strictPools sync.Map
+
+// SetStrictConvert 打开或关闭连接池上的严格转换，sqldb 为 nil 时是 Open 打开的连接池，重新连接后仍然有效
+//
+// 严格转换时，Scan、Struct 等方法读到的值不能无损地转换为目标类型时返回错误，而不是尽量转换：
+//   - 超出 2^53 的整数不能读到 float64；
+//   - 字符串只有 0、1、true、false 可以读到 bool；
+//   - 零值日期和只有时间的字符串不能读到 time.Time。
+//
+// 从副本读取的表按表的主连接池的设置转换。
+func SetStrictConvert(sqldb *sql.DB, on bool) {
+	if on {
+		strictPools.Store(sqldb, true)
+	} else {
+		strictPools.Delete(sqldb)
+	}
+}
+
+// SetStrictConvert 只对这个表打开或关闭严格转换
+//
+// 表上的设置和连接池的设置任一打开即为严格转换。
+func (t *Table) SetStrictConvert(on bool) {
+	t.strict = on
+}
+
+func (t Table) isStrict() bool {
+	return t.strict || strictOn(t.sqlDB())
+}
+
+func strictOn(sqldb *sql.DB) bool {
+	if _, ok := strictPools.Load(sqldb); ok {
+		return true
+	}
+	_, ok := strictPools.Load((*sql.DB)(nil))
+	return ok && sqldb == conn()
+}
+
+// 按表的设置转换读到的值
+func (t Table) convertValue(dest interface{}, src interface{}) error {
+	return convertValueMode(dest, src, t.isStrict())
+}
+
+// 严格转换时字符串到 bool
+func strictBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true":
+		return true, nil
+	case "0", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("db: the string (%s) can't convert to bool", s)
+}
+
+// 严格转换时字符串必须是完整的日期
+func strictDateTime(s string) error {
+	if s == "" || strings.HasPrefix(s, "0000-00-00") {
+		return fmt.Errorf("db: the zero date (%s) can't convert to time", s)
+	}
+	if len(s) < 10 {
+		return fmt.Errorf("db: the string (%s) is not a date", s)
+	}
+	if _, err := time.Parse("2006-01-02", s[:10]); err != nil {
+		return fmt.Errorf("db: the string (%s) is not a date", s)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import "net/url"
+
+// 所有字段格式化为字符串，NULL 为空字符串
+func (t Table) parseStringMap(scans []interface{}) (map[string]string, error) {
+	data := make(map[string]string, t.Len)
+	for i := range t.Fields {
+		if parseValue(scans[i]) == nil {
+			data[t.Fields[i].Name] = ""
+			continue
+		}
+		var s string
+		if err := t.convertValue(&s, scans[i]); err != nil {
+			return nil, err
+		}
+		data[t.Fields[i].Name] = s
+	}
+	return data, nil
+}
+
+// StringMap 读取一行，所有字段格式化为字符串，NULL 为空字符串
+//
+// 格式与 Scan 到 *string 相同，适用于模板和导出 CSV。
+func (r *Row) StringMap() (map[string]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	scans := r.t.getScans()
+	defer r.t.putScans(scans)
+	if err := r.t.scan(r.source(), scans); err != nil {
+		return nil, r.t.wrapErr(r.query, err)
+	}
+	return r.t.parseStringMap(scans)
+}
+
+// StringMap 读取当前行，所有字段格式化为字符串，NULL 为空字符串
+func (rs *Rows) StringMap() (map[string]string, error) {
+	if err := rs.t.scan(rs.source(), rs.scans); err != nil {
+		return nil, err
+	}
+	return rs.t.parseStringMap(rs.scans)
+}
+
+// 每个字段一个值
+func toURLValues(data map[string]string) url.Values {
+	values := make(url.Values, len(data))
+	for k, v := range data {
+		values[k] = []string{v}
+	}
+	return values
+}
+
+// URLValues 读取一行，与 StringMap 相同，结果可以直接用于 Encode 生成查询字符串或表单
+func (r *Row) URLValues() (url.Values, error) {
+	data, err := r.StringMap()
+	if err != nil {
+		return nil, err
+	}
+	return toURLValues(data), nil
+}
+
+// URLValues 读取当前行，与 StringMap 相同
+func (rs *Rows) URLValues() (url.Values, error) {
+	data, err := rs.StringMap()
+	if err != nil {
+		return nil, err
+	}
+	return toURLValues(data), nil
+}
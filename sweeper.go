@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// SweeperOptions 过期行清理的选项
+type SweeperOptions struct {
+	//两次清理的间隔，默认 1 分钟
+	Interval time.Duration
+	//每次间隔随机增加的最长时间，避免多个实例同时清理，默认 Interval 的十分之一
+	Jitter time.Duration
+	//每批删除的行数，默认 1000
+	BatchSize int
+	//两批之间的停顿
+	Pause time.Duration
+	//删除失败时回调
+	OnError func(table string, err error)
+}
+
+// SweepStats 一个表的清理统计
+type SweepStats struct {
+	Table   string
+	Runs    int64
+	Deleted int64
+	Errors  int64
+	LastRun time.Time
+	//最近一次清理的耗时
+	LastDuration time.Duration
+}
+
+type sweepEntry struct {
+	t         *Table
+	column    string
+	retention time.Duration
+	stats     SweepStats
+}
+
+// Sweeper 在后台按批删除过期的行
+type Sweeper struct {
+	opt     SweeperOptions
+	mu      sync.Mutex
+	entries []*sweepEntry
+	//Start 之前为 nil，停止后关闭
+	stop chan struct{}
+	done chan struct{}
+	//取消 Close 时的注册
+	unregister func()
+}
+
+// NewSweeper 创建清理器，注册表后调用 Start 开始清理
+func NewSweeper(opt SweeperOptions) *Sweeper {
+	if opt.Interval <= 0 {
+		opt.Interval = time.Minute
+	}
+	if opt.Jitter <= 0 {
+		opt.Jitter = opt.Interval / 10
+	}
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 1000
+	}
+	return &Sweeper{opt: opt}
+}
+
+// Register 删除 column 早于当前时间减去 retention 的行
+func (s *Sweeper) Register(t *Table, column string, retention time.Duration) error {
+	if _, err := t.indexOf(column); err != nil {
+		return err
+	}
+	if retention <= 0 {
+		return fmt.Errorf("db: the retention (%s) must be positive", retention)
+	}
+	s.mu.Lock()
+	s.entries = append(s.entries, &sweepEntry{t: t, column: column, retention: retention, stats: SweepStats{Table: t.Fullname}})
+	s.mu.Unlock()
+	return nil
+}
+
+// Start 在后台开始定时清理，已经在运行时什么也不做，Stop 之后可以再次调用
+func (s *Sweeper) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil && !isClosed(s.stop) {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.unregister = OnClose(s.Stop)
+	go s.run(s.stop, s.done)
+}
+
+// Stop 停止清理，等待正在进行的一批完成，可以重复调用，Start 之前调用什么也不做
+func (s *Sweeper) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	if stop == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	if !isClosed(stop) {
+		close(stop)
+		s.unregister()
+	}
+	s.mu.Unlock()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Sweeper) run(stop, done chan struct{}) {
+	defer close(done)
+	for {
+		timer := time.NewTimer(s.opt.Interval + time.Duration(rand.Int63n(int64(s.opt.Jitter)+1)))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.mu.Lock()
+		entries := append([]*sweepEntry(nil), s.entries...)
+		s.mu.Unlock()
+		for _, e := range entries {
+			if !s.sweep(e, stop) {
+				return
+			}
+		}
+	}
+}
+
+// Sweep 立即清理一次所有注册的表
+func (s *Sweeper) Sweep() {
+	s.mu.Lock()
+	entries := append([]*sweepEntry(nil), s.entries...)
+	s.mu.Unlock()
+	for _, e := range entries {
+		s.sweep(e, nil)
+	}
+}
+
+// 分批删除一个表中过期的行，stop 关闭时返回 false
+func (s *Sweeper) sweep(e *sweepEntry, stop chan struct{}) bool {
+	start := time.Now()
+	i, _ := e.t.indexOf(e.column)
+	where := fmt.Sprintf("WHERE %s < ? ORDER BY %s LIMIT %d", e.t.Fields[i].FullName, e.t.Fields[i].FullName, s.opt.BatchSize)
+	args := []interface{}{start.Add(-e.retention)}
+	var deleted, errs int64
+	running := true
+	for running {
+		res, err := e.t.write(opDelete, fmt.Sprintf("%s %s", e.t.sqlDelete, where), args, where, args, nil)
+		if err != nil {
+			errs++
+			if s.opt.OnError != nil {
+				s.opt.OnError(e.t.Fullname, err)
+			}
+			break
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+		if n < int64(s.opt.BatchSize) {
+			break
+		}
+		select {
+		case <-stop:
+			running = false
+		case <-time.After(s.opt.Pause):
+		}
+	}
+	s.mu.Lock()
+	e.stats.Runs++
+	e.stats.Deleted += deleted
+	e.stats.Errors += errs
+	e.stats.LastRun = start
+	e.stats.LastDuration = time.Since(start)
+	s.mu.Unlock()
+	return running
+}
+
+// Stats 所有注册的表的清理统计
+func (s *Sweeper) Stats() []SweepStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]SweepStats, len(s.entries))
+	for i, e := range s.entries {
+		stats[i] = e.stats
+	}
+	return stats
+}
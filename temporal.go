@@ -0,0 +1,216 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration 对应 MySQL 的 TIME 类型
+//
+// TIME 表示一段时间而不是一天中的时刻，可以超过 24 小时，也可以为负，
+// 取值范围为 -838:59:59 到 838:59:59。
+type Duration time.Duration
+
+// String 格式化为 TIME 的文本形式，例如 -25:30:00 和 01:02:03.500000
+func (d Duration) String() string {
+	v := time.Duration(d)
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	h := v / time.Hour
+	m := v % time.Hour / time.Minute
+	s := v % time.Minute / time.Second
+	us := v % time.Second / time.Microsecond
+	if us != 0 {
+		return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, h, m, s, us)
+	}
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, h, m, s)
+}
+
+// Scan implements the Scanner interface.
+func (d *Duration) Scan(value interface{}) error {
+	var nd NullDuration
+	if err := nd.Scan(value); err != nil {
+		return err
+	}
+	*d = nd.Duration
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (d Duration) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// NullDuration 可空的 TIME
+type NullDuration struct {
+	Duration Duration
+	Valid    bool // Valid is true if Duration is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (nd *NullDuration) Scan(value interface{}) error {
+	var err error
+	switch v := value.(type) {
+	case nil:
+		nd.Duration, nd.Valid = 0, false
+	case []byte:
+		nd.Duration, err = ParseDuration(string(v))
+		nd.Valid = err == nil
+	case string:
+		nd.Duration, err = ParseDuration(v)
+		nd.Valid = err == nil
+	case int64:
+		//数字形式的 TIME，例如 TIME 字段参与运算的结果 HHMMSS
+		nd.Duration, err = ParseDuration(strconv.FormatInt(v, 10))
+		nd.Valid = err == nil
+	default:
+		nd.Duration, nd.Valid = 0, false
+		err = fmt.Errorf("db: can't scan %T into Duration", value)
+	}
+	return err
+}
+
+// Value implements the driver Valuer interface.
+func (nd NullDuration) Value() (driver.Value, error) {
+	if !nd.Valid {
+		return nil, nil
+	}
+	return nd.Duration.String(), nil
+}
+
+// ParseDuration 解析 TIME 的文本形式，支持 [-]HH:MM:SS[.fraction]、
+// [-]D HH:MM:SS 和 [-]HHMMSS
+func ParseDuration(s string) (Duration, error) {
+	str := strings.TrimSpace(s)
+	neg := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(str, "-")
+	var days int64
+	if i := strings.IndexByte(str, ' '); i >= 0 {
+		n, err := strconv.ParseInt(str[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("db: can't parse (%s) as time", s)
+		}
+		days, str = n, str[i+1:]
+	}
+	var frac string
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		str, frac = str[:i], str[i+1:]
+	}
+	var parts []string
+	if strings.Contains(str, ":") {
+		parts = strings.Split(str, ":")
+	} else {
+		//HHMMSS 格式，从右往左每两位一段
+		for len(str) > 2 {
+			parts = append([]string{str[len(str)-2:]}, parts...)
+			str = str[:len(str)-2]
+		}
+		parts = append([]string{str}, parts...)
+	}
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("db: can't parse (%s) as time", s)
+	}
+	//不足三段时按 HH:MM 补齐
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	var v time.Duration
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i := range parts {
+		n, err := strconv.ParseInt(parts[i], 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("db: can't parse (%s) as time", s)
+		}
+		v += time.Duration(n) * units[i]
+	}
+	v += time.Duration(days) * 24 * time.Hour
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		n, err := strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("db: can't parse (%s) as time", s)
+		}
+		v += time.Duration(n)
+	}
+	if neg {
+		v = -v
+	}
+	return Duration(v), nil
+}
+
+// 按字段类型转换写入的值：time.Duration 写入 TIME，time.Time 写入 YEAR，
+// 写入 DATETIME 等字段时按小数秒位数截断，避免 MySQL 四舍五入进位
+func (t Table) formatValues(values []interface{}) []interface{} {
+	var converted []interface{}
+	for i := range values {
+		if i >= t.Len || values[i] == nil {
+			continue
+		}
+		var value interface{}
+		switch t.Fields[i].Type.Value {
+		case TypeTime:
+			switch v := values[i].(type) {
+			case time.Duration:
+				value = Duration(v.Truncate(fspUnit(t.Fields[i].Type.Fsp)))
+			case Duration:
+				value = Duration(time.Duration(v).Truncate(fspUnit(t.Fields[i].Type.Fsp)))
+			}
+		case TypeDatetime, TypeTimestamp:
+			if v, ok := values[i].(time.Time); ok && v.Nanosecond() != 0 {
+				value = v.Truncate(fspUnit(t.Fields[i].Type.Fsp))
+			}
+		case TypeYear:
+			if v, ok := values[i].(time.Time); ok {
+				value = int16(v.Year())
+			}
+		}
+		if value == nil {
+			continue
+		}
+		if converted == nil {
+			converted = make([]interface{}, len(values))
+			copy(converted, values)
+		}
+		converted[i] = value
+	}
+	if converted == nil {
+		return values
+	}
+	return converted
+}
+
+// 小数秒位数对应的精度
+func fspUnit(fsp int) time.Duration {
+	unit := time.Second
+	for i := 0; i < fsp && i < 9; i++ {
+		unit /= 10
+	}
+	return unit
+}
+
+// 读取 TIME 和 YEAR 时的值，Slice 和 Map 中分别为 Duration 和 int16
+func parseTemporal(src interface{}) (interface{}, bool) {
+	switch v := src.(type) {
+	case *Duration:
+		return *v, true
+	case *NullDuration:
+		if !v.Valid {
+			return nil, true
+		}
+		return v.Duration, true
+	case *sql.NullInt16:
+		if !v.Valid {
+			return nil, true
+		}
+		return v.Int16, true
+	}
+	return nil, false
+}
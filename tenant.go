@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+)
+
+// ErrInvalidTenant 租户对应的数据库名不是合法的标识符
+var ErrInvalidTenant = errors.New("db: the tenant schema is not a valid identifier")
+
+// 数据库名直接拼接在语句中，只允许字母、数字、下划线和 $
+var reSchema = regexp.MustCompile(`^[A-Za-z0-9_$]+$`)
+
+// Tenancy 多租户设置
+type Tenancy struct {
+	//表名前缀，GetTable 时自动加上，例如 "app_"
+	Prefix string
+	//租户对应的数据库名，为 nil 时数据库名就是租户名
+	Schema func(tenant string) string
+}
+
+var (
+	tenancy   Tenancy
+	tenancyMu sync.RWMutex
+)
+
+// SetTenancy 设置多租户，应在 GetTable 之前调用
+func SetTenancy(t Tenancy) {
+	tenancyMu.Lock()
+	tenancy = t
+	tenancyMu.Unlock()
+}
+
+func tablePrefix() string {
+	tenancyMu.RLock()
+	defer tenancyMu.RUnlock()
+	return tenancy.Prefix
+}
+
+type tenantKey struct{}
+
+// WithTenant 在上下文中记录租户
+//
+//	t.WithContext(db.WithTenant(ctx, "tenant_42")).Get(1)
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom 读取上下文中的租户
+func TenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// 上下文中租户对应的数据库名，没有租户时为空
+func tenantSchema(ctx context.Context) string {
+	tenant := TenantFrom(ctx)
+	if tenant == "" {
+		return ""
+	}
+	tenancyMu.RLock()
+	resolve := tenancy.Schema
+	tenancyMu.RUnlock()
+	if resolve == nil {
+		return tenant
+	}
+	return resolve(tenant)
+}
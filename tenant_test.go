@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTenantSchemaValidated(t *testing.T) {
+	users := openFake(t, 3)
+	SetTenancy(Tenancy{})
+	defer SetTenancy(Tenancy{})
+	tt := users.WithContext(WithTenant(context.Background(), "tenant_42"))
+	if tt.Fullname != "tenant_42.users" {
+		t.Fatalf("Fullname = %q, want tenant_42.users", tt.Fullname)
+	}
+	bad := users.WithContext(WithTenant(context.Background(), "x.users; DROP TABLE users; --"))
+	if bad.Fullname != users.Fullname {
+		t.Fatalf("an invalid tenant changed Fullname to %q", bad.Fullname)
+	}
+	if _, err := bad.Count(); !errors.Is(err, ErrInvalidTenant) {
+		t.Fatalf("Count() = %v, want ErrInvalidTenant", err)
+	}
+	if _, err := bad.GetMany(); !errors.Is(err, ErrInvalidTenant) {
+		t.Fatalf("GetMany() = %v, want ErrInvalidTenant", err)
+	}
+	if _, err := bad.Add(nil, "name", int64(1)); !errors.Is(err, ErrInvalidTenant) {
+		t.Fatalf("Add() = %v, want ErrInvalidTenant", err)
+	}
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 默认的服务端最长执行时间，0 表示不限制
+var maxExecutionTime durationSetting
+
+// SetMaxExecutionTime 设置 SELECT 语句默认的服务端最长执行时间
+//
+// 超时的查询由 MySQL 终止，而不只是客户端放弃。d 为 0 时取消限制。
+func SetMaxExecutionTime(d time.Duration) {
+	maxExecutionTime.store(d)
+}
+
+// WithMaxExecutionTime 给单条 SELECT 语句加上 MAX_EXECUTION_TIME 优化器提示
+//
+// 已带有该提示或不是 SELECT 的语句原样返回，因此可以覆盖默认设置。
+func WithMaxExecutionTime(d time.Duration, query string) string {
+	trimmed := strings.TrimLeft(query, " \t\r\n")
+	if len(trimmed) < 6 || !strings.EqualFold(trimmed[:6], "SELECT") {
+		return query
+	}
+	if strings.Contains(strings.ToUpper(query), "MAX_EXECUTION_TIME") {
+		return query
+	}
+	ms := d.Nanoseconds() / int64(time.Millisecond)
+	if ms <= 0 {
+		return query
+	}
+	return fmt.Sprintf("SELECT /*+ MAX_EXECUTION_TIME(%d) */%s", ms, trimmed[6:])
+}
+
+// 使用默认设置
+func defaultExecutionTime(query string) string {
+	d := maxExecutionTime.load()
+	if d <= 0 {
+		return query
+	}
+	return WithMaxExecutionTime(d, query)
+}
+
+// 默认的客户端查询超时，0 表示不限制
+var queryTimeout durationSetting
+
+type queryTimeoutKey struct{}
+
+// SetQueryTimeout 设置经过本包执行的每条语句默认的客户端超时
+//
+// ctx 已有期限的调用不受影响；d 为 0 时取消默认超时。
+// 超时后客户端放弃等待，服务端的语句可能还在执行，需要时配合 SetMaxExecutionTime。
+func SetQueryTimeout(d time.Duration) {
+	queryTimeout.store(d)
+}
+
+// WithQueryTimeout 返回覆盖默认超时的 ctx，d 为 0 时该 ctx 上的语句不限制时间
+func WithQueryTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutKey{}, d)
+}
+
+// 语句使用的超时，没有时返回 0
+func timeoutOf(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(queryTimeoutKey{}).(time.Duration); ok {
+		return d
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return 0
+	}
+	return queryTimeout.load()
+}
+
+// 给 ctx 加上超时，返回的 cancel 在语句完成后调用
+func timeoutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	d := timeoutOf(ctx)
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRowsCloseReleasesTimeout(t *testing.T) {
+	users := openFake(t, 3)
+	SetQueryTimeout(time.Hour)
+	defer SetQueryTimeout(0)
+	rs, err := users.GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.cancel == nil {
+		t.Fatal("rows opened with a query timeout have no cancel")
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rs.cancel != nil {
+		t.Fatal("Close did not release the query timeout")
+	}
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Begin 在 Open 打开的连接池上开始事务
+func Begin() (*sql.Tx, error) {
+	return BeginTx(context.Background(), nil)
+}
+
+// BeginTx 开始事务，ctx 带有连接池时使用该连接池
+//
+// 事务中的语句不经过包内的检查，只读模式下只能开始只读事务，否则返回 ErrReadOnly。
+func BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if isReadOnly() && (opts == nil || !opts.ReadOnly) {
+		return nil, ErrReadOnly
+	}
+	return connFrom(ctx).BeginTx(ctx, opts)
+}
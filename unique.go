@@ -0,0 +1,43 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 按名称查找唯一索引，name 可以是索引名，也可以是单列唯一索引的字段名
+func (t Table) uniqueIndex(name string) (*Index, error) {
+	for i := range t.Indexes {
+		if t.Indexes[i].Unique && strings.EqualFold(t.Indexes[i].Name, name) {
+			return &t.Indexes[i], nil
+		}
+	}
+	for i := range t.Indexes {
+		idx := &t.Indexes[i]
+		if idx.Unique && len(idx.Columns) == 1 && strings.EqualFold(idx.Columns[0], name) {
+			return idx, nil
+		}
+	}
+	return nil, fmt.Errorf("db: the unique index (%s) not found in table (%s)", name, t.TbName)
+}
+
+// GetByUnique 按唯一索引查询一行，values 按索引中字段的顺序给出
+//
+// name 为唯一索引名或单列唯一索引的字段名，例如
+//
+//	t.GetByUnique("email", "a@example.com")
+//	t.GetByUnique("uk_tenant_name", tenantID, "bob")
+func (t *Table) GetByUnique(name string, values ...interface{}) *Row {
+	idx, err := t.uniqueIndex(name)
+	if err != nil {
+		return &Row{t: t, err: err}
+	}
+	if len(values) != len(idx.Columns) {
+		return &Row{t: t, err: fmt.Errorf("db: the unique index (%s) has %d columns, got %d values", idx.Name, len(idx.Columns), len(values))}
+	}
+	listwhere := make([]string, len(idx.Columns))
+	for i, column := range idx.Columns {
+		listwhere[i] = fmt.Sprintf("%s.`%s`=?", t.TbName, column)
+	}
+	return t.row(fmt.Sprintf("%s WHERE %s limit 1", t.sqlSelect, strings.Join(listwhere, " AND ")), values...)
+}
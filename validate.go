@@ -0,0 +1,137 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation 一个不符合字段定义的值
+type Violation struct {
+	Column  string
+	Value   interface{}
+	Message string
+}
+
+// ValidationError 写入前检查发现的所有问题
+type ValidationError struct {
+	Table      string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	items := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		items[i] = fmt.Sprintf("%s: %s", v.Column, v.Message)
+	}
+	return fmt.Sprintf("db: the values of table (%s) are invalid: %s", e.Table, strings.Join(items, "; "))
+}
+
+// SetValidation 开启后 Add 和 Update 在执行前调用 Validate 检查
+func (t *Table) SetValidation(on bool) {
+	t.validate = on
+}
+
+// 各种文本类型的最大字节数
+var textBytes = map[int]int{
+	TypeText:       math.MaxUint16,
+	TypeMediumText: 1<<24 - 1,
+}
+
+// Validate 按字段定义检查按位置排列的值
+//
+// 检查字符串长度、枚举取值、整数范围，insert 为 true 时还检查没有默认值的 NOT NULL 字段是否给出。
+// 发现问题时返回 *ValidationError，列出所有问题。
+func (t Table) Validate(insert bool, values ...interface{}) error {
+	if len(values) > t.Len {
+		return fmt.Errorf("db: the values numbers (%d) more than table column numbers (%d)", len(values), t.Len)
+	}
+	violations := make([]Violation, 0)
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		if value == nil {
+			if insert && !f.Null && f.Default.Null && !strings.Contains(strings.ToLower(f.Extra), "auto_increment") {
+				violations = append(violations, Violation{Column: f.Name, Message: "NOT NULL column without default is missing"})
+			}
+			continue
+		}
+		//Sql 片段的值在数据库中才能确定
+		if _, ok := value.(Expression); ok {
+			continue
+		}
+		if msg := checkValue(f.Type, value); msg != "" {
+			violations = append(violations, Violation{Column: f.Name, Value: value, Message: msg})
+		}
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Table: t.TbName, Violations: violations}
+	}
+	return nil
+}
+
+// 检查一个值，没有问题时返回空字符串
+func checkValue(ft FieldType, value interface{}) string {
+	rv := reflect.ValueOf(value)
+	switch ft.Value {
+	case TypeChar, TypeVarchar:
+		if s, ok := stringOf(value); ok && ft.Length > 0 && utf8.RuneCountInString(s) > ft.Length {
+			return fmt.Sprintf("length %d exceeds %s(%d)", utf8.RuneCountInString(s), ft.Name, ft.Length)
+		}
+	case TypeText, TypeMediumText:
+		if s, ok := stringOf(value); ok && len(s) > textBytes[ft.Value] {
+			return fmt.Sprintf("%d bytes exceeds %s", len(s), ft.Name)
+		}
+	case TypeEnum:
+		if s, ok := stringOf(value); ok {
+			for _, e := range ft.Enum {
+				if s == e {
+					return ""
+				}
+			}
+			return fmt.Sprintf("%q is not one of %s", s, ft.ToSql())
+		}
+	case TypeInt, TypeBigint:
+		var min, max float64
+		switch {
+		case ft.Value == TypeInt && ft.Unsigned:
+			min, max = 0, math.MaxUint32
+		case ft.Value == TypeInt:
+			min, max = math.MinInt32, math.MaxInt32
+		case ft.Unsigned:
+			min, max = 0, math.MaxUint64
+		default:
+			min, max = math.MinInt64, math.MaxInt64
+		}
+		var n float64
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			n = rv.Float()
+		default:
+			return ""
+		}
+		if n < min || n > max {
+			return fmt.Sprintf("%v is out of range of %s", value, ft.ToSql())
+		}
+	}
+	return ""
+}
+
+func stringOf(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
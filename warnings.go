@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 语句警告的处理方式
+const (
+	//不读取警告
+	WarningsIgnore int32 = iota
+	//读取警告并交给 SetWarnings 设置的回调
+	WarningsReport
+	//除 Note 之外的警告作为错误返回，同时交给回调
+	WarningsStrict
+)
+
+// Warning SHOW WARNINGS 的一行
+type Warning struct {
+	//Note、Warning 或 Error
+	Level   string
+	Code    int
+	Message string
+}
+
+// WarningError 严格模式下语句产生了警告
+//
+// 语句已经执行，不在事务中时修改已经生效。
+type WarningError struct {
+	Sql      string
+	Warnings []Warning
+}
+
+func (e *WarningError) Error() string {
+	list := make([]string, len(e.Warnings))
+	for i, w := range e.Warnings {
+		list[i] = fmt.Sprintf("%s %d: %s", w.Level, w.Code, w.Message)
+	}
+	return fmt.Sprintf("db: the statement produced warnings: %s", strings.Join(list, "; "))
+}
+
+var warnings struct {
+	sync.RWMutex
+	mode    int32
+	handler func(query string, warnings []Warning)
+}
+
+// SetWarnings 设置执行语句后是否读取 SHOW WARNINGS
+//
+// 打开后经过包内连接池执行的修改语句（Exec、Add、Update、Del 等）都在同一个连接上读取警告，
+// 例如字段被截断、数据转换，fn 不为 nil 时收到所有警告。查询语句不读取警告。
+func SetWarnings(mode int32, fn func(query string, warnings []Warning)) {
+	warnings.Lock()
+	warnings.mode, warnings.handler = mode, fn
+	warnings.Unlock()
+}
+
+func warningSettings() (int32, func(string, []Warning)) {
+	warnings.RLock()
+	defer warnings.RUnlock()
+	return warnings.mode, warnings.handler
+}
+
+// 执行语句，需要时在同一个连接上读取警告
+func execWarn(sqldb *sql.DB, ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	mode, handler := warningSettings()
+	if mode == WarningsIgnore {
+		return sqldb.ExecContext(ctx, query, args...)
+	}
+	c, err := sqldb.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	res, err := c.ExecContext(ctx, query, args...)
+	if err != nil {
+		return res, err
+	}
+	list, err := showWarnings(c, ctx)
+	if err != nil {
+		return res, err
+	}
+	return res, reportWarnings(query, list, mode, handler)
+}
+
+// 在事务中执行语句，事务本身就在同一个连接上
+func execWarnTx(tx *sql.Tx, ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	mode, handler := warningSettings()
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil || mode == WarningsIgnore {
+		return res, err
+	}
+	list, err := showWarnings(tx, ctx)
+	if err != nil {
+		return res, err
+	}
+	return res, reportWarnings(query, list, mode, handler)
+}
+
+// 回调警告，严格模式下有 Note 以外的警告时返回错误
+func reportWarnings(query string, list []Warning, mode int32, handler func(string, []Warning)) error {
+	if len(list) == 0 {
+		return nil
+	}
+	if handler != nil {
+		handler(query, list)
+	}
+	if mode == WarningsStrict {
+		serious := make([]Warning, 0, len(list))
+		for _, w := range list {
+			if w.Level != "Note" {
+				serious = append(serious, w)
+			}
+		}
+		if len(serious) > 0 {
+			return &WarningError{Sql: query, Warnings: serious}
+		}
+	}
+	return nil
+}
+
+// 读取连接上最后一条语句的警告
+func showWarnings(c querier, ctx context.Context) ([]Warning, error) {
+	rows, err := c.QueryContext(ctx, "SHOW WARNINGS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := make([]Warning, 0)
+	for rows.Next() {
+		var w Warning
+		if err = rows.Scan(&w.Level, &w.Code, &w.Message); err != nil {
+			return nil, err
+		}
+		list = append(list, w)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,270 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Condition 查询条件
+type Condition struct {
+	column string
+	op     string
+	args   []interface{}
+	//原样使用的条件语句
+	raw string
+	//And、Or、Not 组合的子条件
+	conds []Condition
+}
+
+// Raw 原样使用的条件语句，可以引用 CTE 或其他表
+//
+//	db.Raw("id IN (SELECT id FROM recent)")
+func Raw(query string, args ...interface{}) Condition {
+	return Condition{raw: query, args: args}
+}
+
+// 查找字段的位置
+func (t Table) indexOf(column string) (int, error) {
+	for i := range t.Fields {
+		if t.Fields[i].Name == column {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("db: the column (%s) not found in table (%s)", column, t.TbName)
+}
+
+// 生成条件语句
+func (c Condition) toSql(t *Table) (string, []interface{}, error) {
+	if c.raw != "" {
+		return "(" + c.raw + ")", c.args, nil
+	}
+	switch c.op {
+	case "AND", "OR":
+		return c.joinSql(t)
+	case "NOT":
+		where, args, err := c.conds[0].toSql(t)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + where + ")", args, nil
+	}
+	i, err := t.indexOf(c.column)
+	if err != nil {
+		return "", nil, err
+	}
+	column := t.Fields[i].FullName
+	switch c.op {
+	case "IS NULL", "IS NOT NULL":
+		return fmt.Sprintf("%s %s", column, c.op), nil, nil
+	case "IN", "NOT IN":
+		if len(c.args) == 0 {
+			//空集合
+			if c.op == "IN" {
+				return "1=0", nil, nil
+			}
+			return "1=1", nil, nil
+		}
+		return fmt.Sprintf("%s %s (%s)", column, c.op, strings.TrimSuffix(strings.Repeat("?, ", len(c.args)), ", ")), c.args, nil
+	case "BETWEEN":
+		return fmt.Sprintf("%s BETWEEN ? AND ?", column), c.args, nil
+	}
+	return fmt.Sprintf("%s %s ?", column, c.op), c.args, nil
+}
+
+// 用 AND 或 OR 连接子条件，没有子条件时 AND 为真，OR 为假
+func (c Condition) joinSql(t *Table) (string, []interface{}, error) {
+	if len(c.conds) == 0 {
+		if c.op == "AND" {
+			return "1=1", nil, nil
+		}
+		return "1=0", nil, nil
+	}
+	listwhere := make([]string, 0, len(c.conds))
+	listparam := make([]interface{}, 0, len(c.conds))
+	for i := range c.conds {
+		where, args, err := c.conds[i].toSql(t)
+		if err != nil {
+			return "", nil, err
+		}
+		listwhere = append(listwhere, where)
+		listparam = append(listparam, args...)
+	}
+	return "(" + strings.Join(listwhere, " "+c.op+" ") + ")", listparam, nil
+}
+
+// 用 AND 连接多个条件
+func (t *Table) sqlWhere(conds []Condition) (string, []interface{}, error) {
+	listwhere := make([]string, 0, len(conds))
+	listparam := make([]interface{}, 0, len(conds))
+	for i := range conds {
+		where, args, err := conds[i].toSql(t)
+		if err != nil {
+			return "", nil, err
+		}
+		listwhere = append(listwhere, where)
+		listparam = append(listparam, args...)
+	}
+	if len(listwhere) == 0 {
+		return "", listparam, nil
+	}
+	return "WHERE " + strings.Join(listwhere, " AND "), listparam, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike 转义 LIKE 模式中的 \ % _
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// Like 字段 LIKE 指定的值，值中的通配符会被转义
+func Like(column, value string) Condition {
+	return Condition{column: column, op: "LIKE", args: []interface{}{EscapeLike(value)}}
+}
+
+// Contains 字段包含指定的字符串
+func Contains(column, value string) Condition {
+	return Condition{column: column, op: "LIKE", args: []interface{}{"%" + EscapeLike(value) + "%"}}
+}
+
+// StartsWith 字段以指定的字符串开头
+func StartsWith(column, value string) Condition {
+	return Condition{column: column, op: "LIKE", args: []interface{}{EscapeLike(value) + "%"}}
+}
+
+// EndsWith 字段以指定的字符串结尾
+func EndsWith(column, value string) Condition {
+	return Condition{column: column, op: "LIKE", args: []interface{}{"%" + EscapeLike(value)}}
+}
+
+// Where 查询满足所有条件的数据
+func (t *Table) Where(conds ...Condition) (*Rows, error) {
+	where, args, err := t.sqlWhere(conds)
+	if err != nil {
+		return nil, err
+	}
+	return t.rows(fmt.Sprintf("%s %s", t.sqlSelect, where), args...)
+}
+
+// Eq 字段等于指定的值
+//
+// 省略 value 时作为预编译查询的参数占位，值在执行时给出，见 Table.Prepare。
+func Eq(column string, value ...interface{}) Condition {
+	if len(value) == 0 {
+		return Condition{column: column, op: "="}
+	}
+	return Condition{column: column, op: "=", args: value[:1]}
+}
+
+// Neq 字段不等于指定的值
+func Neq(column string, value interface{}) Condition {
+	return Condition{column: column, op: "!=", args: []interface{}{value}}
+}
+
+// Gt 字段大于指定的值
+func Gt(column string, value interface{}) Condition {
+	return Condition{column: column, op: ">", args: []interface{}{value}}
+}
+
+// Gte 字段大于等于指定的值
+func Gte(column string, value interface{}) Condition {
+	return Condition{column: column, op: ">=", args: []interface{}{value}}
+}
+
+// Lt 字段小于指定的值
+func Lt(column string, value interface{}) Condition {
+	return Condition{column: column, op: "<", args: []interface{}{value}}
+}
+
+// Lte 字段小于等于指定的值
+func Lte(column string, value interface{}) Condition {
+	return Condition{column: column, op: "<=", args: []interface{}{value}}
+}
+
+// In 字段的值在 values 中，values 为空时没有满足条件的行
+func In(column string, values ...interface{}) Condition {
+	return Condition{column: column, op: "IN", args: values}
+}
+
+// NotIn 字段的值不在 values 中，values 为空时所有行都满足条件
+func NotIn(column string, values ...interface{}) Condition {
+	return Condition{column: column, op: "NOT IN", args: values}
+}
+
+// Between 字段的值在 min 和 max 之间，包含两端
+func Between(column string, min, max interface{}) Condition {
+	return Condition{column: column, op: "BETWEEN", args: []interface{}{min, max}}
+}
+
+// IsNull 字段为 NULL
+func IsNull(column string) Condition {
+	return Condition{column: column, op: "IS NULL"}
+}
+
+// IsNotNull 字段不为 NULL
+func IsNotNull(column string) Condition {
+	return Condition{column: column, op: "IS NOT NULL"}
+}
+
+// And 所有条件都满足，可以嵌套
+//
+//	db.Or(db.Eq("status", 1), db.And(db.Eq("status", 0), db.Gt("updated", t)))
+func And(conds ...Condition) Condition {
+	return Condition{op: "AND", conds: conds}
+}
+
+// Or 满足任意一个条件
+func Or(conds ...Condition) Condition {
+	return Condition{op: "OR", conds: conds}
+}
+
+// Not 不满足条件
+func Not(cond Condition) Condition {
+	return Condition{op: "NOT", conds: []Condition{cond}}
+}
+
+// GetWhere 满足所有条件的第一行
+func (t *Table) GetWhere(conds ...Condition) *Row {
+	where, args, err := t.sqlWhere(conds)
+	if err != nil {
+		return &Row{t: t, err: err}
+	}
+	return t.row(fmt.Sprintf("%s %s limit 1", t.sqlSelect, where), args...)
+}
+
+// CountWhere 统计满足所有条件的行数
+func (t Table) CountWhere(conds ...Condition) (int64, error) {
+	where, args, err := t.sqlWhere(conds)
+	if err != nil {
+		return -1, err
+	}
+	return t.scalarInt64(fmt.Sprintf("%s %s", t.sqlSelectCount, where), args...)
+}
+
+// DelWhere 删除满足所有条件的行，没有条件时返回错误，不会删除整个表
+func (t Table) DelWhere(conds ...Condition) (int64, error) {
+	if len(conds) == 0 {
+		return -1, errors.New("db: DelWhere requires at least one condition")
+	}
+	where, args, err := t.sqlWhere(conds)
+	if err != nil {
+		return -1, err
+	}
+	res, err := t.write(opDelete, fmt.Sprintf("%s %s", t.sqlDelete, where), args, where, args, nil)
+	if err != nil {
+		return -1, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdateWhere 修改满足所有条件的行，没有条件时返回错误，不会修改整个表
+//
+//	t.UpdateWhere(db.In("id", 1, 2, 3)).Columns(map[string]interface{}{"status": 0})
+func (t *Table) UpdateWhere(conds ...Condition) *Setter {
+	if len(conds) == 0 {
+		return &Setter{t: t, err: errors.New("db: UpdateWhere requires at least one condition")}
+	}
+	where, args, err := t.sqlWhere(conds)
+	return &Setter{t: t, query: where, args: args, err: err}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Window 窗口定义，用于生成 OVER (...) 子句
+type Window struct {
+	partition []string
+	order     []string
+}
+
+// Over 开始定义窗口
+func Over() *Window {
+	return &Window{}
+}
+
+// PartitionBy 按字段分区
+func (w *Window) PartitionBy(columns ...string) *Window {
+	for _, c := range columns {
+		w.partition = append(w.partition, "`"+c+"`")
+	}
+	return w
+}
+
+// OrderBy 窗口内按字段升序排列
+func (w *Window) OrderBy(column string) *Window {
+	w.order = append(w.order, "`"+column+"` ASC")
+	return w
+}
+
+// OrderByDesc 窗口内按字段降序排列
+func (w *Window) OrderByDesc(column string) *Window {
+	w.order = append(w.order, "`"+column+"` DESC")
+	return w
+}
+
+func (w *Window) String() string {
+	parts := make([]string, 0, 2)
+	if len(w.partition) > 0 {
+		parts = append(parts, "PARTITION BY "+strings.Join(w.partition, ", "))
+	}
+	if len(w.order) > 0 {
+		parts = append(parts, "ORDER BY "+strings.Join(w.order, ", "))
+	}
+	return "OVER (" + strings.Join(parts, " ") + ")"
+}
+
+// RowNumber ROW_NUMBER() 窗口函数，与 Selector.Column 一起使用
+//
+//	t.Select().Column("rn", db.RowNumber(db.Over().PartitionBy("user_id").OrderByDesc("created")))
+func RowNumber(w *Window) string {
+	return "ROW_NUMBER() " + w.String()
+}
+
+// Rank RANK() 窗口函数
+func Rank(w *Window) string {
+	return "RANK() " + w.String()
+}
+
+// DenseRank DENSE_RANK() 窗口函数
+func DenseRank(w *Window) string {
+	return "DENSE_RANK() " + w.String()
+}
+
+// WindowFunc 任意窗口函数，例如 WindowFunc("SUM(`amount`)", w)
+func WindowFunc(fn string, w *Window) string {
+	return fmt.Sprintf("%s %s", fn, w.String())
+}
+
+// Extra 当前行附加列的值，列不存在时返回 nil
+func (rs *Rows) Extra(name string) interface{} {
+	for i := range rs.extras {
+		if rs.extras[i] == name {
+			v := *rs.scans[rs.t.Len+i].(*interface{})
+			if buf, ok := v.([]byte); ok {
+				return string(buf)
+			}
+			return v
+		}
+	}
+	return nil
+}
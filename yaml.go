@@ -0,0 +1,198 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 把 JSON 解码得到的值写成块格式的 YAML，字符串使用双引号
+func writeYAML(b *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString(pad + k + ":")
+			writeYAMLChild(b, v[k], indent)
+		}
+	case []interface{}:
+		for _, item := range v {
+			b.WriteString(pad + "-")
+			writeYAMLChild(b, item, indent)
+		}
+	default:
+		b.WriteString(pad + yamlScalar(v) + "\n")
+	}
+}
+
+// 写出映射的值或序列的元素，非空的映射和序列另起一行并缩进
+func writeYAMLChild(b *bytes.Buffer, v interface{}, indent int) {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		if len(c) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+	case []interface{}:
+		if len(c) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+		return
+	}
+	b.WriteString("\n")
+	writeYAML(b, v, indent+2)
+}
+
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		buf, _ := json.Marshal(v)
+		return string(buf)
+	}
+	return fmt.Sprint(v)
+}
+
+// YAML 的一行
+type yamlLine struct {
+	no     int
+	indent int
+	text   string
+}
+
+// 解析 writeYAML 写出的 YAML 子集
+func parseYAML(data []byte) (interface{}, error) {
+	lines := make([]yamlLine, 0)
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{no: i + 1, indent: len(raw) - len(text), text: text})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	v, next, err := parseYAMLNode(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("db: yaml line %d: unexpected indentation", lines[next].no)
+	}
+	return v, nil
+}
+
+func parseYAMLNode(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	if lines[i].text == "-" || strings.HasPrefix(lines[i].text, "- ") {
+		return parseYAMLSeq(lines, i, indent)
+	}
+	return parseYAMLMap(lines, i, indent)
+}
+
+func parseYAMLSeq(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	seq := make([]interface{}, 0)
+	for i < len(lines) && lines[i].indent == indent {
+		l := lines[i]
+		if l.text != "-" && !strings.HasPrefix(l.text, "- ") {
+			break
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(l.text, "-"))
+		i++
+		if rest != "" {
+			v, err := parseYAMLScalar(rest, l.no)
+			if err != nil {
+				return nil, i, err
+			}
+			seq = append(seq, v)
+			continue
+		}
+		v, next, err := parseYAMLBlock(lines, i, indent)
+		if err != nil {
+			return nil, i, err
+		}
+		seq, i = append(seq, v), next
+	}
+	return seq, i, nil
+}
+
+func parseYAMLMap(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	m := make(map[string]interface{})
+	for i < len(lines) && lines[i].indent == indent {
+		l := lines[i]
+		k := strings.Index(l.text, ":")
+		if k <= 0 || strings.HasPrefix(l.text, "- ") {
+			return nil, i, fmt.Errorf("db: yaml line %d: expected key: value", l.no)
+		}
+		key, rest := strings.TrimSpace(l.text[:k]), strings.TrimSpace(l.text[k+1:])
+		i++
+		if rest != "" {
+			v, err := parseYAMLScalar(rest, l.no)
+			if err != nil {
+				return nil, i, err
+			}
+			m[key] = v
+			continue
+		}
+		v, next, err := parseYAMLBlock(lines, i, indent)
+		if err != nil {
+			return nil, i, err
+		}
+		m[key], i = v, next
+	}
+	return m, i, nil
+}
+
+// 解析缩进更深的子节点，没有时为 null
+func parseYAMLBlock(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	if i >= len(lines) || lines[i].indent <= indent {
+		return nil, i, nil
+	}
+	return parseYAMLNode(lines, i, lines[i].indent)
+}
+
+func parseYAMLScalar(s string, no int) (interface{}, error) {
+	switch s {
+	case "null", "~":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "{}":
+		return map[string]interface{}{}, nil
+	case "[]":
+		return []interface{}{}, nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		var v string
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, fmt.Errorf("db: yaml line %d: %w", no, err)
+		}
+		return v, nil
+	}
+	if strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") && len(s) >= 2 {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return s, nil
+}